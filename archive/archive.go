@@ -0,0 +1,422 @@
+// Package archive keeps the bodies fetched from URLs on local disk. Each
+// body is gzipped and stored once under its SHA-256, however many URLs or
+// fetches return it, and an index records which versions each URL served.
+// Old versions are dropped by age and to keep the archive within a disk
+// budget.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+// ErrNotFound is returned for a hash the archive does not hold.
+var ErrNotFound = errors.New("snapshot not found")
+
+const (
+	indexFile = "index.json"
+	blobsDir  = "blobs"
+)
+
+// Options says where the archive lives and what it keeps. Bodies over
+// MaxBody bytes are not kept; versions last seen more than MaxAge ago are
+// dropped, unless it is zero, then the oldest until the compressed copies
+// fit in MaxBytes.
+type Options struct {
+	Dir      string
+	MaxBody  int64
+	MaxAge   time.Duration
+	MaxBytes int64
+}
+
+// Archive is the snapshot archive in one directory. Its index is saved
+// every ARCHIVE_SAVE_EVERY seconds when it changed, and on Close; bodies
+// are written as they are stored.
+type Archive struct {
+	mutex    sync.Mutex
+	options  Options
+	versions map[string][]*types.Snapshot // by URL, oldest first
+	blobs    map[string]*blob             // by hash
+	stored   int64                        // compressed bytes on disk
+
+	// seen holds every version, least recently seen first, so retention
+	// drops from its front without sorting.
+	seen     *list.List
+	elements map[*types.Snapshot]*list.Element
+
+	// dirty is set under mutex when the index changes; flush writes it out
+	// under saving.
+	dirty  bool
+	saving sync.Mutex
+	done   chan struct{}
+}
+
+// seenVersion is an entry of Archive.seen.
+type seenVersion struct {
+	url     string
+	version *types.Snapshot
+}
+
+// blob is a compressed body on disk and how many versions refer to it.
+type blob struct {
+	refs int
+	size int64
+}
+
+// Open loads the archive in opts.Dir, creating the directory if needed.
+// Stored bodies no version refers to, as left by a crash, are removed.
+func Open(opts Options) (*Archive, error) {
+	if err := os.MkdirAll(filepath.Join(opts.Dir, blobsDir), 0755); err != nil {
+		return nil, err
+	}
+	a := &Archive{options: opts, versions: map[string][]*types.Snapshot{}, blobs: map[string]*blob{},
+		seen: list.New(), elements: map[*types.Snapshot]*list.Element{}, done: make(chan struct{})}
+
+	data, err := os.ReadFile(filepath.Join(opts.Dir, indexFile))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &a.versions); err != nil {
+			return nil, fmt.Errorf("reading archive index: %w", err)
+		}
+	}
+
+	files := map[string]string{}
+	err = filepath.WalkDir(filepath.Join(opts.Dir, blobsDir), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		hash := strings.TrimSuffix(entry.Name(), ".gz")
+		files[hash] = path
+		a.blobs[hash] = &blob{size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for url, versions := range a.versions {
+		kept := versions[:0]
+		for _, version := range versions {
+			if b, exists := a.blobs[version.Hash]; exists {
+				version.StoredSize = b.size
+				b.refs++
+				kept = append(kept, version)
+			}
+		}
+		if len(kept) == 0 {
+			delete(a.versions, url)
+			continue
+		}
+		a.versions[url] = kept
+	}
+	for hash, b := range a.blobs {
+		if b.refs == 0 {
+			delete(a.blobs, hash)
+			os.Remove(files[hash])
+			continue
+		}
+		a.stored += b.size
+	}
+
+	var all []seenVersion
+	for url, versions := range a.versions {
+		for _, version := range versions {
+			all = append(all, seenVersion{url: url, version: version})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].version.LastSeen.Before(all[j].version.LastSeen) })
+	for _, entry := range all {
+		a.track(entry.url, entry.version)
+	}
+
+	a.mutex.Lock()
+	a.prune(time.Now())
+	a.dirty = true
+	a.mutex.Unlock()
+	a.flush()
+	go a.saveLoop()
+	slog.Info("opened snapshot archive", "dir", opts.Dir, "urls", len(a.versions), "bodies", len(a.blobs),
+		"stored_bytes", a.stored)
+	return a, nil
+}
+
+// Flush saves the index if it changed since it was last saved.
+func (a *Archive) Flush() {
+	a.flush()
+}
+
+// Close stops the periodic saves and saves the index.
+func (a *Archive) Close() {
+	close(a.done)
+	a.flush()
+}
+
+// saveLoop drops versions past MaxAge and saves the index when it changed,
+// every ARCHIVE_SAVE_EVERY seconds until Close.
+func (a *Archive) saveLoop() {
+	ticker := time.NewTicker(constants.ARCHIVE_SAVE_EVERY * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-ticker.C:
+			a.mutex.Lock()
+			a.prune(now)
+			a.mutex.Unlock()
+			a.flush()
+		}
+	}
+}
+
+// SetLimits applies the limits in opts, whose Dir is ignored, and drops
+// what they no longer allow.
+func (a *Archive) SetLimits(opts Options) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	opts.Dir = a.options.Dir
+	a.options = opts
+	a.prune(time.Now())
+}
+
+// Store keeps body as the latest version of url. A body equal to the
+// latest version only moves its LastSeen forward.
+func (a *Archive) Store(url string, body []byte, contentType string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if int64(len(body)) > a.options.MaxBody {
+		metrics.ArchiveWrites.Inc("too_large")
+		return nil
+	}
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now().UTC()
+
+	versions := a.versions[url]
+	if n := len(versions); n > 0 && versions[n-1].Hash == hash {
+		versions[n-1].LastSeen = now
+		a.seen.MoveToBack(a.elements[versions[n-1]])
+		a.dirty = true
+		metrics.ArchiveWrites.Inc("unchanged")
+		return nil
+	}
+
+	b, exists := a.blobs[hash]
+	if exists {
+		metrics.ArchiveWrites.Inc("duplicate")
+	} else {
+		size, err := a.write(hash, body)
+		if err != nil {
+			metrics.ArchiveWrites.Inc("error")
+			return err
+		}
+		b = &blob{size: size}
+		a.blobs[hash] = b
+		a.stored += size
+		metrics.ArchiveWrites.Inc("stored")
+	}
+	b.refs++
+	version := &types.Snapshot{Hash: hash, Size: int64(len(body)), StoredSize: b.size, ContentType: contentType,
+		FirstSeen: now, LastSeen: now}
+	a.versions[url] = append(versions, version)
+	a.track(url, version)
+	if excess := len(a.versions[url]) - constants.MAX_SNAPSHOTS; excess > 0 {
+		for _, dropped := range a.versions[url][:excess] {
+			a.untrack(dropped)
+		}
+		a.versions[url] = append(a.versions[url][:0:0], a.versions[url][excess:]...)
+	}
+	a.dirty = true
+	a.prune(now)
+	return nil
+}
+
+// track adds version of url to the back of seen. It must be called with
+// the mutex held, and only for a version whose LastSeen is the latest.
+func (a *Archive) track(url string, version *types.Snapshot) {
+	a.elements[version] = a.seen.PushBack(seenVersion{url: url, version: version})
+}
+
+// untrack removes version from seen and releases its body. It must be
+// called with the mutex held; the caller removes it from versions.
+func (a *Archive) untrack(version *types.Snapshot) {
+	a.seen.Remove(a.elements[version])
+	delete(a.elements, version)
+	a.release(version.Hash)
+}
+
+// write compresses body into the file for hash, through a temporary file
+// so a crash never leaves a partial body under its hash.
+func (a *Archive) write(hash string, body []byte) (int64, error) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(body)
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+
+	path := a.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, compressed.Bytes(), 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, err
+	}
+	return int64(compressed.Len()), nil
+}
+
+// Versions returns the versions kept of url, newest first.
+func (a *Archive) Versions(url string) []types.Snapshot {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	versions := a.versions[url]
+	list := make([]types.Snapshot, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		list = append(list, *versions[i])
+	}
+	return list
+}
+
+// Open returns the gzipped body stored under hash; the caller closes it.
+func (a *Archive) Open(hash string) (io.ReadCloser, error) {
+	a.mutex.Lock()
+	_, held := a.blobs[hash]
+	a.mutex.Unlock()
+	if !held {
+		return nil, ErrNotFound
+	}
+	// Retention may remove the file after the check; that reads as gone.
+	f, err := os.Open(a.blobPath(hash))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Usage returns how many versions are kept and the bytes their compressed
+// bodies take on disk.
+func (a *Archive) Usage() (int, int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	n := 0
+	for _, versions := range a.versions {
+		n += len(versions)
+	}
+	return n, a.stored
+}
+
+// prune drops versions last seen before MaxAge, then the least recently
+// seen until the stored bodies fit in MaxBytes. Within the limits it only
+// looks at the least recently seen version. It must be called with the
+// mutex held.
+func (a *Archive) prune(now time.Time) {
+	dropped := 0
+	for front := a.seen.Front(); front != nil; front = a.seen.Front() {
+		entry := front.Value.(seenVersion)
+		expired := a.options.MaxAge > 0 && now.Sub(entry.version.LastSeen) > a.options.MaxAge
+		if !expired && a.stored <= a.options.MaxBytes {
+			break
+		}
+		a.untrack(entry.version)
+		versions := a.versions[entry.url]
+		kept := versions[:0]
+		for _, version := range versions {
+			if version != entry.version {
+				kept = append(kept, version)
+			}
+		}
+		if len(kept) == 0 {
+			delete(a.versions, entry.url)
+		} else {
+			a.versions[entry.url] = kept
+		}
+		dropped++
+	}
+	if dropped == 0 {
+		return
+	}
+	a.dirty = true
+	slog.Info("pruned snapshot archive", "versions", dropped, "stored_bytes", a.stored)
+}
+
+// release drops one version's hold on hash and removes the body when it
+// was the last. It must be called with the mutex held.
+func (a *Archive) release(hash string) {
+	b := a.blobs[hash]
+	if b.refs--; b.refs > 0 {
+		return
+	}
+	delete(a.blobs, hash)
+	a.stored -= b.size
+	path := a.blobPath(hash)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		slog.Error("removing archived body", "file", path, "error", err)
+	}
+}
+
+// blobPath spreads bodies over directories named by the first two hex
+// digits of their hash.
+func (a *Archive) blobPath(hash string) string {
+	return filepath.Join(a.options.Dir, blobsDir, hash[:2], hash+".gz")
+}
+
+// flush writes the index to disk if it changed since the last write. It
+// must be called without the mutex held; only the index is encoded under
+// it.
+func (a *Archive) flush() {
+	a.saving.Lock()
+	defer a.saving.Unlock()
+
+	a.mutex.Lock()
+	if !a.dirty {
+		a.mutex.Unlock()
+		return
+	}
+	a.dirty = false
+	data, err := json.Marshal(a.versions)
+	a.mutex.Unlock()
+	if err != nil {
+		slog.Error("marshaling archive index", "error", err)
+		return
+	}
+	path := filepath.Join(a.options.Dir, indexFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		slog.Error("writing archive index", "file", tmp, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		slog.Error("replacing archive index", "file", path, "error", err)
+	}
+}
@@ -0,0 +1,117 @@
+package archive
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func open(t *testing.T, dir string) *Archive {
+	a, err := Open(Options{Dir: dir, MaxBody: 1 << 20, MaxBytes: 1 << 30})
+	assert.NoError(t, err)
+	t.Cleanup(a.Close)
+	return a
+}
+
+func read(t *testing.T, a *Archive, hash string) string {
+	stored, err := a.Open(hash)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer stored.Close()
+	body, err := gzip.NewReader(stored)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(body)
+	return string(data)
+}
+
+func TestStore(t *testing.T) {
+	a := open(t, t.TempDir())
+	const url = "http://archive.example/"
+
+	assert.NoError(t, a.Store(url, []byte("first"), "text/html"))
+	first := a.Versions(url)[0]
+	assert.Equal(t, int64(5), first.Size)
+	assert.Equal(t, "text/html", first.ContentType)
+	assert.Equal(t, "first", read(t, a, first.Hash))
+
+	// The same body again only moves LastSeen.
+	assert.NoError(t, a.Store(url, []byte("first"), "text/html"))
+	assert.Len(t, a.Versions(url), 1)
+	assert.Equal(t, first.FirstSeen, a.Versions(url)[0].FirstSeen)
+
+	assert.NoError(t, a.Store(url, []byte("second"), "text/plain"))
+	versions := a.Versions(url)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "second", read(t, a, versions[0].Hash))
+
+	// Another URL serving the same body shares its stored copy.
+	_, stored := a.Usage()
+	assert.NoError(t, a.Store("http://mirror.example/", []byte("first"), "text/html"))
+	count, after := a.Usage()
+	assert.Equal(t, 3, count)
+	assert.Equal(t, stored, after)
+
+	assert.NoError(t, a.Store(url, []byte(strings.Repeat("x", 2<<20)), "text/html"))
+	assert.Len(t, a.Versions(url), 2)
+
+	_, err := a.Open(strings.Repeat("0", 64))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Empty(t, a.Versions("http://never-fetched.example/"))
+}
+
+func TestRetention(t *testing.T) {
+	a := open(t, t.TempDir())
+	a.Store("http://old.example/", []byte("old"), "")
+	a.Store("http://new.example/", []byte("new"), "")
+	old := a.Versions("http://old.example/")[0].Hash
+	a.mutex.Lock()
+	a.versions["http://old.example/"][0].LastSeen = time.Now().Add(-48 * time.Hour)
+	a.mutex.Unlock()
+
+	a.SetLimits(Options{MaxBody: 1 << 20, MaxAge: 24 * time.Hour, MaxBytes: 1 << 30})
+	assert.Empty(t, a.Versions("http://old.example/"))
+	assert.Len(t, a.Versions("http://new.example/"), 1)
+	_, err := a.Open(old)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Over the budget the least recently seen versions go first; storing
+	// an unchanged body counts as seeing it.
+	a.Store("http://newer.example/", []byte("newer"), "")
+	a.Store("http://new.example/", []byte("new"), "")
+	_, stored := a.Usage()
+	a.SetLimits(Options{MaxBody: 1 << 20, MaxBytes: stored - 1})
+	assert.Empty(t, a.Versions("http://newer.example/"))
+	assert.Len(t, a.Versions("http://new.example/"), 1)
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	a := open(t, dir)
+	a.Store("http://archive.example/", []byte("kept"), "text/html")
+	hash := a.Versions("http://archive.example/")[0].Hash
+
+	// The index is saved on a timer, not on every store.
+	index, _ := os.ReadFile(filepath.Join(dir, indexFile))
+	assert.NotContains(t, string(index), "archive.example")
+	a.Flush()
+	index, _ = os.ReadFile(filepath.Join(dir, indexFile))
+	assert.Contains(t, string(index), "archive.example")
+
+	// A body no version refers to, as a crash would leave, is removed.
+	orphan := filepath.Join(dir, blobsDir, "ab", strings.Repeat("ab", 32)+".gz")
+	os.MkdirAll(filepath.Dir(orphan), 0755)
+	os.WriteFile(orphan, []byte("x"), 0644)
+
+	reopened := open(t, dir)
+	assert.Equal(t, a.Versions("http://archive.example/"), reopened.Versions("http://archive.example/"))
+	assert.Equal(t, "kept", read(t, reopened, hash))
+	_, err := os.Stat(orphan)
+	assert.True(t, os.IsNotExist(err))
+}
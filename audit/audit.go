@@ -0,0 +1,193 @@
+// Package audit keeps an append-only record of every change made through
+// the API: submissions, admin actions and configuration reloads. Entries
+// are JSON lines in the audit file, which is rotated by size, and can be
+// read back with Query.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
+)
+
+// Audited action names.
+const (
+	ActionSubmit        = "url.submit"
+	ActionDelete        = "url.delete"
+	ActionReset         = "url.reset"
+	ActionPin           = "url.pin"
+	ActionUnpin         = "url.unpin"
+	ActionExclude       = "url.exclude"
+	ActionInclude       = "url.include"
+	ActionTag           = "url.tag"
+	ActionBulkDelete    = "urls.bulk_delete"
+	ActionConfigReload  = "config.reload"
+	ActionVerdictSet    = "verdict.set"
+	ActionVerdictDelete = "verdict.delete"
+	ActionHashMatch     = "url.hash_match"
+	ActionHashesAdd     = "hashes.add"
+	ActionHashDelete    = "hashes.delete"
+)
+
+// Entry is one audited change. Seq numbers entries in the order they were
+// written, across rotations, so pages can resume exactly where they left
+// off. Before and After hold the values it changed, when there are any.
+type Entry struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	Affected  int       `json:"affected"`
+	DryRun    bool      `json:"dry_run,omitempty"`
+	Before    any       `json:"before,omitempty"`
+	After     any       `json:"after,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Options controls where entries go and when the file rotates. When the
+// file would grow past MaxSize bytes it is renamed to Path.1, older files
+// move up by one and anything beyond MaxBackups is removed.
+type Options struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+}
+
+var (
+	mutex   sync.Mutex
+	options Options
+	file    *os.File
+	size    int64
+	seq     uint64
+)
+
+// Open appends entries to opts.Path from now on, creating it if needed,
+// numbering them on from the last entry written there.
+func Open(opts Options) error {
+	if opts.MaxBackups < 0 {
+		return fmt.Errorf("max backups must not be negative")
+	}
+	f, err := openFile(opts.Path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	last, err := lastSeq(opts)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if file != nil {
+		file.Close()
+	}
+	options, file, size, seq = opts, f, info.Size(), last
+	return nil
+}
+
+func openFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+}
+
+// Close flushes and closes the audit file.
+func Close() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if file == nil {
+		return nil
+	}
+	err := file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	file = nil
+	return err
+}
+
+// Record stamps entry with the time and the request ID carried by ctx and
+// appends it. An entry that cannot be written is logged instead, so it is
+// not lost.
+func Record(ctx context.Context, entry Entry) {
+	entry.Time = time.Now().UTC()
+	if entry.RequestID == "" {
+		entry.RequestID = logger.RequestID(ctx)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	entry.Seq = seq + 1
+	line, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("encoding audit entry", "action", entry.Action, "error", err)
+		return
+	}
+	line = append(line, '\n')
+	seq++
+	if err := write(line); err != nil {
+		logger.FromContext(ctx).Error("writing audit entry", "error", err, "entry", string(line))
+	}
+}
+
+// write must be called with the mutex held.
+func write(line []byte) error {
+	if file == nil {
+		return fmt.Errorf("audit log is not open")
+	}
+	if options.MaxSize > 0 && size > 0 && size+int64(len(line)) > options.MaxSize {
+		if err := rotate(); err != nil {
+			// Keep appending to the current file rather than drop entries.
+			slog.Error("rotating audit log", "error", err)
+		}
+	}
+	n, err := file.Write(line)
+	size += int64(n)
+	return err
+}
+
+// rotate must be called with the mutex held.
+func rotate() error {
+	if err := file.Close(); err != nil {
+		return err
+	}
+	os.Remove(backupName(options.Path, options.MaxBackups))
+	for i := options.MaxBackups - 1; i >= 1; i-- {
+		os.Rename(backupName(options.Path, i), backupName(options.Path, i+1))
+	}
+	var renameErr error
+	if options.MaxBackups > 0 {
+		renameErr = os.Rename(options.Path, backupName(options.Path, 1))
+	} else {
+		renameErr = os.Remove(options.Path)
+	}
+
+	f, err := openFile(options.Path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	file, size = f, info.Size()
+	return renameErr
+}
+
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func open(t *testing.T, opts Options) {
+	assert.NoError(t, Open(opts))
+	t.Cleanup(func() {
+		Close()
+		options = Options{}
+	})
+}
+
+func TestRecordRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	open(t, Options{Path: path, MaxSize: 600, MaxBackups: 2})
+
+	for i := 0; i < 20; i++ {
+		Record(context.Background(), Entry{Actor: "anonymous", Action: ActionSubmit, Target: strings.Repeat("x", 40)})
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		assert.NoError(t, err, name)
+		assert.LessOrEqual(t, info.Size(), int64(600), name)
+	}
+	_, err := os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "only MaxBackups rotated files are kept")
+
+	entries, err := Query(Filter{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, entries)
+	assert.Less(t, len(entries), 20)
+	for i := 1; i < len(entries); i++ {
+		assert.False(t, entries[i].Time.After(entries[i-1].Time), "newest first")
+	}
+}
+
+func TestQueryFilters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	open(t, Options{Path: path, MaxSize: 1 << 20, MaxBackups: 1})
+
+	ctx := logger.WithRequestID(context.Background(), "req-1")
+	Record(ctx, Entry{Actor: "anonymous", Action: ActionSubmit, Target: "http://a.com", Client: "10.0.0.1"})
+	Record(context.Background(), Entry{Actor: "token:ops", Action: ActionDelete, Target: "http://a.com",
+		Before: map[string]int{"count": 1}})
+	middle := time.Now()
+	Record(context.Background(), Entry{Actor: "token:ops", Action: ActionPin, Target: "http://b.com"})
+	Record(context.Background(), Entry{Actor: "system:sighup", Action: ActionConfigReload})
+
+	// A torn line, as left by a crash mid-write, is skipped.
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"time":"2026-`)
+	f.Close()
+
+	count := func(f Filter) int {
+		entries, err := Query(f)
+		assert.NoError(t, err)
+		return len(entries)
+	}
+	assert.Equal(t, 4, count(Filter{}))
+	assert.Equal(t, 2, count(Filter{Actor: "token:ops"}))
+	assert.Equal(t, 3, count(Filter{Action: "url."}))
+	assert.Equal(t, 1, count(Filter{Action: ActionPin}))
+	assert.Equal(t, 2, count(Filter{Target: "http://a.com"}))
+	assert.Equal(t, 1, count(Filter{Client: "10.0.0.1"}))
+	assert.Equal(t, 1, count(Filter{RequestID: "req-1"}))
+	assert.Equal(t, 2, count(Filter{Since: middle}))
+	assert.Equal(t, 2, count(Filter{Until: middle}))
+
+	entries, err := Query(Filter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, ActionConfigReload, entries[0].Action)
+
+	entries, _ = Query(Filter{Action: ActionDelete})
+	assert.Equal(t, map[string]any{"count": float64(1)}, entries[0].Before)
+}
+
+func TestQueryPagesBySeq(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	open(t, Options{Path: path, MaxSize: 600, MaxBackups: 5})
+	for i := 0; i < 10; i++ {
+		Record(context.Background(), Entry{Actor: "anonymous", Action: ActionSubmit, Target: strings.Repeat("x", 40)})
+	}
+
+	// Numbering goes on after a restart.
+	Close()
+	assert.NoError(t, Open(Options{Path: path, MaxSize: 600, MaxBackups: 5}))
+	Record(context.Background(), Entry{Actor: "anonymous", Action: ActionSubmit})
+	_, err := os.Stat(path + ".1")
+	assert.NoError(t, err, "the entries span rotated files")
+
+	// Paging by Seq returns every entry once, whatever their times.
+	var seqs []uint64
+	filter := Filter{Limit: 3}
+	for {
+		entries, err := Query(filter)
+		assert.NoError(t, err)
+		for _, entry := range entries {
+			seqs = append(seqs, entry.Seq)
+		}
+		if len(entries) < filter.Limit {
+			break
+		}
+		filter.Before = entries[len(entries)-1].Seq
+	}
+	assert.Equal(t, []uint64{11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, seqs)
+}
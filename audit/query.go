@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Filter selects entries for Query. Empty fields match everything. Action
+// also matches by prefix when it ends in ".", so "url." finds every URL
+// action.
+type Filter struct {
+	Action    string
+	Actor     string
+	Target    string
+	Client    string
+	RequestID string
+	Since     time.Time
+	Until     time.Time
+	// Before, when set, keeps only entries written before the one with
+	// this Seq; it is how Query pages.
+	Before uint64
+	Limit  int
+}
+
+func (f Filter) matches(e *Entry) bool {
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, ".") {
+			if !strings.HasPrefix(e.Action, f.Action) {
+				return false
+			}
+		} else if e.Action != f.Action {
+			return false
+		}
+	}
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Target == "" || e.Target == f.Target) &&
+		(f.Client == "" || e.Client == f.Client) &&
+		(f.RequestID == "" || e.RequestID == f.RequestID) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Query returns the entries matching f from the audit file and its
+// rotated backups, newest first, at most f.Limit of them when it is set.
+func Query(f Filter) ([]Entry, error) {
+	files, err := openForReading()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	var entries []Entry
+	// files runs newest first; each one is read forwards and its matches
+	// reversed. Sequence numbers only grow through a file, so reading it
+	// stops at the first entry at or past f.Before, and files written
+	// wholly after it are left after their first line.
+	for _, file := range files {
+		var matched []Entry
+		err := scan(file, func(entry *Entry) bool {
+			if f.Before > 0 && entry.Seq >= f.Before {
+				return false
+			}
+			if f.matches(entry) {
+				matched = append(matched, *entry)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		slices.Reverse(matched)
+		entries = append(entries, matched...)
+		if f.Limit > 0 && len(entries) >= f.Limit {
+			return entries[:f.Limit], nil
+		}
+	}
+	return entries, nil
+}
+
+// scan calls fn with each entry in file until it returns false.
+func scan(file *os.File, fn func(*Entry) bool) error {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A torn last line from a crash should not hide the rest.
+			continue
+		}
+		if !fn(&entry) {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+// lastSeq returns the sequence number of the last entry in the files opts
+// names, or 0 when there is none.
+func lastSeq(opts Options) (uint64, error) {
+	for i := 0; i <= opts.MaxBackups; i++ {
+		name := opts.Path
+		if i > 0 {
+			name = backupName(opts.Path, i)
+		}
+		file, err := os.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		var last uint64
+		err = scan(file, func(entry *Entry) bool {
+			last = max(last, entry.Seq)
+			return true
+		})
+		file.Close()
+		if err != nil || last > 0 {
+			return last, err
+		}
+	}
+	return 0, nil
+}
+
+// openForReading opens the audit file and its backups, newest first, under
+// the mutex so that a rotation cannot shift them while they are listed.
+// Open files stay readable after a later rename.
+func openForReading() ([]*os.File, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if options.Path == "" {
+		return nil, nil
+	}
+
+	var files []*os.File
+	for i := 0; i <= options.MaxBackups; i++ {
+		name := options.Path
+		if i > 0 {
+			name = backupName(options.Path, i)
+		}
+		file, err := os.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,157 @@
+// Package certs builds the server TLS configuration: a certificate and key
+// pair, and optionally a CA bundle that client certificates must chain to,
+// all reloaded when their files change on disk.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkInterval limits how often handshakes stat the files for changes.
+const checkInterval = time.Second
+
+// Client authentication modes.
+const (
+	ClientAuthRequest = "request"
+	ClientAuthRequire = "require"
+)
+
+// Options names the files to serve from.
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile, when set, is the PEM bundle client certificates are
+	// verified against.
+	ClientCAFile string
+	// ClientAuth is ClientAuthRequest to verify certificates clients choose
+	// to present, or ClientAuthRequire to reject clients without one.
+	ClientAuth string
+}
+
+// Reloader serves the current certificate and client CA pool, reloading
+// them when their files' modification times change.
+type Reloader struct {
+	options Options
+
+	mutex       sync.Mutex
+	checked     time.Time
+	modTimes    map[string]time.Time
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+// NewReloader loads the files once, failing if any of them is invalid.
+func NewReloader(options Options) (*Reloader, error) {
+	r := &Reloader{options: options, modTimes: make(map[string]time.Time)}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+	return r, nil
+}
+
+// TLSConfig returns a server configuration that picks up reloaded files on
+// each handshake and offers HTTP/2.
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := r.config()
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.maybeReload()
+		return r.config(), nil
+	}
+	return base
+}
+
+func (r *Reloader) config() *tls.Config {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{*r.certificate},
+	}
+	if r.clientCAs != nil {
+		cfg.ClientCAs = r.clientCAs
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if r.options.ClientAuth == ClientAuthRequire {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return cfg
+}
+
+func (r *Reloader) maybeReload() {
+	r.mutex.Lock()
+	if time.Since(r.checked) < checkInterval {
+		r.mutex.Unlock()
+		return
+	}
+	r.checked = time.Now()
+	changed := false
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err == nil && !info.ModTime().Equal(r.modTimes[file]) {
+			changed = true
+		}
+	}
+	r.mutex.Unlock()
+
+	if changed {
+		if err := r.load(); err != nil {
+			slog.Error("reloading TLS files, keeping the previous ones", "error", err)
+			return
+		}
+		slog.Info("reloaded TLS files", "cert", r.options.CertFile)
+	}
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.options.CertFile, r.options.KeyFile}
+	if r.options.ClientCAFile != "" {
+		files = append(files, r.options.ClientCAFile)
+	}
+	return files
+}
+
+func (r *Reloader) load() error {
+	// Record the times before reading so a write racing the read is seen
+	// as a change on the next check.
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.options.CertFile, r.options.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.options.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.options.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("reading client CA bundle: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA bundle %s", r.options.ClientCAFile)
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return nil
+}
@@ -0,0 +1,174 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, _ := x509.ParseCertificate(der)
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for commonName signed by a.
+func (a *authority) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func write(t *testing.T, path string, data []byte) {
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+func TestMutualTLSAndReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	options := Options{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server-key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+		ClientAuth:   ClientAuthRequire,
+	}
+	serverCert, serverKey := ca.issue(t, "server-1", x509.ExtKeyUsageServerAuth)
+	write(t, options.CertFile, serverCert)
+	write(t, options.KeyFile, serverKey)
+	write(t, options.ClientCAFile, ca.pem)
+
+	reloader, err := NewReloader(options)
+	assert.NoError(t, err)
+
+	var seenCN string
+	var seenProto int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenCN = r.TLS.VerifiedChains[0][0].Subject.CommonName
+		seenProto = r.ProtoMajor
+	}))
+	server.TLS = reloader.TLSConfig()
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) (*http.Client, *tls.ConnectionState) {
+		state := &tls.ConnectionState{}
+		return &http.Client{Transport: &http.Transport{
+			ForceAttemptHTTP2: true,
+			TLSClientConfig: &tls.Config{
+				RootCAs:      roots,
+				Certificates: certs,
+				VerifyConnection: func(cs tls.ConnectionState) error {
+					*state = cs
+					return nil
+				},
+			},
+		}}, state
+	}
+
+	anonymous, _ := client()
+	_, err = anonymous.Get(server.URL)
+	assert.Error(t, err, "a client without a certificate must be rejected")
+
+	clientCert, clientKey := ca.issue(t, "client-a", x509.ExtKeyUsageClientAuth)
+	pair, err := tls.X509KeyPair(clientCert, clientKey)
+	assert.NoError(t, err)
+	identified, state := client(pair)
+	resp, err := identified.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "client-a", seenCN)
+	assert.Equal(t, 2, seenProto)
+	assert.Equal(t, "server-1", state.PeerCertificates[0].Subject.CommonName)
+
+	// Replace the server certificate and make the change visible.
+	serverCert, serverKey = ca.issue(t, "server-2", x509.ExtKeyUsageServerAuth)
+	write(t, options.CertFile, serverCert)
+	write(t, options.KeyFile, serverKey)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(options.CertFile, later, later)
+	reloader.mutex.Lock()
+	reloader.checked = time.Time{}
+	reloader.mutex.Unlock()
+
+	fresh, state := client(pair)
+	resp, err = fresh.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "server-2", state.PeerCertificates[0].Subject.CommonName)
+}
+
+func TestReloadKeepsPreviousFilesOnError(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	options := Options{CertFile: filepath.Join(dir, "server.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	cert, key := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	write(t, options.CertFile, cert)
+	write(t, options.KeyFile, key)
+
+	reloader, err := NewReloader(options)
+	assert.NoError(t, err)
+
+	write(t, options.CertFile, []byte("not a certificate"))
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(options.CertFile, later, later)
+	reloader.checked = time.Time{}
+	reloader.maybeReload()
+
+	config := reloader.config()
+	assert.Len(t, config.Certificates, 1)
+	assert.Nil(t, config.ClientCAs)
+
+	_, err = NewReloader(options)
+	assert.Error(t, err)
+}
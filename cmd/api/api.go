@@ -1,33 +1,103 @@
 package api
 
 import (
-	"log"
+	"crypto/tls"
+	"log/slog"
 	"net/http"
 
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/archive"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/feeds"
+	adminHlr "github.com/Dev-AustinPeter/spamhaus-take-home-task/handler/admin"
+	domainHlr "github.com/Dev-AustinPeter/spamhaus-take-home-task/handler/domain"
+	feedsHlr "github.com/Dev-AustinPeter/spamhaus-take-home-task/handler/feeds"
+	healthHlr "github.com/Dev-AustinPeter/spamhaus-take-home-task/handler/health"
+	jobsHlr "github.com/Dev-AustinPeter/spamhaus-take-home-task/handler/jobs"
+	lookupHlr "github.com/Dev-AustinPeter/spamhaus-take-home-task/handler/lookup"
+	snapshotsHlr "github.com/Dev-AustinPeter/spamhaus-take-home-task/handler/snapshots"
 	urlHlr "github.com/Dev-AustinPeter/spamhaus-take-home-task/handler/url"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/hashes"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/rules"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
 	"github.com/gorilla/mux"
 )
 
 type APIServer struct {
-	addr string
+	addr      string
+	queue     *service.Queue
+	verdicts  *service.Verdicts
+	rules     *rules.Engine
+	feeds     *feeds.Generator
+	hashes    *hashes.Set
+	archive   *archive.Archive
+	tlsConfig *tls.Config
 }
 
-func NewAPIServer(addr string) *APIServer {
+// NewAPIServer serves plain HTTP, or HTTPS with HTTP/2 when tlsConfig is
+// not nil.
+func NewAPIServer(addr string, queue *service.Queue, verdicts *service.Verdicts, rules *rules.Engine, feeds *feeds.Generator, hashes *hashes.Set, archive *archive.Archive, tlsConfig *tls.Config) *APIServer {
 	return &APIServer{
-		addr: addr,
+		addr:      addr,
+		queue:     queue,
+		verdicts:  verdicts,
+		rules:     rules,
+		feeds:     feeds,
+		hashes:    hashes,
+		archive:   archive,
+		tlsConfig: tlsConfig,
 	}
 }
 
 func (s *APIServer) Run() error {
+	cfg := config.Envs
+
 	router := mux.NewRouter()
+	router.Use(middleware.RequestID, middleware.Identity, middleware.Tracing, middleware.AccessLog, middleware.Metrics,
+		middleware.BodyLimit(int64(cfg.MaxBodyBytes)))
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	healthHlr.NewHandler().RegisterRoutes(router)
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
 
 	rateLimiter := middleware.NewRateLimiter()
 
-	urlHandler := urlHlr.NewHandler()
+	urlHandler := urlHlr.NewHandler(s.queue)
 	urlHandler.RegisterRoutes(subrouter, rateLimiter)
 
-	log.Println("[INFO]: Listening on port", s.addr)
-	return http.ListenAndServe(s.addr, router)
+	jobsHandler := jobsHlr.NewHandler(s.queue)
+	jobsHandler.RegisterRoutes(subrouter, rateLimiter)
+
+	domainHandler := domainHlr.NewHandler()
+	domainHandler.RegisterRoutes(subrouter, rateLimiter)
+
+	lookupHandler := lookupHlr.NewHandler(s.verdicts)
+	lookupHandler.RegisterRoutes(subrouter, rateLimiter)
+
+	feedsHandler := feedsHlr.NewHandler(s.feeds)
+	feedsHandler.RegisterRoutes(subrouter, rateLimiter)
+
+	snapshotsHandler := snapshotsHlr.NewHandler(s.archive)
+	snapshotsHandler.RegisterRoutes(subrouter, rateLimiter)
+
+	adminHandler := adminHlr.NewHandler(s.verdicts, s.rules, s.hashes)
+	adminHandler.RegisterRoutes(subrouter, rateLimiter)
+
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           router,
+		TLSConfig:         s.tlsConfig,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	if s.tlsConfig != nil {
+		slog.Info("listening", "addr", s.addr, "tls", true)
+		return server.ListenAndServeTLS("", "")
+	}
+	slog.Info("listening", "addr", s.addr)
+	return server.ListenAndServe()
 }
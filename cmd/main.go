@@ -1,38 +1,224 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/archive"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/certs"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/cmd/api"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/dnsbl"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/feeds"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/hashes"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/rules"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/tracing"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
 )
 
 func main() {
 
+	if err := config.Init(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cfg := config.Envs
+
+	if err := logger.Init(cfg.LogLevel, cfg.LogFormat); err != nil {
+		slog.Error("configuring logger", "error", err)
+		os.Exit(1)
+	}
+	if err := tracing.Init(cfg.ServiceName, cfg.TraceExporter, cfg.TraceEndpoint); err != nil {
+		slog.Error("configuring tracing", "error", err)
+		os.Exit(1)
+	}
+	if err := audit.Open(audit.Options{Path: cfg.AuditFile, MaxSize: int64(cfg.AuditMaxSize), MaxBackups: cfg.AuditMaxBackups}); err != nil {
+		slog.Error("opening audit log", "error", err)
+		os.Exit(1)
+	}
+
 	// Load stored data on startup
-	utils.LoadData(constants.DATA_FILE)
+	utils.LoadData(cfg.DataFile)
+	utils.SetMaxDownloads(cfg.MaxDownloads)
+	// Restore queued fetch jobs and start the worker pool
+	queue := service.NewQueue(cfg.JobsFile, utils.FetchURLContext)
+	queue.Load()
+	queue.Start(cfg.FetchWorkers)
+	// Restore analyst and rule verdicts for lookups
+	verdicts := service.NewVerdicts(cfg.VerdictsFile)
+	verdicts.Load()
+	metrics.NewGaugeFunc("verdicts", "Verdicts stored on URLs, hosts and domains.",
+		func() float64 { return float64(verdicts.Count()) })
+	// Regenerate the blocklist feeds whenever verdicts change
+	feedGenerator := feeds.NewGenerator(verdicts)
+	feedGenerator.Start()
+	// Classify URLs by rule whenever a submission or fetch updates them
+	engine := rules.NewEngine(cfg.RulesFile, verdicts)
+	if err := engine.Load(); err != nil {
+		slog.Error("loading rules", "error", err)
+		os.Exit(1)
+	}
+	engine.Start(constants.RULE_WORKERS)
+	utils.OnUpdate(func(ctx context.Context, url string) {
+		engine.Schedule(context.WithoutCancel(ctx), url)
+	})
+	metrics.NewGaugeFunc("rules_pending", "URLs waiting for the rules to be applied.",
+		func() float64 { return float64(engine.Pending()) })
+	// Check fetched bodies against the known-bad hashes
+	hashSet := hashes.NewSet(cfg.HashesFile, verdicts)
+	hashSet.Load()
+	hashSet.Start(constants.HASH_WORKERS)
+	utils.OnUpdate(func(ctx context.Context, url string) {
+		hashSet.Schedule(context.WithoutCancel(ctx), url)
+	})
+	metrics.NewGaugeFunc("hashes_pending", "URLs waiting to be checked against the known-bad hashes.",
+		func() float64 { return float64(hashSet.Pending()) })
+	metrics.NewGaugeFunc("known_hashes", "Known-bad content hashes fetched bodies are checked against.",
+		func() float64 { return float64(hashSet.Count()) })
+
+	// Keep fetched bodies on disk when asked to
+	var snapshotArchive *archive.Archive
+	if cfg.ArchiveDir != "" {
+		var err error
+		snapshotArchive, err = archive.Open(archiveOptions(cfg))
+		if err != nil {
+			slog.Error("opening snapshot archive", "dir", cfg.ArchiveDir, "error", err)
+			os.Exit(1)
+		}
+		utils.OnBody(func(ctx context.Context, url string, body []byte, contentType string) {
+			if err := snapshotArchive.Store(url, body, contentType); err != nil {
+				slog.Error("archiving fetched body", "url", url, "error", err)
+			}
+		})
+		config.OnReload(func(cfg config.Config) {
+			snapshotArchive.SetLimits(archiveOptions(cfg))
+		})
+		metrics.NewGaugeFunc("archive_snapshots", "Fetched body versions kept in the snapshot archive.",
+			func() float64 { versions, _ := snapshotArchive.Usage(); return float64(versions) })
+		metrics.NewGaugeFunc("archive_bytes", "Bytes the compressed bodies in the snapshot archive take on disk.",
+			func() float64 { _, stored := snapshotArchive.Usage(); return float64(stored) })
+	}
+
+	metrics.NewGaugeFunc("fetch_queue_pending", "Fetch jobs waiting for a worker.",
+		func() float64 { return float64(queue.Pending()) })
+	metrics.NewGaugeFunc("fetch_workers", "Fetch queue workers running.",
+		func() float64 { return float64(queue.Workers()) })
+
+	// Serve listed domains over DNS when asked to
+	var dnsblServer *dnsbl.Server
+	if cfg.DNSBLAddr != "" {
+		dnsblServer = dnsbl.NewServer(cfg.DNSBLZone, cfg.DNSBLTTL, verdicts)
+		if err := dnsblServer.Start(cfg.DNSBLAddr); err != nil {
+			slog.Error("starting DNSBL server", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Apply reloaded concurrency limits; the rate limiter and background
+	// fetch read theirs from config.Current.
+	config.OnReload(func(cfg config.Config) {
+		utils.SetMaxDownloads(cfg.MaxDownloads)
+		queue.SetWorkers(cfg.FetchWorkers)
+		if err := engine.Load(); err != nil {
+			slog.Error("reloading rules, keeping the previous ones", "error", err)
+		}
+	})
+	config.OnReload(auditReload(config.Current()))
+	config.WatchSIGHUP()
+
 	// Start background processes
-	go utils.StartBatchSave(constants.DATA_FILE)
-	go service.StartBackgroundFetch()
+	go utils.StartBatchSave(cfg.DataFile, cfg.SnapshotInterval)
+	go service.StartBackgroundFetch(queue)
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigChan
-		log.Println("[INFO] Shutting down, saving data...")
-		utils.SaveData(constants.DATA_FILE)
+		slog.Info("shutting down, saving data")
+		if dnsblServer != nil {
+			dnsblServer.Close()
+		}
+		queue.Close()
+		if snapshotArchive != nil {
+			snapshotArchive.Close()
+		}
+		utils.SaveData(cfg.DataFile)
+		tracing.Shutdown()
+		audit.Close()
 		os.Exit(0)
 	}()
 
-	server := api.NewAPIServer(":" + config.Envs.Port)
+	var tlsConfig *tls.Config
+	if cfg.TLSCertFile != "" {
+		reloader, err := certs.NewReloader(certs.Options{
+			CertFile:     cfg.TLSCertFile,
+			KeyFile:      cfg.TLSKeyFile,
+			ClientCAFile: cfg.TLSClientCAFile,
+			ClientAuth:   cfg.TLSClientAuth,
+		})
+		if err != nil {
+			slog.Error("loading TLS files", "error", err)
+			os.Exit(1)
+		}
+		tlsConfig = reloader.TLSConfig()
+	}
+
+	server := api.NewAPIServer(":"+cfg.Port, queue, verdicts, engine, feedGenerator, hashSet, snapshotArchive, tlsConfig)
 	if err := server.Run(); err != nil {
-		log.Fatalf("[ERROR] Server exited with error: %v", err)
+		slog.Error("server exited", "error", err)
+		os.Exit(1)
+	}
+}
+
+// archiveOptions returns the snapshot archive settings in cfg.
+func archiveOptions(cfg config.Config) archive.Options {
+	return archive.Options{
+		Dir:      cfg.ArchiveDir,
+		MaxBody:  int64(cfg.ArchiveMaxBody),
+		MaxAge:   cfg.ArchiveMaxAge,
+		MaxBytes: int64(cfg.ArchiveMaxBytes),
+	}
+}
+
+// auditReload returns a reload hook that records the settings each reload
+// applied, starting from applied.
+func auditReload(applied config.Config) func(config.Config) {
+	return func(cfg config.Config) {
+		changes := config.Changes(applied, cfg)
+		applied = cfg
+		if len(changes) == 0 {
+			return
+		}
+
+		before, after := map[string]string{}, map[string]string{}
+		for _, change := range changes {
+			before[change.Setting], after[change.Setting] = change.Old, change.New
+			if change.Secret {
+				before[change.Setting], after[change.Setting] = "(secret)", "(secret)"
+			}
+		}
+		audit.Record(context.Background(), audit.Entry{
+			Actor:    "system:sighup",
+			Action:   audit.ActionConfigReload,
+			Affected: len(changes),
+			Before:   before,
+			After:    after,
+		})
 	}
 }
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func restore(t *testing.T) {
+	saved, savedCurrent := Envs, current.Load()
+	t.Cleanup(func() {
+		Envs = saved
+		current.Store(savedCurrent)
+		configPath, flagValues = "", nil
+		hooks = nil
+	})
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", "port: \"9000\"\nfetch_workers: 5\nfetch_interval: 2m\nlog_level: debug\n")
+	t.Setenv("FETCH_WORKERS", "7")
+	t.Setenv("LOG_LEVEL", "warn")
+
+	cfg, err := load(path, map[string]string{"LogLevel": "error"})
+	assert.NoError(t, err)
+	assert.Equal(t, "9000", cfg.Port)
+	assert.Equal(t, 2*time.Minute, cfg.FetchInterval)
+	assert.Equal(t, 7, cfg.FetchWorkers)
+	assert.Equal(t, "error", cfg.LogLevel)
+	assert.Equal(t, defaults().DataFile, cfg.DataFile)
+}
+
+func TestLoadJSONFile(t *testing.T) {
+	path := writeFile(t, "config.json", `{"max_page_size": 20, "rate_limit_interval": "250ms"}`)
+
+	cfg, err := load(path, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, cfg.MaxPageSize)
+	assert.Equal(t, 250*time.Millisecond, cfg.RateLimitInterval)
+}
+
+func TestLoadReportsEveryError(t *testing.T) {
+	path := writeFile(t, "config.yaml", "fetch_workers: 0\n")
+	t.Setenv("PORT", "99999")
+	t.Setenv("FETCH_INTERVAL", "soon")
+
+	_, err := load(path, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "FETCH_INTERVAL: invalid duration")
+	assert.Contains(t, err.Error(), "port must be between 1 and 65535")
+	assert.Contains(t, err.Error(), "fetch_workers must be between 1 and 100")
+
+	_, err = load(writeFile(t, "dnsbl.yaml", "dnsbl_addr: \"5353\"\ndnsbl_zone: bad..zone\n"), nil)
+	assert.ErrorContains(t, err, "dnsbl_addr must be host:port")
+	assert.ErrorContains(t, err, "dnsbl_zone must be a DNS name")
+
+	_, err = load(writeFile(t, "typo.yaml", "fetch_wrokers: 3\n"), nil)
+	assert.ErrorContains(t, err, "fetch_wrokers")
+}
+
+func TestParseFlags(t *testing.T) {
+	path, values, err := parseFlags([]string{"-config", "c.yaml", "-fetch-workers", "4", "-trace-exporter=file"})
+	assert.NoError(t, err)
+	assert.Equal(t, "c.yaml", path)
+	assert.Equal(t, map[string]string{"FetchWorkers": "4", "TraceExporter": "file"}, values)
+
+	_, _, err = parseFlags([]string{"-no-such-flag"})
+	assert.Error(t, err)
+}
+
+func TestReloadAppliesOnlyReloadableSettings(t *testing.T) {
+	restore(t)
+	path := writeFile(t, "config.yaml", "fetch_workers: 2\nport: \"9000\"\n")
+	assert.NoError(t, Init([]string{"-config", path}))
+
+	var notified Config
+	OnReload(func(cfg Config) { notified = cfg })
+
+	assert.NoError(t, os.WriteFile(path, []byte("fetch_workers: 6\nfetch_interval: 5s\nport: \"9001\"\n"), 0644))
+	cfg, err := Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, cfg.FetchWorkers)
+	assert.Equal(t, 5*time.Second, cfg.FetchInterval)
+	assert.Equal(t, "9000", cfg.Port)
+	assert.Equal(t, cfg, Current())
+	assert.Equal(t, cfg, notified)
+
+	assert.NoError(t, os.WriteFile(path, []byte("fetch_workers: 500\n"), 0644))
+	_, err = Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 6, Current().FetchWorkers)
+}
+
+func TestReadinessAgesFollowIntervals(t *testing.T) {
+	cfg := defaults()
+	cfg.FetchInterval = time.Minute
+	assert.Equal(t, 3*time.Minute, cfg.FetcherMaxAge())
+	cfg.ReadyFetcherMaxAge = time.Hour
+	assert.Equal(t, time.Hour, cfg.FetcherMaxAge())
+	assert.Equal(t, 3*cfg.SnapshotInterval, cfg.SnapshotMaxAge())
+}
+
+func TestAdminCredentials(t *testing.T) {
+	cfg := defaults()
+	credentials, err := cfg.AdminCredentials()
+	assert.NoError(t, err)
+	assert.Empty(t, credentials)
+
+	cfg.AdminTokens = " ops:0123456789abcdef , ci:fedcba9876543210 "
+	credentials, err = cfg.AdminCredentials()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"0123456789abcdef": "ops", "fedcba9876543210": "ci"}, credentials)
+	assert.NoError(t, cfg.Validate())
+
+	for _, tokens := range []string{
+		"0123456789abcdef",
+		"ops:short",
+		"ops:0123456789abcdef,ops:fedcba9876543210",
+		"ops:0123456789abcdef,ci:0123456789abcdef",
+	} {
+		cfg.AdminTokens = tokens
+		assert.Error(t, cfg.Validate(), tokens)
+	}
+
+	cfg.AdminClientNames = "admin-a, ,admin-b"
+	assert.Equal(t, []string{"admin-a", "admin-b"}, cfg.AdminClients())
+}
+
+func TestChangesRedactSecrets(t *testing.T) {
+	old := defaults()
+	next := old
+	next.FetchInterval = 2 * time.Minute
+	next.AdminTokens = "ops:0123456789abcdef"
+	next.Port = "9000"
+
+	changes := Changes(old, next)
+	assert.Len(t, changes, 3)
+	bySetting := map[string]Change{}
+	for _, change := range changes {
+		bySetting[change.Setting] = change
+	}
+	assert.Equal(t, "1m0s", bySetting["fetch_interval"].Old)
+	assert.Equal(t, "2m0s", bySetting["fetch_interval"].New)
+	assert.True(t, bySetting["fetch_interval"].Reloadable)
+	assert.False(t, bySetting["port"].Reloadable)
+	assert.True(t, bySetting["admin_tokens"].Secret)
+	assert.Empty(t, bySetting["admin_tokens"].New)
+}
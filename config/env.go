@@ -1,30 +1,180 @@
+// Package config merges settings from defaults, an optional YAML or JSON
+// file, environment variables and command-line flags, in that order of
+// precedence, and reloads the ones that are safe to change on SIGHUP.
 package config
 
 import (
-	"os"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
 )
 
+// Config holds every setting. The yaml tag names the key in the config file
+// and, with underscores turned into dashes, the command-line flag; the env
+// tag names the environment variable. Fields tagged reload can change while
+// the process runs and must be read through Current; fields tagged secret
+// are never logged.
 type Config struct {
-	PublicHost string
-	Port       string
+	PublicHost string `yaml:"public_host" env:"PUBLIC_HOST"`
+	Port       string `yaml:"port" env:"PORT"`
+
+	// TLS is served when TLSCertFile and TLSKeyFile are set. With
+	// TLSClientCAFile, client certificates are verified against that
+	// bundle; TLSClientAuth "require" rejects clients without one and
+	// "request" lets them through unidentified.
+	TLSCertFile     string `yaml:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile      string `yaml:"tls_key_file" env:"TLS_KEY_FILE"`
+	TLSClientCAFile string `yaml:"tls_client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+	TLSClientAuth   string `yaml:"tls_client_auth" env:"TLS_CLIENT_AUTH"`
+
+	// Limits on the HTTP server. WriteTimeout must outlast the longest
+	// /jobs/{id}?wait= long poll.
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"MAX_HEADER_BYTES"`
+	MaxBodyBytes      int           `yaml:"max_body_bytes" env:"MAX_BODY_BYTES"`
+
+	DataFile     string `yaml:"data_file" env:"DATA_FILE"`
+	JobsFile     string `yaml:"jobs_file" env:"JOBS_FILE"`
+	VerdictsFile string `yaml:"verdicts_file" env:"VERDICTS_FILE"`
+	// RulesFile holds the classification rules; it is reloaded when it
+	// changes and on SIGHUP, and a missing file means no rules.
+	RulesFile string `yaml:"rules_file" env:"RULES_FILE"`
+	// HashesFile holds the known-bad content hashes. A fetched body whose
+	// fuzzy hash scores at least HashMatchThreshold (1-100) against one of
+	// them matches it.
+	HashesFile         string `yaml:"hashes_file" env:"HASHES_FILE"`
+	HashMatchThreshold int    `yaml:"hash_match_threshold" env:"HASH_MATCH_THRESHOLD" reload:"true"`
+
+	LogLevel  string `yaml:"log_level" env:"LOG_LEVEL"`
+	LogFormat string `yaml:"log_format" env:"LOG_FORMAT"`
+
+	// RateLimitInterval is the shortest gap allowed between two API
+	// requests from one client.
+	RateLimitInterval time.Duration `yaml:"rate_limit_interval" env:"RATE_LIMIT_INTERVAL" reload:"true"`
+
+	// FetchInterval is how often the background fetch queues URLs.
+	// FetchWorkers is how many queued jobs run at once and MaxDownloads
+	// how many downloads may be in flight across all callers.
+	FetchInterval time.Duration `yaml:"fetch_interval" env:"FETCH_INTERVAL" reload:"true"`
+	FetchWorkers  int           `yaml:"fetch_workers" env:"FETCH_WORKERS" reload:"true"`
+	MaxDownloads  int           `yaml:"max_downloads" env:"MAX_DOWNLOADS" reload:"true"`
+
+	SnapshotInterval time.Duration `yaml:"snapshot_interval" env:"SNAPSHOT_INTERVAL"`
+	MaxPageSize      int           `yaml:"max_page_size" env:"MAX_PAGE_SIZE"`
+
+	// Readiness fails when no snapshot has succeeded, or the fetch loop has
+	// not ticked, for longer than these. Zero means three intervals.
+	ReadySnapshotMaxAge time.Duration `yaml:"ready_snapshot_max_age" env:"READY_SNAPSHOT_MAX_AGE"`
+	ReadyFetcherMaxAge  time.Duration `yaml:"ready_fetcher_max_age" env:"READY_FETCHER_MAX_AGE"`
+
+	// FetchSelection picks which URLs the background fetch queues: the
+	// most submitted of all time or the most submitted in TrendingWindow.
+	FetchSelection string        `yaml:"fetch_selection" env:"FETCH_SELECTION"`
+	TrendingWindow time.Duration `yaml:"trending_window" env:"TRENDING_WINDOW"`
+
+	// TraceExporter is "none", "file" or "otlp"; TraceEndpoint is the file
+	// spans are appended to or the OTLP/HTTP collector URL.
+	TraceExporter string `yaml:"trace_exporter" env:"TRACE_EXPORTER"`
+	TraceEndpoint string `yaml:"trace_endpoint" env:"TRACE_ENDPOINT"`
+	ServiceName   string `yaml:"service_name" env:"SERVICE_NAME"`
+
+	// AdminTokens is a comma-separated list of name:token pairs accepted
+	// as bearer tokens on /api/v1/admin and /api/v1/audit; the name is
+	// recorded as the actor in the audit log. AdminClientNames lists the
+	// common names of client certificates, verified over mutual TLS, that
+	// are let in without a token. With neither set both refuse every
+	// request.
+	AdminTokens      string `yaml:"admin_tokens" env:"ADMIN_TOKENS" reload:"true" secret:"true"`
+	AdminClientNames string `yaml:"admin_client_names" env:"ADMIN_CLIENT_NAMES" reload:"true"`
+
+	// DNSBLAddr, when set, serves listed domains as the DNS zone
+	// DNSBLZone over UDP and TCP; answers may be cached for DNSBLTTL.
+	DNSBLAddr string        `yaml:"dnsbl_addr" env:"DNSBL_ADDR"`
+	DNSBLZone string        `yaml:"dnsbl_zone" env:"DNSBL_ZONE"`
+	DNSBLTTL  time.Duration `yaml:"dnsbl_ttl" env:"DNSBL_TTL"`
+
+	// ArchiveDir, when set, keeps the body of every full fetch there,
+	// gzipped and stored once per SHA-256. Bodies over ArchiveMaxBody bytes
+	// are not kept. Versions last seen more than ArchiveMaxAge ago are
+	// dropped, unless it is zero, then the oldest until the archive fits in
+	// ArchiveMaxBytes.
+	ArchiveDir      string        `yaml:"archive_dir" env:"ARCHIVE_DIR"`
+	ArchiveMaxBody  int           `yaml:"archive_max_body" env:"ARCHIVE_MAX_BODY" reload:"true"`
+	ArchiveMaxAge   time.Duration `yaml:"archive_max_age" env:"ARCHIVE_MAX_AGE" reload:"true"`
+	ArchiveMaxBytes int           `yaml:"archive_max_bytes" env:"ARCHIVE_MAX_BYTES" reload:"true"`
+
+	// AuditFile is rotated to AuditFile.1 when it would grow past
+	// AuditMaxSize bytes; AuditMaxBackups rotated files are kept.
+	AuditFile       string `yaml:"audit_file" env:"AUDIT_FILE"`
+	AuditMaxSize    int    `yaml:"audit_max_size" env:"AUDIT_MAX_SIZE"`
+	AuditMaxBackups int    `yaml:"audit_max_backups" env:"AUDIT_MAX_BACKUPS"`
 }
 
+// Envs is the configuration loaded at startup. Settings tagged reload may
+// since have changed; read those through Current.
 var Envs = initConfig()
 
 func initConfig() Config {
-	godotenv.Load()
+	// Flags are only known once main calls Init; until then, for tests and
+	// package initialisation, use whatever the file and environment give.
+	cfg, _ := load("", nil)
+	current.Store(&cfg)
+	return cfg
+}
 
+func defaults() Config {
 	return Config{
-		PublicHost: getEnv("PUBLIC_HOST", "http://localhost"),
-		Port:       getEnv("PORT", "8080"),
-	}
-}
+		PublicHost: "http://localhost",
+		Port:       "8080",
+
+		TLSClientAuth: "require",
+
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      2 * constants.MAX_JOB_WAIT * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    64 << 10,
+		MaxBodyBytes:      1 << 20,
+
+		DataFile:     constants.DATA_FILE,
+		JobsFile:     constants.JOBS_FILE,
+		VerdictsFile: constants.VERDICTS_FILE,
+		RulesFile:    constants.RULES_FILE,
+		HashesFile:   constants.HASHES_FILE,
+
+		HashMatchThreshold: 80,
+
+		LogLevel:  "info",
+		LogFormat: "json",
+
+		RateLimitInterval: time.Second,
+
+		FetchInterval: constants.FETCH_INTERVAL * time.Second,
+		FetchWorkers:  constants.MAX_DOWNLOADS,
+		MaxDownloads:  constants.MAX_DOWNLOADS,
+
+		SnapshotInterval: constants.BATCH_SAVE_INTERVAL * time.Second,
+		MaxPageSize:      constants.MAX_PAGE_SIZE,
+
+		FetchSelection: constants.FETCH_BY_COUNT,
+		TrendingWindow: time.Hour,
+
+		TraceExporter: "none",
+		TraceEndpoint: constants.TRACES_FILE,
+		ServiceName:   "spamhaus-take-home-task",
+
+		DNSBLZone: "zone.local",
+		DNSBLTTL:  5 * time.Minute,
+
+		ArchiveMaxBody:  1 << 20,
+		ArchiveMaxAge:   30 * 24 * time.Hour,
+		ArchiveMaxBytes: 1 << 30,
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+		AuditFile:       constants.AUDIT_FILE,
+		AuditMaxSize:    10 << 20,
+		AuditMaxBackups: 5,
 	}
-	return fallback
 }
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// load builds a Config from defaults, then the file at path (or the one
+// named by CONFIG_FILE when path is empty), then the environment, then
+// flags, which maps field names to raw flag values. It returns the merged
+// Config along with every error found, so one run reports all of them.
+func load(path string, flags map[string]string) (Config, error) {
+	godotenv.Load()
+
+	cfg := defaults()
+	var errs []error
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	v := reflect.ValueOf(&cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if raw, ok := os.LookupEnv(field.Tag.Get("env")); ok {
+			if err := setField(v.Field(i), raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.Tag.Get("env"), err))
+			}
+		}
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if raw, ok := flags[field.Name]; ok {
+			if err := setField(v.Field(i), raw); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", flagName(field), err))
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// loadFile overlays the settings in a YAML or JSON file onto cfg. JSON is
+// read by the YAML decoder, which accepts it as a subset. Unknown keys are
+// errors so typos do not go unnoticed.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func setField(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func flagName(field reflect.StructField) string {
+	return strings.ReplaceAll(field.Tag.Get("yaml"), "_", "-")
+}
+
+// parseFlags reads command-line flags: -config and one per setting. It
+// returns the config file path and the raw values of the flags given.
+func parseFlags(args []string) (string, map[string]string, error) {
+	fs := flag.NewFlagSet("spamhaus-take-home-task", flag.ContinueOnError)
+	path := fs.String("config", "", "YAML or JSON config `file` (default $CONFIG_FILE)")
+
+	values := make(map[string]string)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fs.Func(flagName(field), "overrides $"+field.Tag.Get("env"), func(raw string) error {
+			values[field.Name] = raw
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	if fs.NArg() > 0 {
+		return "", nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return *path, values, nil
+}
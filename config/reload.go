@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+var (
+	current atomic.Pointer[Config]
+
+	sourceMutex sync.Mutex
+	configPath  string
+	flagValues  map[string]string
+
+	hooksMutex sync.Mutex
+	hooks      []func(Config)
+)
+
+// Init loads the configuration with command-line args applied on top and
+// fails with every invalid setting listed.
+func Init(args []string) error {
+	path, flags, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+	cfg, err := load(path, flags)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	sourceMutex.Lock()
+	configPath, flagValues = path, flags
+	sourceMutex.Unlock()
+
+	Envs = cfg
+	current.Store(&cfg)
+	return nil
+}
+
+// Current returns the live configuration, including reloaded settings.
+func Current() Config {
+	return *current.Load()
+}
+
+// OnReload registers fn to be called with the new configuration after each
+// successful reload.
+func OnReload(fn func(Config)) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	hooks = append(hooks, fn)
+}
+
+// Reload reads the file, environment and flags again and applies the
+// settings tagged reload. Other changes are reported and left for the next
+// restart. An invalid configuration is rejected as a whole.
+func Reload() (Config, error) {
+	sourceMutex.Lock()
+	fresh, err := load(configPath, flagValues)
+	sourceMutex.Unlock()
+	if err != nil {
+		return Current(), fmt.Errorf("invalid configuration, keeping the current one:\n%w", err)
+	}
+
+	next := Current()
+	v := reflect.ValueOf(&next).Elem()
+	f := reflect.ValueOf(fresh)
+	for _, change := range Changes(next, fresh) {
+		if !change.Reloadable {
+			slog.Warn("config change needs a restart", "setting", change.Setting)
+			continue
+		}
+		if change.Secret {
+			slog.Info("config setting reloaded", "setting", change.Setting)
+		} else {
+			slog.Info("config setting reloaded", "setting", change.Setting, "old", change.Old, "new", change.New)
+		}
+		v.Field(change.field).Set(f.Field(change.field))
+	}
+	current.Store(&next)
+
+	hooksMutex.Lock()
+	registered := slices.Clone(hooks)
+	hooksMutex.Unlock()
+	for _, fn := range registered {
+		fn(next)
+	}
+	return next, nil
+}
+
+// WatchSIGHUP reloads the configuration each time the process receives
+// SIGHUP.
+func WatchSIGHUP() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			slog.Info("SIGHUP received, reloading configuration")
+			if _, err := Reload(); err != nil {
+				slog.Error("reloading configuration", "error", err)
+			}
+		}
+	}()
+}
+
+// Change is one setting that differs between two configurations, with
+// its values as they would be written in the file. Old and New are left
+// empty for secret settings.
+type Change struct {
+	Setting    string `json:"setting"`
+	Old        string `json:"old,omitempty"`
+	New        string `json:"new,omitempty"`
+	Reloadable bool   `json:"-"`
+	Secret     bool   `json:"-"`
+
+	field int
+}
+
+// Changes lists the settings that differ between old and new.
+func Changes(old, new Config) []Change {
+	var changes []Change
+	o, n := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < o.NumField(); i++ {
+		if reflect.DeepEqual(o.Field(i).Interface(), n.Field(i).Interface()) {
+			continue
+		}
+		field := o.Type().Field(i)
+		change := Change{
+			Setting:    field.Tag.Get("yaml"),
+			Reloadable: field.Tag.Get("reload") == "true",
+			Secret:     field.Tag.Get("secret") == "true",
+			field:      i,
+		}
+		if !change.Secret {
+			change.Old, change.New = fmt.Sprint(o.Field(i).Interface()), fmt.Sprint(n.Field(i).Interface())
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
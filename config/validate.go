@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+)
+
+// Validate reports every setting that is out of range.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port >= 1 && port <= 65535, "port must be between 1 and 65535, got %q", c.Port)
+	check((c.TLSCertFile == "") == (c.TLSKeyFile == ""), "tls_cert_file and tls_key_file must be set together")
+	check(c.TLSClientCAFile == "" || c.TLSCertFile != "", "tls_client_ca_file needs tls_cert_file and tls_key_file")
+	check(oneOf(c.TLSClientAuth, "request", "require"), "tls_client_auth must be request or require, got %q", c.TLSClientAuth)
+	check(c.ReadHeaderTimeout >= 100*time.Millisecond && c.ReadHeaderTimeout <= time.Minute, "read_header_timeout must be between 100ms and 1m, got %s", c.ReadHeaderTimeout)
+	check(c.ReadTimeout >= c.ReadHeaderTimeout && c.ReadTimeout <= 10*time.Minute, "read_timeout must be between read_header_timeout and 10m, got %s", c.ReadTimeout)
+	check(c.WriteTimeout > constants.MAX_JOB_WAIT*time.Second && c.WriteTimeout <= 10*time.Minute,
+		"write_timeout must be over %ds, the longest job wait, and at most 10m, got %s", constants.MAX_JOB_WAIT, c.WriteTimeout)
+	check(c.IdleTimeout >= time.Second && c.IdleTimeout <= time.Hour, "idle_timeout must be between 1s and 1h, got %s", c.IdleTimeout)
+	check(c.MaxHeaderBytes >= 4<<10 && c.MaxHeaderBytes <= 1<<20, "max_header_bytes must be between 4096 and 1048576, got %d", c.MaxHeaderBytes)
+	check(c.MaxBodyBytes >= 1<<10 && c.MaxBodyBytes <= 64<<20, "max_body_bytes must be between 1024 and 67108864, got %d", c.MaxBodyBytes)
+	check(c.DataFile != "", "data_file must not be empty")
+	check(c.JobsFile != "", "jobs_file must not be empty")
+	check(c.VerdictsFile != "", "verdicts_file must not be empty")
+	check(c.RulesFile != "", "rules_file must not be empty")
+	check(c.HashesFile != "", "hashes_file must not be empty")
+	check(c.HashMatchThreshold >= 1 && c.HashMatchThreshold <= 100, "hash_match_threshold must be between 1 and 100, got %d", c.HashMatchThreshold)
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.LogLevel)) == nil, "log_level must be debug, info, warn or error, got %q", c.LogLevel)
+	check(oneOf(strings.ToLower(c.LogFormat), "json", "text"), "log_format must be json or text, got %q", c.LogFormat)
+
+	check(c.RateLimitInterval >= 0 && c.RateLimitInterval <= time.Hour, "rate_limit_interval must be between 0s and 1h, got %s", c.RateLimitInterval)
+	check(c.FetchInterval >= time.Second && c.FetchInterval <= 24*time.Hour, "fetch_interval must be between 1s and 24h, got %s", c.FetchInterval)
+	check(c.FetchWorkers >= 1 && c.FetchWorkers <= 100, "fetch_workers must be between 1 and 100, got %d", c.FetchWorkers)
+	check(c.MaxDownloads >= 1 && c.MaxDownloads <= 100, "max_downloads must be between 1 and 100, got %d", c.MaxDownloads)
+	check(c.SnapshotInterval >= time.Second && c.SnapshotInterval <= 24*time.Hour, "snapshot_interval must be between 1s and 24h, got %s", c.SnapshotInterval)
+	check(c.MaxPageSize >= 1 && c.MaxPageSize <= 1000, "max_page_size must be between 1 and 1000, got %d", c.MaxPageSize)
+	check(c.ReadySnapshotMaxAge >= 0, "ready_snapshot_max_age must not be negative")
+	check(c.ReadyFetcherMaxAge >= 0, "ready_fetcher_max_age must not be negative")
+
+	check(oneOf(c.FetchSelection, constants.FETCH_BY_COUNT, constants.FETCH_BY_TRENDING),
+		"fetch_selection must be %s or %s, got %q", constants.FETCH_BY_COUNT, constants.FETCH_BY_TRENDING, c.FetchSelection)
+	check(c.TrendingWindow >= time.Minute && c.TrendingWindow <= utils.MaxTrendingWindow,
+		"trending_window must be between 1m and %s, got %s", utils.MaxTrendingWindow, c.TrendingWindow)
+
+	check(oneOf(c.TraceExporter, "none", "file", "otlp"), "trace_exporter must be none, file or otlp, got %q", c.TraceExporter)
+	if c.TraceExporter == "otlp" {
+		u, err := url.Parse(c.TraceEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"trace_endpoint must be an http(s) URL when trace_exporter is otlp, got %q", c.TraceEndpoint)
+	}
+
+	_, err = c.AdminCredentials()
+	check(err == nil, "admin_tokens: %v", err)
+	if c.DNSBLAddr != "" {
+		_, _, err := net.SplitHostPort(c.DNSBLAddr)
+		check(err == nil, "dnsbl_addr must be host:port, got %q", c.DNSBLAddr)
+	}
+	check(validZone(c.DNSBLZone), "dnsbl_zone must be a DNS name, got %q", c.DNSBLZone)
+	check(c.DNSBLTTL >= 0 && c.DNSBLTTL <= 24*time.Hour, "dnsbl_ttl must be between 0s and 24h, got %s", c.DNSBLTTL)
+	check(c.ArchiveMaxBody >= 1<<10 && c.ArchiveMaxBody <= constants.MAX_FETCH_BODY,
+		"archive_max_body must be between 1024 and %d, got %d", constants.MAX_FETCH_BODY, c.ArchiveMaxBody)
+	check(c.ArchiveMaxAge >= 0, "archive_max_age must not be negative")
+	check(c.ArchiveMaxBytes >= c.ArchiveMaxBody, "archive_max_bytes must be at least archive_max_body, got %d", c.ArchiveMaxBytes)
+	check(c.AuditFile != "", "audit_file must not be empty")
+	check(c.AuditMaxSize >= 64<<10, "audit_max_size must be at least 65536, got %d", c.AuditMaxSize)
+	check(c.AuditMaxBackups >= 0 && c.AuditMaxBackups <= 100, "audit_max_backups must be between 0 and 100, got %d", c.AuditMaxBackups)
+
+	return errors.Join(errs...)
+}
+
+// validZone reports whether zone is a DNS name of host name labels.
+func validZone(zone string) bool {
+	zone = strings.TrimSuffix(zone, ".")
+	if zone == "" || len(zone) > 200 {
+		return false
+	}
+	for _, label := range strings.Split(zone, ".") {
+		if label == "" || len(label) > 63 || strings.Trim(label, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// SnapshotMaxAge is ReadySnapshotMaxAge, or three snapshot intervals when
+// that is unset.
+func (c Config) SnapshotMaxAge() time.Duration {
+	if c.ReadySnapshotMaxAge > 0 {
+		return c.ReadySnapshotMaxAge
+	}
+	return 3 * c.SnapshotInterval
+}
+
+// FetcherMaxAge is ReadyFetcherMaxAge, or three fetch intervals when that
+// is unset.
+func (c Config) FetcherMaxAge() time.Duration {
+	if c.ReadyFetcherMaxAge > 0 {
+		return c.ReadyFetcherMaxAge
+	}
+	return 3 * c.FetchInterval
+}
+
+// minAdminTokenLength keeps admin tokens out of guessing range.
+const minAdminTokenLength = 16
+
+// AdminCredentials parses AdminTokens into a map from token to name.
+func (c Config) AdminCredentials() (map[string]string, error) {
+	credentials := map[string]string{}
+	names := map[string]bool{}
+	for _, entry := range splitList(c.AdminTokens) {
+		name, token, ok := strings.Cut(entry, ":")
+		name, token = strings.TrimSpace(name), strings.TrimSpace(token)
+		switch {
+		case !ok || name == "":
+			return nil, fmt.Errorf("entries must be name:token")
+		case len(token) < minAdminTokenLength:
+			return nil, fmt.Errorf("token for %q must be at least %d characters", name, minAdminTokenLength)
+		case names[name]:
+			return nil, fmt.Errorf("name %q is listed twice", name)
+		case credentials[token] != "":
+			return nil, fmt.Errorf("token for %q is also used by %q", name, credentials[token])
+		}
+		names[name] = true
+		credentials[token] = name
+	}
+	return credentials, nil
+}
+
+// AdminClients returns the certificate common names allowed on the admin
+// API.
+func (c Config) AdminClients() []string {
+	return splitList(c.AdminClientNames)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
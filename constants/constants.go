@@ -2,8 +2,31 @@ package constants
 
 const (
 	DATA_FILE           = "data.json"
-	RATE_LIMIT          = 5   // Maximum requests per IP per minute
-	MAX_DOWNLOADS       = 3   // Max concurrent downloads
-	FETCH_INTERVAL      = 60  // Seconds between background fetch runs
-	BATCH_SAVE_INTERVAL = 300 // Save data every 5 minutes
+	TRACES_FILE         = "traces.jsonl"
+	AUDIT_FILE          = "audit.log"
+	JOBS_FILE           = "jobs.json"
+	VERDICTS_FILE       = "verdicts.json"
+	RULES_FILE          = "rules.yaml"
+	HASHES_FILE         = "hashes.json"
+	MAX_DOWNLOADS       = 3        // Max concurrent downloads
+	FETCH_INTERVAL      = 60       // Seconds between background fetch runs
+	BATCH_SAVE_INTERVAL = 300      // Save data every 5 minutes
+	TOP_URLS            = 10       // URLs scheduled per background fetch run
+	JOB_HISTORY         = 100      // Finished jobs kept for GET /jobs/{id}
+	MAX_JOB_WAIT        = 30       // Longest ?wait= long-poll in seconds
+	MAX_PAGE_SIZE       = 50       // Default cap on URLs returned per page
+	TIMING_SAMPLES      = 20       // Fetch timings kept per URL for percentiles
+	MAX_FETCH_BODY      = 10 << 20 // Bytes of a response body read before giving up
+	MAX_SOURCES_PER_URL = 64       // Distinct sources tracked per URL
+	MAX_TAGS_PER_URL    = 128      // Distinct tags counted per URL
+	MAX_SUBMISSION_TAGS = 20       // Tags accepted on one submission
+	VERDICT_HISTORY     = 10000    // Verdict changes kept for feed diffs
+	MAX_KNOWN_HASHES    = 10000    // Known-bad content hashes compared on every fetch
+	MAX_SNAPSHOTS       = 100      // Archived body versions kept per URL
+	ARCHIVE_SAVE_EVERY  = 30       // Seconds between saves of a changed archive index
+	RULE_WORKERS        = 4        // URLs the rules are applied to at once
+	HASH_WORKERS        = 2        // URLs checked against the known-bad hashes at once
+
+	FETCH_BY_COUNT    = "count"    // Background fetch picks the most submitted URLs
+	FETCH_BY_TRENDING = "trending" // Background fetch picks the fastest rising URLs
 )
@@ -0,0 +1,190 @@
+package dnsbl
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// Just enough of the RFC 1035 wire format to read a question and answer it
+// with A, TXT and SOA records.
+
+const (
+	typeA   uint16 = 1
+	typeSOA uint16 = 6
+	typeTXT uint16 = 16
+	typeANY uint16 = 255
+
+	classINET uint16 = 1
+	classANY  uint16 = 255
+
+	rcodeSuccess        = 0
+	rcodeFormatError    = 1
+	rcodeNameError      = 3
+	rcodeNotImplemented = 4
+	rcodeRefused        = 5
+
+	flagResponse      = 1 << 15
+	flagAuthoritative = 1 << 10
+	flagTruncated     = 1 << 9
+	flagRecursion     = 1 << 8
+	opcodeMask        = 0xf << 11
+
+	headerLength = 12
+	// maxUDPLength is the largest reply sent over UDP; longer ones are
+	// truncated so the client retries over TCP.
+	maxUDPLength = 512
+)
+
+var errMalformed = errors.New("malformed DNS message")
+
+type question struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+type record struct {
+	name  string
+	rtype uint16
+	ttl   uint32
+	data  []byte
+}
+
+type message struct {
+	id        uint16
+	flags     uint16
+	question  *question
+	answers   []record
+	authority []record
+}
+
+// parseQuery reads the header and the single question of a query. The id
+// and flags are returned even when the question is malformed, so the error
+// can be answered.
+func parseQuery(buf []byte) (message, error) {
+	if len(buf) < headerLength {
+		return message{}, errMalformed
+	}
+	query := message{
+		id:    binary.BigEndian.Uint16(buf[0:2]),
+		flags: binary.BigEndian.Uint16(buf[2:4]),
+	}
+	if query.flags&flagResponse != 0 || binary.BigEndian.Uint16(buf[4:6]) != 1 {
+		return query, errMalformed
+	}
+
+	name, offset, err := readName(buf, headerLength)
+	if err != nil || offset+4 > len(buf) {
+		return query, errMalformed
+	}
+	query.question = &question{
+		name:   name,
+		qtype:  binary.BigEndian.Uint16(buf[offset : offset+2]),
+		qclass: binary.BigEndian.Uint16(buf[offset+2 : offset+4]),
+	}
+	return query, nil
+}
+
+// readName reads the name at offset, following compression pointers, and
+// returns it without the trailing dot along with the offset just past it.
+func readName(buf []byte, offset int) (string, int, error) {
+	var labels []string
+	end, length := -1, 0
+	for jumps := 0; ; {
+		if offset >= len(buf) {
+			return "", 0, errMalformed
+		}
+		n := int(buf[offset])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case n&0xc0 == 0xc0:
+			if offset+1 >= len(buf) || jumps > 10 {
+				return "", 0, errMalformed
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(buf[offset:offset+2]) & 0x3fff)
+			jumps++
+		case n > 63:
+			return "", 0, errMalformed
+		default:
+			if offset+1+n > len(buf) {
+				return "", 0, errMalformed
+			}
+			if length += n + 1; length > 255 {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, string(buf[offset+1:offset+1+n]))
+			offset += 1 + n
+		}
+	}
+}
+
+// pack encodes m without name compression.
+func (m message) pack() []byte {
+	buf := make([]byte, headerLength, maxUDPLength)
+	binary.BigEndian.PutUint16(buf[0:2], m.id)
+	binary.BigEndian.PutUint16(buf[2:4], m.flags)
+	if m.question != nil {
+		binary.BigEndian.PutUint16(buf[4:6], 1)
+		buf = appendName(buf, m.question.name)
+		buf = binary.BigEndian.AppendUint16(buf, m.question.qtype)
+		buf = binary.BigEndian.AppendUint16(buf, m.question.qclass)
+	}
+	binary.BigEndian.PutUint16(buf[6:8], uint16(len(m.answers)))
+	binary.BigEndian.PutUint16(buf[8:10], uint16(len(m.authority)))
+	for _, rr := range append(m.answers, m.authority...) {
+		buf = appendName(buf, rr.name)
+		buf = binary.BigEndian.AppendUint16(buf, rr.rtype)
+		buf = binary.BigEndian.AppendUint16(buf, classINET)
+		buf = binary.BigEndian.AppendUint32(buf, rr.ttl)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(rr.data)))
+		buf = append(buf, rr.data...)
+	}
+	return buf
+}
+
+// truncated is m with only its question and the truncation flag set.
+func (m message) truncated() message {
+	return message{id: m.id, flags: m.flags | flagTruncated, question: m.question}
+}
+
+func appendName(buf []byte, name string) []byte {
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			buf = append(buf, byte(len(label)))
+			buf = append(buf, label...)
+		}
+	}
+	return append(buf, 0)
+}
+
+// txtData encodes text as TXT character-strings of at most 255 bytes.
+func txtData(text string) []byte {
+	var data []byte
+	for {
+		chunk := text[:min(len(text), 255)]
+		data = append(data, byte(len(chunk)))
+		data = append(data, chunk...)
+		if text = text[len(chunk):]; text == "" {
+			return data
+		}
+	}
+}
+
+// soaData encodes an SOA record for zone. minimum is how long resolvers
+// may cache a negative answer.
+func soaData(zone string, serial, minimum uint32) []byte {
+	data := appendName(nil, zone)
+	data = appendName(data, "hostmaster."+zone)
+	for _, value := range []uint32{serial, 3600, 600, 86400, minimum} {
+		data = binary.BigEndian.AppendUint32(data, value)
+	}
+	return data
+}
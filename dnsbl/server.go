@@ -0,0 +1,309 @@
+// Package dnsbl serves verdicts as a DNS zone, so that mail servers can
+// check domains the way they check any RHSBL: a query for
+// example.com.<zone> is answered with a 127.0.0.x address when the
+// domain, or the host's registrable domain, is listed, and with NXDOMAIN
+// when it is not.
+package dnsbl
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+// Return codes for listed names, following RFC 5782.
+var codes = map[string]net.IP{
+	types.VerdictMalicious:  net.IPv4(127, 0, 0, 2),
+	types.VerdictSuspicious: net.IPv4(127, 0, 0, 3),
+}
+
+// testName is always listed, as RFC 5782 asks, so clients can check that
+// the zone answers.
+const testName = "test"
+
+// tcpTimeout bounds how long a TCP connection may sit idle between
+// queries.
+const tcpTimeout = 10 * time.Second
+
+// maxTCPConns caps the TCP connections served at once; further clients
+// wait in the listen backlog.
+const maxTCPConns = 64
+
+type Server struct {
+	zone     string
+	ttl      uint32
+	verdicts *service.Verdicts
+
+	udp net.PacketConn
+	tcp net.Listener
+	wg  sync.WaitGroup
+
+	slots  chan struct{}
+	mutex  sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// NewServer answers queries under zone from verdicts, letting resolvers
+// cache answers for ttl.
+func NewServer(zone string, ttl time.Duration, verdicts *service.Verdicts) *Server {
+	return &Server{
+		zone:     strings.ToLower(strings.Trim(zone, ".")),
+		ttl:      uint32(ttl / time.Second),
+		verdicts: verdicts,
+		slots:    make(chan struct{}, maxTCPConns),
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// Start listens on addr over both TCP and UDP and serves queries until
+// Close.
+func (s *Server) Start(addr string) error {
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	// Bind UDP to the port TCP got, so ":0" gives both the same one.
+	udp, err := net.ListenPacket("udp", tcp.Addr().String())
+	if err != nil {
+		tcp.Close()
+		return err
+	}
+	s.tcp, s.udp = tcp, udp
+
+	s.wg.Add(2)
+	go s.serveUDP()
+	go s.serveTCP()
+	slog.Info("serving DNSBL zone", "zone", s.zone, "address", tcp.Addr().String())
+	return nil
+}
+
+// Addr is the address served over both TCP and UDP.
+func (s *Server) Addr() string {
+	return s.tcp.Addr().String()
+}
+
+// Close stops listening, closes the open TCP connections and waits for
+// everything serving them to return.
+func (s *Server) Close() error {
+	err := errors.Join(s.udp.Close(), s.tcp.Close())
+	s.mutex.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveUDP() {
+	defer s.wg.Done()
+	buf := make([]byte, 4096)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			slog.Warn("reading DNS query", "error", err)
+			continue
+		}
+		// A malformed datagram gets no reply: its source may be spoofed,
+		// and echoing a header back to it would reflect traffic.
+		reply, ok := s.answer(buf[:n])
+		if !ok || reply.flags&0xf == rcodeFormatError {
+			continue
+		}
+		packed := reply.pack()
+		if len(packed) > maxUDPLength {
+			packed = reply.truncated().pack()
+		}
+		if _, err := s.udp.WriteTo(packed, addr); err != nil {
+			slog.Debug("writing DNS reply", "client", addr.String(), "error", err)
+		}
+	}
+}
+
+func (s *Server) serveTCP() {
+	defer s.wg.Done()
+	for {
+		// Take a slot before accepting, so a full server leaves clients
+		// queued rather than holding their connections.
+		s.slots <- struct{}{}
+		conn, err := s.tcp.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			<-s.slots
+			return
+		}
+		if err != nil {
+			<-s.slots
+			slog.Warn("accepting DNS connection", "error", err)
+			continue
+		}
+
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			conn.Close()
+			<-s.slots
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mutex.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn answers length-prefixed queries on conn until the client
+// closes it or goes idle.
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		<-s.slots
+		s.wg.Done()
+	}()
+	for {
+		conn.SetDeadline(time.Now().Add(tcpTimeout))
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		reply, ok := s.answer(query)
+		if !ok {
+			return
+		}
+		packed := reply.pack()
+		framed := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(packed)), uint16(len(packed)))
+		if _, err := conn.Write(append(framed, packed...)); err != nil {
+			return
+		}
+	}
+}
+
+// answer builds the reply to query. It reports false when the query is too
+// short to reply to at all, or is itself a response, which a server must
+// never answer lest two servers reply to each other forever.
+func (s *Server) answer(query []byte) (message, bool) {
+	if len(query) < headerLength || binary.BigEndian.Uint16(query[2:4])&flagResponse != 0 {
+		return message{}, false
+	}
+	parsed, err := parseQuery(query)
+	reply := message{
+		id:       parsed.id,
+		flags:    flagResponse | flagAuthoritative | parsed.flags&(opcodeMask|flagRecursion),
+		question: parsed.question,
+	}
+	qtype := "other"
+	defer func() { metrics.DNSBLQueries.Inc(qtype, rcodeName(reply.flags&0xf)) }()
+
+	switch {
+	case err != nil:
+		reply.flags |= rcodeFormatError
+		return reply, true
+	case parsed.flags&opcodeMask != 0:
+		reply.flags |= rcodeNotImplemented
+		return reply, true
+	}
+
+	q := parsed.question
+	qtype = typeName(q.qtype)
+	name := strings.ToLower(q.name)
+	if (q.qclass != classINET && q.qclass != classANY) || (name != s.zone && !strings.HasSuffix(name, "."+s.zone)) {
+		reply.flags |= rcodeRefused
+		return reply, true
+	}
+	soa := record{name: s.zone, rtype: typeSOA, ttl: s.ttl, data: soaData(s.zone, uint32(time.Now().Unix()), s.ttl)}
+	if name == s.zone {
+		if q.qtype == typeSOA || q.qtype == typeANY {
+			reply.answers = append(reply.answers, soa)
+		} else {
+			reply.authority = append(reply.authority, soa)
+		}
+		return reply, true
+	}
+
+	classification, reasons := s.listing(strings.TrimSuffix(name, "."+s.zone))
+	if classification == "" {
+		reply.flags |= rcodeNameError
+		reply.authority = append(reply.authority, soa)
+		return reply, true
+	}
+	if q.qtype == typeA || q.qtype == typeANY {
+		reply.answers = append(reply.answers, record{name: q.name, rtype: typeA, ttl: s.ttl, data: codes[classification].To4()})
+	}
+	if q.qtype == typeTXT || q.qtype == typeANY {
+		text := classification
+		if len(reasons) > 0 {
+			text += ": " + strings.Join(reasons, "; ")
+		}
+		reply.answers = append(reply.answers, record{name: q.name, rtype: typeTXT, ttl: s.ttl, data: txtData(text)})
+	}
+	if len(reply.answers) == 0 {
+		reply.authority = append(reply.authority, soa)
+	}
+	return reply, true
+}
+
+// listing returns the classification and reasons of the verdict listing
+// name, or an empty classification when it is not listed.
+func (s *Server) listing(name string) (string, []string) {
+	if name == testName {
+		return types.VerdictMalicious, []string{"test entry"}
+	}
+	if !service.ValidHostName(name) {
+		return "", nil
+	}
+	result := s.verdicts.Lookup(name)
+	if !result.Listed {
+		return "", nil
+	}
+	return result.Classification, result.Match.Reasons
+}
+
+func typeName(qtype uint16) string {
+	switch qtype {
+	case typeA:
+		return "A"
+	case typeTXT:
+		return "TXT"
+	case typeSOA:
+		return "SOA"
+	case typeANY:
+		return "ANY"
+	}
+	return "other"
+}
+
+func rcodeName(rcode uint16) string {
+	switch rcode {
+	case rcodeSuccess:
+		return "NOERROR"
+	case rcodeFormatError:
+		return "FORMERR"
+	case rcodeNameError:
+		return "NXDOMAIN"
+	case rcodeNotImplemented:
+		return "NOTIMP"
+	case rcodeRefused:
+		return "REFUSED"
+	}
+	return "other"
+}
@@ -0,0 +1,177 @@
+package dnsbl
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServer(t *testing.T) *Server {
+	verdicts := service.NewVerdicts(filepath.Join(t.TempDir(), "verdicts.json"))
+	verdicts.Set(types.Verdict{Scope: types.ScopeDomain, Key: "evil.example", Classification: types.VerdictMalicious,
+		Reasons: []string{"phishing", "malware"}})
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "odd.example.org", Classification: types.VerdictSuspicious})
+	verdicts.Set(types.Verdict{Scope: types.ScopeDomain, Key: "good.example", Classification: types.VerdictClean})
+
+	server := NewServer("Zone.Local.", time.Minute, verdicts)
+	assert.NoError(t, server.Start("127.0.0.1:0"))
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// resolver sends every query to server over network.
+func resolver(server *Server, network string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server.Addr())
+		},
+	}
+}
+
+func TestServerAnswersOverUDPAndTCP(t *testing.T) {
+	server := newServer(t)
+	ctx := context.Background()
+
+	for _, network := range []string{"udp", "tcp"} {
+		r := resolver(server, network)
+
+		addrs, err := r.LookupHost(ctx, "evil.example.zone.local")
+		assert.NoError(t, err, network)
+		assert.Equal(t, []string{"127.0.0.2"}, addrs, network)
+
+		addrs, err = r.LookupHost(ctx, "WWW.Evil.Example.zone.local")
+		assert.NoError(t, err, network)
+		assert.Equal(t, []string{"127.0.0.2"}, addrs, network)
+
+		txt, err := r.LookupTXT(ctx, "evil.example.zone.local")
+		assert.NoError(t, err, network)
+		assert.Equal(t, []string{"malicious: phishing; malware"}, txt, network)
+
+		addrs, err = r.LookupHost(ctx, "odd.example.org.zone.local")
+		assert.NoError(t, err, network)
+		assert.Equal(t, []string{"127.0.0.3"}, addrs, network)
+
+		addrs, err = r.LookupHost(ctx, "test.zone.local")
+		assert.NoError(t, err, network)
+		assert.Equal(t, []string{"127.0.0.2"}, addrs, network)
+
+		for _, name := range []string{"good.example.zone.local", "other.example.zone.local", "example.org.zone.local"} {
+			_, err = r.LookupHost(ctx, name)
+			var dnsErr *net.DNSError
+			assert.True(t, errors.As(err, &dnsErr) && dnsErr.IsNotFound, "%s over %s: %v", name, network, err)
+		}
+	}
+}
+
+func TestServerCapsTCPConnections(t *testing.T) {
+	server := newServer(t)
+	var idle []net.Conn
+	for range maxTCPConns {
+		conn, err := net.Dial("tcp", server.Addr())
+		assert.NoError(t, err)
+		idle = append(idle, conn)
+	}
+
+	// Every slot is held by an idle client, so a new one is not answered.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := resolver(server, "tcp").LookupHost(ctx, "evil.example.zone.local")
+	assert.Error(t, err)
+
+	idle[0].Close()
+	addrs, err := resolver(server, "tcp").LookupHost(context.Background(), "evil.example.zone.local")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.2"}, addrs)
+
+	// Close ends the idle connections rather than waiting out their timeout.
+	closed := make(chan struct{})
+	go func() {
+		server.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close waited for idle connections")
+	}
+	idle[1].SetReadDeadline(time.Now().Add(time.Second))
+	_, err = idle[1].Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestAnswer(t *testing.T) {
+	server := newServer(t)
+	query := func(name string, qtype uint16) message {
+		packed := message{id: 7, flags: flagRecursion, question: &question{name: name, qtype: qtype, qclass: classINET}}.pack()
+		reply, ok := server.answer(packed)
+		assert.True(t, ok)
+		assert.Equal(t, uint16(7), reply.id)
+		return reply
+	}
+
+	reply := query("evil.example.elsewhere", typeA)
+	assert.Equal(t, uint16(rcodeRefused), reply.flags&0xf)
+	assert.NotZero(t, reply.flags&flagRecursion)
+
+	reply = query("zone.local", typeSOA)
+	assert.Equal(t, uint16(rcodeSuccess), reply.flags&0xf)
+	assert.Len(t, reply.answers, 1)
+
+	reply = query("evil.example.zone.local", typeANY)
+	assert.Len(t, reply.answers, 2)
+
+	// A listed name has no MX: an empty answer, not NXDOMAIN.
+	reply = query("evil.example.zone.local", 15)
+	assert.Equal(t, uint16(rcodeSuccess), reply.flags&0xf)
+	assert.Empty(t, reply.answers)
+	assert.Len(t, reply.authority, 1)
+
+	reply = query("user@evil.example.zone.local", typeA)
+	assert.Equal(t, uint16(rcodeNameError), reply.flags&0xf)
+
+	_, ok := server.answer([]byte{1, 2, 3})
+	assert.False(t, ok)
+	reply, ok = server.answer(make([]byte, headerLength))
+	assert.True(t, ok)
+	assert.Equal(t, uint16(rcodeFormatError), reply.flags&0xf)
+	// A response is never answered, whatever it holds.
+	response := make([]byte, headerLength)
+	binary.BigEndian.PutUint16(response[2:4], flagResponse)
+	_, ok = server.answer(response)
+	assert.False(t, ok)
+}
+
+func TestTXTDataSplitsLongText(t *testing.T) {
+	data := txtData(string(make([]byte, 300)))
+	assert.Len(t, data, 302)
+	assert.Equal(t, byte(255), data[0])
+	assert.Equal(t, byte(45), data[256])
+}
+
+func TestServerIgnoresBadDatagrams(t *testing.T) {
+	server := newServer(t)
+	conn, err := net.Dial("udp", server.Addr())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	response := make([]byte, headerLength)
+	binary.BigEndian.PutUint16(response[2:4], flagResponse)
+	for _, datagram := range [][]byte{response, make([]byte, headerLength), {1, 2, 3}} {
+		conn.Write(datagram)
+	}
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 512))
+	var netErr net.Error
+	assert.True(t, errors.As(err, &netErr) && netErr.Timeout(), "got a reply: %v", err)
+}
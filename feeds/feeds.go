@@ -0,0 +1,273 @@
+// Package feeds renders the listed verdicts as blocklist exports: plain
+// text, CSV, an RPZ zone, a hosts file and JSON. Feeds are regenerated when
+// the verdicts change, not per request, and can also be read as diffs
+// since a verdict serial.
+package feeds
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+// rpzTTL is the TTL of the records in the RPZ feed.
+const rpzTTL = 300
+
+// Feed is one export format over the listed verdicts of some scopes.
+type Feed struct {
+	Name        string
+	ContentType string
+	Scopes      []string
+
+	// header starts the full feed; the JSON feed has none.
+	header func(serial uint64, count int) string
+	// entries renders one verdict. Text feeds return lines; the JSON feed
+	// returns the verdict itself.
+	entries func(v types.Verdict) []any
+}
+
+// Feeds are the exports served, by name.
+var Feeds = []*Feed{
+	{
+		Name:        "urls.txt",
+		ContentType: "text/plain; charset=utf-8",
+		Scopes:      []string{types.ScopeURL},
+		header:      commentHeader("Listed URLs"),
+		entries:     func(v types.Verdict) []any { return []any{v.Key} },
+	},
+	{
+		Name:        "domains.txt",
+		ContentType: "text/plain; charset=utf-8",
+		Scopes:      []string{types.ScopeDomain, types.ScopeHost},
+		header:      commentHeader("Listed domains and hosts"),
+		entries:     namesOnly(func(v types.Verdict) []any { return []any{v.Key} }),
+	},
+	{
+		Name:        "hosts",
+		ContentType: "text/plain; charset=utf-8",
+		Scopes:      []string{types.ScopeDomain, types.ScopeHost},
+		header:      commentHeader("Listed domains and hosts, in hosts file format; subdomains are not covered"),
+		entries:     namesOnly(func(v types.Verdict) []any { return []any{"0.0.0.0 " + v.Key} }),
+	},
+	{
+		Name:        "blocklist.rpz",
+		ContentType: "text/dns",
+		Scopes:      []string{types.ScopeDomain, types.ScopeHost},
+		header:      rpzHeader,
+		entries:     rpzEntries,
+	},
+	{
+		Name:        "blocklist.csv",
+		ContentType: "text/csv; charset=utf-8",
+		Scopes:      []string{types.ScopeURL, types.ScopeHost, types.ScopeDomain},
+		header: func(uint64, int) string {
+			return "scope,key,classification,reasons,set_by,set_at\n"
+		},
+		entries: csvEntry,
+	},
+	{
+		Name:        "blocklist.json",
+		ContentType: "application/json",
+		Scopes:      []string{types.ScopeURL, types.ScopeHost, types.ScopeDomain},
+		entries:     func(v types.Verdict) []any { return []any{v} },
+	},
+}
+
+// Find returns the feed called name.
+func Find(name string) (*Feed, bool) {
+	for _, feed := range Feeds {
+		if feed.Name == name {
+			return feed, true
+		}
+	}
+	return nil, false
+}
+
+// includes reports whether v appears in the feed.
+func (f *Feed) includes(v *types.Verdict) bool {
+	return v != nil && v.Listed() && slices.Contains(f.Scopes, v.Scope)
+}
+
+// Rendered is a generated feed.
+type Rendered struct {
+	Body   []byte
+	ETag   string
+	Serial uint64
+	Count  int
+}
+
+// render generates the feed from every verdict as of serial.
+func (f *Feed) render(serial uint64, verdicts []types.Verdict) *Rendered {
+	var entries []any
+	for i := range verdicts {
+		if f.includes(&verdicts[i]) {
+			entries = append(entries, f.entries(verdicts[i])...)
+		}
+	}
+
+	var body bytes.Buffer
+	if f.header == nil {
+		// The JSON feed is a single document.
+		if entries == nil {
+			entries = []any{}
+		}
+		json.NewEncoder(&body).Encode(struct {
+			Serial  uint64 `json:"serial"`
+			Entries []any  `json:"entries"`
+		}{serial, entries})
+	} else {
+		body.WriteString(f.header(serial, len(entries)))
+		for _, entry := range entries {
+			body.WriteString(entry.(string))
+			body.WriteByte('\n')
+		}
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	return &Rendered{
+		Body:   body.Bytes(),
+		ETag:   `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Serial: serial,
+		Count:  len(entries),
+	}
+}
+
+// Diff is what changed in a feed between two serials: the entries to drop
+// and the entries to add, in the feed's own format.
+type Diff struct {
+	Feed    string `json:"feed"`
+	Since   uint64 `json:"since"`
+	Serial  uint64 `json:"serial"`
+	Added   []any  `json:"added"`
+	Removed []any  `json:"removed"`
+}
+
+// diff folds changes, oldest first, into the feed entries they add and
+// remove. A key changed several times counts once, from its first before
+// to its last after.
+func (f *Feed) diff(since, serial uint64, changes []service.VerdictChange) Diff {
+	d := Diff{Feed: f.Name, Since: since, Serial: serial, Added: []any{}, Removed: []any{}}
+	type span struct{ before, after *types.Verdict }
+	spans := map[string]*span{}
+	var order []string
+	for _, c := range changes {
+		id := c.Scope + ":" + c.Key
+		if s, exists := spans[id]; exists {
+			s.after = c.After
+			continue
+		}
+		spans[id] = &span{before: c.Before, after: c.After}
+		order = append(order, id)
+	}
+
+	for _, id := range order {
+		s := spans[id]
+		var before, after []any
+		if f.includes(s.before) {
+			before = f.entries(*s.before)
+		}
+		if f.includes(s.after) {
+			after = f.entries(*s.after)
+		}
+		d.Removed = append(d.Removed, subtract(before, after)...)
+		d.Added = append(d.Added, subtract(after, before)...)
+	}
+	return d
+}
+
+// subtract returns the entries of a that are not in b.
+func subtract(a, b []any) []any {
+	var result []any
+	for _, entry := range a {
+		if !slices.ContainsFunc(b, func(other any) bool { return sameEntry(entry, other) }) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func sameEntry(a, b any) bool {
+	if a, ok := a.(string); ok {
+		return a == b
+	}
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+// namesOnly leaves out host verdicts on IP addresses, which name files
+// such as hosts cannot block.
+func namesOnly(entries func(types.Verdict) []any) func(types.Verdict) []any {
+	return func(v types.Verdict) []any {
+		if net.ParseIP(v.Key) != nil {
+			return nil
+		}
+		return entries(v)
+	}
+}
+
+func commentHeader(title string) func(uint64, int) string {
+	return func(serial uint64, count int) string {
+		return fmt.Sprintf("# %s\n# serial: %d\n# entries: %d\n", title, serial, count)
+	}
+}
+
+// rpzHeader starts a Response Policy Zone with relative owner names, so
+// it can be loaded under any zone name. The SOA serial is the verdict
+// serial, truncated to the 32 bits DNS allows.
+func rpzHeader(serial uint64, _ int) string {
+	return fmt.Sprintf("$TTL %d\n@ SOA localhost. hostmaster.localhost. %d 3600 600 86400 %d\n@ NS localhost.\n",
+		rpzTTL, uint32(serial), rpzTTL)
+}
+
+// rpzEntries answers NXDOMAIN for a listed host, and also for every name
+// under a listed domain. A listed IP address becomes an rpz-ip trigger,
+// which applies to answers holding that address.
+func rpzEntries(v types.Verdict) []any {
+	if ip := net.ParseIP(v.Key); ip != nil {
+		return []any{rpzIP(ip) + " CNAME ."}
+	}
+	entries := []any{v.Key + " CNAME ."}
+	if v.Scope == types.ScopeDomain {
+		entries = append(entries, "*."+v.Key+" CNAME .")
+	}
+	return entries
+}
+
+// rpzIP writes ip as an rpz-ip owner name: the prefix length, then the
+// address reversed, with "zz" for the run of zero IPv6 words "::" stands
+// for.
+func rpzIP(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("32.%d.%d.%d.%d.rpz-ip", v4[3], v4[2], v4[1], v4[0])
+	}
+	var words []string
+	for i, half := range strings.SplitN(ip.String(), "::", 2) {
+		if i > 0 {
+			words = append(words, "zz")
+		}
+		if half != "" {
+			words = append(words, strings.Split(half, ":")...)
+		}
+	}
+	slices.Reverse(words)
+	return "128." + strings.Join(words, ".") + ".rpz-ip"
+}
+
+func csvEntry(v types.Verdict) []any {
+	var line strings.Builder
+	w := csv.NewWriter(&line)
+	w.Write([]string{v.Scope, v.Key, v.Classification, strings.Join(v.Reasons, "; "), v.SetBy, v.SetAt.Format(time.RFC3339)})
+	w.Flush()
+	return []any{strings.TrimSuffix(line.String(), "\n")}
+}
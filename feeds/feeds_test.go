@@ -0,0 +1,119 @@
+package feeds
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGenerator(t *testing.T) (*Generator, *service.Verdicts) {
+	verdicts := service.NewVerdicts(filepath.Join(t.TempDir(), "verdicts.json"))
+	setAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	verdicts.Set(types.Verdict{Scope: types.ScopeDomain, Key: "evil.example", Classification: types.VerdictMalicious,
+		Reasons: []string{"phishing", "kit, v2"}, SetBy: "token:ops", SetAt: setAt})
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "cdn.example.net", Classification: types.VerdictSuspicious, SetAt: setAt})
+	verdicts.Set(types.Verdict{Scope: types.ScopeURL, Key: "http://x.example/kit.zip", Classification: types.VerdictMalicious, SetAt: setAt})
+	verdicts.Set(types.Verdict{Scope: types.ScopeDomain, Key: "good.example", Classification: types.VerdictClean, SetAt: setAt})
+	return NewGenerator(verdicts), verdicts
+}
+
+func body(t *testing.T, g *Generator, name string) string {
+	rendered, exists := g.Get(name)
+	assert.True(t, exists, name)
+	return string(rendered.Body)
+}
+
+func TestRender(t *testing.T) {
+	g, _ := newGenerator(t)
+
+	assert.Equal(t, "# Listed URLs\n# serial: 4\n# entries: 1\nhttp://x.example/kit.zip\n", body(t, g, "urls.txt"))
+	assert.Contains(t, body(t, g, "domains.txt"), "evil.example\ncdn.example.net\n")
+	assert.Contains(t, body(t, g, "hosts"), "0.0.0.0 evil.example\n0.0.0.0 cdn.example.net\n")
+	assert.NotContains(t, body(t, g, "hosts"), "good.example")
+
+	rpz := body(t, g, "blocklist.rpz")
+	assert.True(t, strings.HasPrefix(rpz, "$TTL 300\n@ SOA localhost. hostmaster.localhost. 4 "))
+	assert.Contains(t, rpz, "evil.example CNAME .\n*.evil.example CNAME .\ncdn.example.net CNAME .\n")
+	assert.NotContains(t, rpz, "*.cdn.example.net")
+
+	assert.Contains(t, body(t, g, "blocklist.csv"),
+		"scope,key,classification,reasons,set_by,set_at\ndomain,evil.example,malicious,\"phishing; kit, v2\",token:ops,2024-01-02T03:04:05Z\n")
+	assert.Contains(t, body(t, g, "blocklist.json"), `"serial":4,"entries":[{"scope":"domain","key":"evil.example"`)
+}
+
+func TestRenderIPHosts(t *testing.T) {
+	g, verdicts := newGenerator(t)
+	for _, ip := range []string{"192.0.2.7", "2001:db8::1", "::1"} {
+		verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: ip, Classification: types.VerdictMalicious})
+	}
+	g.Refresh()
+
+	for _, name := range []string{"domains.txt", "hosts"} {
+		feed := body(t, g, name)
+		assert.NotContains(t, feed, "192.0.2.7", name)
+		assert.NotContains(t, feed, "::1", name)
+		assert.Contains(t, feed, "# entries: 2\n", name)
+	}
+	rpz := body(t, g, "blocklist.rpz")
+	assert.Contains(t, rpz, "32.7.2.0.192.rpz-ip CNAME .\n")
+	assert.Contains(t, rpz, "128.1.zz.db8.2001.rpz-ip CNAME .\n")
+	assert.Contains(t, rpz, "128.1.zz.rpz-ip CNAME .\n")
+	assert.NotContains(t, rpz, "\n192.0.2.7 ")
+	assert.Contains(t, body(t, g, "blocklist.csv"), "host,192.0.2.7,malicious")
+}
+
+func TestRegenerateOnChange(t *testing.T) {
+	g, verdicts := newGenerator(t)
+	before, _ := g.Get("domains.txt")
+
+	g.Start()
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "new.example", Classification: types.VerdictMalicious})
+	assert.Eventually(t, func() bool {
+		after, _ := g.Get("domains.txt")
+		return after.Serial == 5 && after.ETag != before.ETag && strings.Contains(string(after.Body), "new.example")
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestDiff(t *testing.T) {
+	g, verdicts := newGenerator(t)
+	domains, _ := Find("domains.txt")
+	rpz, _ := Find("blocklist.rpz")
+	csv, _ := Find("blocklist.csv")
+
+	verdicts.Set(types.Verdict{Scope: types.ScopeDomain, Key: "good.example", Classification: types.VerdictMalicious})
+	verdicts.Remove(types.ScopeDomain, "evil.example")
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "cdn.example.net", Classification: types.VerdictMalicious})
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "brief.example", Classification: types.VerdictMalicious})
+	verdicts.Remove(types.ScopeHost, "brief.example")
+
+	diff, err := g.Diff(domains, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(9), diff.Serial)
+	assert.Equal(t, []any{"good.example"}, diff.Added)
+	assert.Equal(t, []any{"evil.example"}, diff.Removed)
+
+	diff, _ = g.Diff(rpz, 4)
+	assert.Equal(t, []any{"evil.example CNAME .", "*.evil.example CNAME ."}, diff.Removed)
+
+	// A reclassified host changes its CSV row but not the domain list.
+	diff, _ = g.Diff(csv, 6)
+	assert.Len(t, diff.Added, 1)
+	assert.Len(t, diff.Removed, 1)
+	assert.True(t, strings.HasPrefix(diff.Added[0].(string), "host,cdn.example.net,malicious"))
+	diff, _ = g.Diff(domains, 6)
+	assert.Empty(t, diff.Added)
+	assert.Empty(t, diff.Removed)
+
+	diff, err = g.Diff(domains, 9)
+	assert.NoError(t, err)
+	assert.Empty(t, diff.Added)
+
+	// A serial from the future cannot be diffed against.
+	_, err = g.Diff(domains, 10)
+	assert.ErrorIs(t, err, ErrSerialAhead)
+}
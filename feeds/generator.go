@@ -0,0 +1,87 @@
+package feeds
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+)
+
+// ErrSerialExpired means the changes since a serial are no longer kept and
+// the full feed must be fetched instead.
+var ErrSerialExpired = errors.New("changes since this serial are no longer kept; fetch the full feed")
+
+// ErrSerialAhead means the serial is past the current one, as one kept from
+// before the verdicts were restored from an older file, and the full feed
+// must be fetched instead.
+var ErrSerialAhead = errors.New("serial is ahead of the current one; fetch the full feed")
+
+// Generator keeps every feed rendered from the current verdicts.
+type Generator struct {
+	verdicts *service.Verdicts
+	changed  chan struct{}
+
+	mutex    sync.RWMutex
+	rendered map[string]*Rendered
+}
+
+// NewGenerator renders the feeds once and marks them stale whenever the
+// verdicts change; Start regenerates stale feeds in the background.
+func NewGenerator(verdicts *service.Verdicts) *Generator {
+	g := &Generator{verdicts: verdicts, changed: make(chan struct{}, 1)}
+	verdicts.OnChange(func() {
+		select {
+		case g.changed <- struct{}{}:
+		default:
+			// A regeneration is already pending and will see this change.
+		}
+	})
+	g.Refresh()
+	return g
+}
+
+// Start regenerates the feeds after each burst of changes.
+func (g *Generator) Start() {
+	go func() {
+		for range g.changed {
+			g.Refresh()
+		}
+	}()
+}
+
+// Refresh renders every feed from the verdicts now.
+func (g *Generator) Refresh() {
+	serial, verdicts := g.verdicts.Snapshot()
+	rendered := make(map[string]*Rendered, len(Feeds))
+	for _, feed := range Feeds {
+		rendered[feed.Name] = feed.render(serial, verdicts)
+	}
+
+	g.mutex.Lock()
+	g.rendered = rendered
+	g.mutex.Unlock()
+	metrics.FeedRegenerations.Inc()
+	slog.Debug("regenerated feeds", "serial", serial)
+}
+
+// Get returns the last rendering of the feed called name.
+func (g *Generator) Get(name string) (*Rendered, bool) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	rendered, exists := g.rendered[name]
+	return rendered, exists
+}
+
+// Diff returns what changed in feed since the verdict serial since.
+func (g *Generator) Diff(feed *Feed, since uint64) (Diff, error) {
+	changes, serial, ok := g.verdicts.ChangesSince(since)
+	switch {
+	case since > serial:
+		return Diff{}, ErrSerialAhead
+	case !ok:
+		return Diff{}, ErrSerialExpired
+	}
+	return feed.diff(since, serial, changes), nil
+}
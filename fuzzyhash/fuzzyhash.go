@@ -0,0 +1,243 @@
+// Package fuzzyhash computes context-triggered piecewise hashes in the
+// style of spamsum and ssdeep, written "blocksize:digest:digest", and
+// scores how alike two of them are. Unlike a SHA-256, the hash of a body
+// changes only locally when the body is edited, so near copies of known
+// content still score high.
+package fuzzyhash
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	rollingWindow = 7
+	minBlockSize  = 3
+	// digestLength is the longest first digest; the second, at twice the
+	// block size, is half as long.
+	digestLength = 64
+	hashPrime    = 0x01000193
+	hashInit     = 0x28021967
+	b64          = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+)
+
+// rolling is the rolling hash over the last rollingWindow bytes whose value
+// picks the points where the body is cut into pieces.
+type rolling struct {
+	window     [rollingWindow]uint32
+	h1, h2, h3 uint32
+	n          uint32
+}
+
+func (r *rolling) add(c byte) uint32 {
+	r.h2 -= r.h1
+	r.h2 += rollingWindow * uint32(c)
+	r.h1 += uint32(c)
+	r.h1 -= r.window[r.n%rollingWindow]
+	r.window[r.n%rollingWindow] = uint32(c)
+	r.n++
+	r.h3 = r.h3<<5 ^ uint32(c)
+	return r.h1 + r.h2 + r.h3
+}
+
+// Hash returns the fuzzy hash of data.
+func Hash(data []byte) string {
+	blockSize := uint32(minBlockSize)
+	for blockSize*digestLength < uint32(len(data)) {
+		blockSize *= 2
+	}
+
+	for {
+		first, second := digests(data, blockSize)
+		// Too few pieces at this block size say little; retry smaller.
+		if blockSize > minBlockSize && len(first) < digestLength/2 {
+			blockSize /= 2
+			continue
+		}
+		return fmt.Sprintf("%d:%s:%s", blockSize, first, second)
+	}
+}
+
+// digests cuts data wherever the rolling hash hits blockSize, and twice
+// blockSize, and hashes each piece to one character.
+func digests(data []byte, blockSize uint32) (string, string) {
+	var roll rolling
+	var first, second []byte
+	h1, h2 := uint32(hashInit), uint32(hashInit)
+	sum := uint32(0)
+	for _, c := range data {
+		h1 = h1*hashPrime ^ uint32(c)
+		h2 = h2*hashPrime ^ uint32(c)
+		sum = roll.add(c)
+
+		if sum%blockSize == blockSize-1 {
+			if len(first) < digestLength-1 {
+				first = append(first, b64[h1%64])
+				h1 = hashInit
+			}
+			if sum%(blockSize*2) == blockSize*2-1 && len(second) < digestLength/2-1 {
+				second = append(second, b64[h2%64])
+				h2 = hashInit
+			}
+		}
+	}
+	// The last piece counts too, unless the body ended on a cut.
+	if sum != 0 {
+		first = append(first, b64[h1%64])
+		second = append(second, b64[h2%64])
+	}
+	return string(first), string(second)
+}
+
+// Valid reports whether hash is written as Hash writes them.
+func Valid(hash string) bool {
+	_, _, _, err := parse(hash)
+	return err == nil
+}
+
+// Comparable reports whether hash is valid and long enough to score
+// against hashes other than itself; the hash of a short body is not.
+func Comparable(hash string) bool {
+	_, first, _, err := parse(hash)
+	return err == nil && len(first) >= rollingWindow
+}
+
+func parse(hash string) (uint32, string, string, error) {
+	parts := strings.Split(hash, ":")
+	if len(parts) != 3 {
+		return 0, "", "", fmt.Errorf("fuzzy hash must be blocksize:digest:digest")
+	}
+	blockSize, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || blockSize < minBlockSize || blockSize%minBlockSize != 0 {
+		return 0, "", "", fmt.Errorf("invalid fuzzy hash block size %q", parts[0])
+	}
+	for _, digest := range parts[1:] {
+		if len(digest) > digestLength || strings.Trim(digest, b64) != "" {
+			return 0, "", "", fmt.Errorf("invalid fuzzy hash digest %q", digest)
+		}
+	}
+	return uint32(blockSize), parts[1], parts[2], nil
+}
+
+// Digest is a hash parsed for comparing, so one compared against many
+// others is parsed once.
+type Digest struct {
+	hash      string
+	blockSize uint32
+	// first and second are the digests with long runs squeezed out.
+	first, second string
+}
+
+// Parse parses hash for Digest.Compare.
+func Parse(hash string) (Digest, error) {
+	blockSize, first, second, err := parse(hash)
+	if err != nil {
+		return Digest{}, err
+	}
+	return Digest{hash: hash, blockSize: blockSize, first: squeeze(first), second: squeeze(second)}, nil
+}
+
+// BlockSize returns the block size the digest was taken at. Only digests
+// whose block sizes are equal or a factor of two apart score above 0.
+func (d Digest) BlockSize() uint32 {
+	return d.blockSize
+}
+
+// Compare scores how alike the content behind two hashes is, from 0 for
+// nothing in common to 100 for the same. Hashes whose block sizes are not
+// equal or a factor of two apart cannot be compared and score 0.
+func Compare(a, b string) int {
+	digestA, errA := Parse(a)
+	digestB, errB := Parse(b)
+	if errA != nil || errB != nil {
+		return 0
+	}
+	return digestA.Compare(digestB)
+}
+
+// Compare scores d against other as the package-level Compare does.
+func (d Digest) Compare(other Digest) int {
+	if d.hash == other.hash {
+		return 100
+	}
+	switch {
+	case d.blockSize == other.blockSize:
+		return max(score(d.first, other.first, d.blockSize), score(d.second, other.second, d.blockSize*2))
+	case d.blockSize == other.blockSize*2:
+		return score(d.first, other.second, d.blockSize)
+	case other.blockSize == d.blockSize*2:
+		return score(d.second, other.first, other.blockSize)
+	}
+	return 0
+}
+
+// squeeze shortens runs of more than three identical characters, which
+// say more about the format than about the content.
+func squeeze(digest string) string {
+	var out []byte
+	for i := 0; i < len(digest); i++ {
+		if i >= 3 && digest[i] == digest[i-1] && digest[i] == digest[i-2] && digest[i] == digest[i-3] {
+			continue
+		}
+		out = append(out, digest[i])
+	}
+	return string(out)
+}
+
+// score compares two digests taken at blockSize. They must share a run of
+// rollingWindow characters to score at all, so short chance overlaps do
+// not count.
+func score(a, b string, blockSize uint32) int {
+	if len(a) < rollingWindow || len(b) < rollingWindow || !shareSubstring(a, b, rollingWindow) {
+		return 0
+	}
+	distance := editDistance(a, b)
+	scaled := distance * digestLength / (len(a) + len(b))
+	scaled = 100 * scaled / digestLength
+	if scaled >= 100 {
+		return 0
+	}
+	result := 100 - scaled
+	// Small block sizes see little of the body; do not let them claim a
+	// close match.
+	if limit := int(blockSize/minBlockSize) * min(len(a), len(b)); result > limit {
+		result = limit
+	}
+	return result
+}
+
+func shareSubstring(a, b string, n int) bool {
+	seen := make(map[string]bool, len(a))
+	for i := 0; i+n <= len(a); i++ {
+		seen[a[i:i+n]] = true
+	}
+	for i := 0; i+n <= len(b); i++ {
+		if seen[b[i:i+n]] {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance counts insertions and deletions as 1 and substitutions as
+// 2, as ssdeep does.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+			if a[i-1] != b[j-1] {
+				substitution += 2
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,65 @@
+package fuzzyhash
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// page builds a few kilobytes of text from seed, like a templated page.
+func page(seed int64) string {
+	r := rand.New(rand.NewSource(seed))
+	words := strings.Fields("the login page of your bank asks for a password and account number, verify now or click here to continue")
+	var b strings.Builder
+	for range 2000 {
+		b.WriteString(words[r.Intn(len(words))])
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func TestHashFormat(t *testing.T) {
+	hash := Hash([]byte(page(1)))
+	assert.True(t, Valid(hash))
+	assert.True(t, Comparable(hash))
+	assert.Equal(t, hash, Hash([]byte(page(1))))
+
+	assert.Equal(t, "3::", Hash(nil))
+	assert.True(t, Valid("3::"))
+	assert.False(t, Comparable("3::"))
+	for _, bad := range []string{"", "abc", "4:abc:def", "3:a$c:def", "x:abc:def", "3:abc"} {
+		assert.False(t, Valid(bad), bad)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	original := page(1)
+	hash := Hash([]byte(original))
+
+	assert.Equal(t, 100, Compare(hash, hash))
+	edited := strings.Replace(original, "password", "passcode", 3)
+	assert.Greater(t, Compare(hash, Hash([]byte(edited))), 90)
+	injected := original[:len(original)/2] + "<script src=//evil.example/x.js></script>" + original[len(original)/2:]
+	assert.Greater(t, Compare(hash, Hash([]byte(injected))), 90)
+	// Half as long again doubles the block size; the hashes still compare.
+	extended := original + original[:len(original)/2]
+	assert.Greater(t, Compare(hash, Hash([]byte(extended))), 60)
+
+	assert.Equal(t, 0, Compare(hash, Hash([]byte(page(2)))))
+	assert.Equal(t, 0, Compare(hash, "not a hash"))
+	assert.Equal(t, 0, Compare(hash, "3072:abcdefgh:abcd"))
+
+	// Parsed digests score as the hashes they came from.
+	digest, err := Parse(hash)
+	assert.NoError(t, err)
+	for _, other := range []string{hash, Hash([]byte(edited)), Hash([]byte(extended))} {
+		parsed, err := Parse(other)
+		assert.NoError(t, err)
+		assert.Equal(t, Compare(hash, other), digest.Compare(parsed))
+		assert.Equal(t, Compare(other, hash), parsed.Compare(digest))
+	}
+	_, err = Parse("not a hash")
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+)
+
+// handleAudit returns audit entries, newest first. The next page starts
+// before the oldest entry returned, whose sequence number is the cursor.
+func (h *Handler) handleAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := audit.Query(filter)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("reading audit log: %w", err))
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+
+	if len(entries) == filter.Limit {
+		utils.SetNextCursor(w, r, strconv.FormatUint(entries[len(entries)-1].Seq, 10))
+	}
+	utils.WriteJson(w, http.StatusOK, entries)
+}
+
+func parseAuditFilter(params url.Values) (audit.Filter, error) {
+	filter := audit.Filter{
+		Action:    params.Get("action"),
+		Actor:     params.Get("actor"),
+		Target:    params.Get("target"),
+		Client:    params.Get("client"),
+		RequestID: params.Get("request_id"),
+		Limit:     config.Envs.MaxPageSize,
+	}
+
+	var err error
+	if filter.Since, err = parseTime(params, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseTime(params, "until"); err != nil {
+		return filter, err
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		if filter.Before, err = strconv.ParseUint(cursor, 10, 64); err != nil || filter.Before == 0 {
+			return filter, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return filter, fmt.Errorf("limit must be a positive integer")
+		}
+		filter.Limit = min(n, filter.Limit)
+	}
+	return filter, nil
+}
+
+func parseTime(params url.Values, name string) (time.Time, error) {
+	value := params.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return t, nil
+}
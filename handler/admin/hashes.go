@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/hashes"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+)
+
+func (h *Handler) handleListHashes(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJson(w, http.StatusOK, h.hashes.List())
+}
+
+// handleAddHashes adds known-bad hashes, then checks the bodies already
+// fetched against the set in the background, so stored URLs match without
+// waiting for their next fetch or holding up the response.
+func (h *Handler) handleAddHashes(w http.ResponseWriter, r *http.Request) {
+	var payload types.HashesPayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteError(w, utils.ParseErrorStatus(err), err)
+		return
+	}
+	if len(payload.Hashes) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("hashes is required"))
+		return
+	}
+
+	actor := middleware.ActorFromContext(r.Context())
+	values := make([]string, 0, len(payload.Hashes))
+	for i := range payload.Hashes {
+		hash := &payload.Hashes[i]
+		if err := hashes.Normalize(hash); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("hash %d: %w", i+1, err))
+			return
+		}
+		hash.AddedBy, hash.AddedAt = actor, time.Time{}
+		values = append(values, hash.Value)
+	}
+
+	added, err := h.hashes.Add(payload.Hashes)
+	if err != nil {
+		record(r, audit.Entry{Action: audit.ActionHashesAdd, Error: err.Error()})
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	record(r, audit.Entry{Action: audit.ActionHashesAdd, Affected: added, After: map[string]any{"hashes": values}})
+
+	h.hashes.RescanLater(context.WithoutCancel(r.Context()))
+	utils.WriteJson(w, http.StatusAccepted, types.HashesResult{Added: added, Total: h.hashes.Count()})
+}
+
+func (h *Handler) handleDeleteHash(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("value")
+	if value == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("value is required"))
+		return
+	}
+	lookup := types.KnownHash{Value: value}
+	if err := hashes.Normalize(&lookup); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	removed, existed := h.hashes.Remove(lookup.Value)
+	if !existed {
+		record(r, audit.Entry{Action: audit.ActionHashDelete, Target: lookup.Value, Error: "hash not found"})
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("hash not found"))
+		return
+	}
+	record(r, audit.Entry{Action: audit.ActionHashDelete, Target: lookup.Value, Affected: 1,
+		Before: map[string]any{"type": removed.Type, "name": removed.Name, "classification": removed.Classification}})
+	// URLs that matched the hash may match another, or none.
+	h.hashes.RescanLater(context.WithoutCancel(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,205 @@
+package admin
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/hashes"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/rules"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	verdicts *service.Verdicts
+	rules    *rules.Engine
+	hashes   *hashes.Set
+}
+
+func NewHandler(verdicts *service.Verdicts, rules *rules.Engine, hashes *hashes.Set) *Handler {
+	return &Handler{verdicts: verdicts, rules: rules, hashes: hashes}
+}
+
+// RegisterRoutes mounts the admin API under /admin, plus /audit and
+// /hashes. Requests are rate limited before authentication, so tokens
+// cannot be guessed quickly.
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middleware.RateLimiter) {
+	slog.Info("registering admin routes")
+
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.Limit, adminAuth)
+
+	admin.HandleFunc("/url", h.handleDelete).Methods("DELETE")
+	admin.HandleFunc("/url/reset", h.handleReset).Methods("POST")
+	admin.HandleFunc("/url/pin", h.flag(audit.ActionPin, "pinned", utils.URLStore.SetPinned, true)).Methods("PUT")
+	admin.HandleFunc("/url/pin", h.flag(audit.ActionUnpin, "pinned", utils.URLStore.SetPinned, false)).Methods("DELETE")
+	admin.HandleFunc("/url/exclude", h.flag(audit.ActionExclude, "excluded", utils.URLStore.SetExcluded, true)).Methods("PUT")
+	admin.HandleFunc("/url/exclude", h.flag(audit.ActionInclude, "excluded", utils.URLStore.SetExcluded, false)).Methods("DELETE")
+	admin.HandleFunc("/urls/delete", h.handleBulkDelete).Methods("POST")
+	admin.HandleFunc("/verdicts", h.handleListVerdicts).Methods("GET")
+	admin.HandleFunc("/verdicts", h.handleSetVerdict).Methods("PUT")
+	admin.HandleFunc("/verdicts", h.handleDeleteVerdict).Methods("DELETE")
+	admin.HandleFunc("/rules", h.handleListRules).Methods("GET")
+	admin.HandleFunc("/rules/test", h.handleTestRules).Methods("POST")
+
+	router.Handle("/audit", middleware.Limit(adminAuth(http.HandlerFunc(h.handleAudit)))).Methods("GET")
+	router.Handle("/hashes", middleware.Limit(adminAuth(http.HandlerFunc(h.handleListHashes)))).Methods("GET")
+	router.Handle("/hashes", middleware.Limit(adminAuth(http.HandlerFunc(h.handleAddHashes)))).Methods("POST")
+	router.Handle("/hashes", middleware.Limit(adminAuth(http.HandlerFunc(h.handleDeleteHash)))).Methods("DELETE")
+}
+
+// adminAuth keeps the middleware package name free for the rate limiter
+// parameter above.
+var adminAuth = middleware.AdminAuth
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	target, ok := requireURL(w, r)
+	if !ok {
+		return
+	}
+
+	before, ok := utils.URLStore.Remove(target)
+	if !ok {
+		notFound(w, r, audit.ActionDelete, target)
+		return
+	}
+
+	record(r, audit.Entry{Action: audit.ActionDelete, Target: target, Affected: 1, Before: summary(before)})
+	utils.WriteJson(w, http.StatusOK, types.AdminResult{Action: audit.ActionDelete, Affected: 1, URLs: []string{target}})
+}
+
+func (h *Handler) handleReset(w http.ResponseWriter, r *http.Request) {
+	target, ok := requireURL(w, r)
+	if !ok {
+		return
+	}
+
+	before, ok := utils.URLStore.Reset(target)
+	if !ok {
+		notFound(w, r, audit.ActionReset, target)
+		return
+	}
+
+	record(r, audit.Entry{Action: audit.ActionReset, Target: target, Affected: 1,
+		Before: counters(before), After: counters(types.URLData{})})
+	writeURL(w, target)
+}
+
+// flag returns a handler that sets or clears the per-URL flag named field
+// through set.
+func (h *Handler) flag(action, field string, set func(string, bool) (types.URLData, bool), value bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, ok := requireURL(w, r)
+		if !ok {
+			return
+		}
+
+		before, ok := set(target, value)
+		if !ok {
+			notFound(w, r, action, target)
+			return
+		}
+
+		previous := before.Pinned
+		if field == "excluded" {
+			previous = before.Excluded
+		}
+		record(r, audit.Entry{Action: action, Target: target, Affected: 1,
+			Before: map[string]bool{field: previous}, After: map[string]bool{field: value}})
+		writeURL(w, target)
+	}
+}
+
+func (h *Handler) handleBulkDelete(w http.ResponseWriter, r *http.Request) {
+	var payload types.BulkDeletePayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteError(w, utils.ParseErrorStatus(err), err)
+		return
+	}
+
+	pattern := utils.SearchQuery{Pattern: payload.Pattern, Mode: payload.Mode}
+	if pattern.Mode == "" {
+		pattern.Mode = utils.MatchSubstring
+	} else if !utils.ValidMatchMode(pattern.Mode) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown match mode %q", pattern.Mode))
+		return
+	}
+
+	target := payload.Domain
+	if target == "" {
+		target = pattern.Mode + ":" + pattern.Pattern
+	}
+
+	urls, err := utils.URLStore.BulkDelete(payload.Domain, pattern, payload.DryRun)
+	if err != nil {
+		record(r, audit.Entry{Action: audit.ActionBulkDelete, Target: target, DryRun: payload.DryRun, Error: err.Error()})
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if urls == nil {
+		urls = []string{}
+	}
+	record(r, audit.Entry{Action: audit.ActionBulkDelete, Target: target, Affected: len(urls), DryRun: payload.DryRun,
+		Before: map[string][]string{"urls": urls}})
+	utils.WriteJson(w, http.StatusOK, types.AdminResult{
+		Action:   audit.ActionBulkDelete,
+		Affected: len(urls),
+		URLs:     urls,
+		DryRun:   payload.DryRun,
+	})
+}
+
+func requireURL(w http.ResponseWriter, r *http.Request) (string, bool) {
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("URL is required"))
+		return "", false
+	}
+	return target, true
+}
+
+func notFound(w http.ResponseWriter, r *http.Request, action, target string) {
+	record(r, audit.Entry{Action: action, Target: target, Error: "URL not found"})
+	utils.WriteError(w, http.StatusNotFound, fmt.Errorf("URL not found"))
+}
+
+func writeURL(w http.ResponseWriter, target string) {
+	data, _ := utils.URLStore.Get(target)
+	utils.WriteJson(w, http.StatusOK, data)
+}
+
+// summary is what the audit log keeps of a deleted URL.
+func summary(data types.URLData) map[string]any {
+	return map[string]any{
+		"count":         data.Count,
+		"success_count": data.SuccessCount,
+		"failure_count": data.FailureCount,
+		"created_at":    data.CreatedAt,
+		"pinned":        data.Pinned,
+		"excluded":      data.Excluded,
+	}
+}
+
+// counters are the fields a reset clears.
+func counters(data types.URLData) map[string]any {
+	return map[string]any{
+		"count":              data.Count,
+		"success_count":      data.SuccessCount,
+		"failure_count":      data.FailureCount,
+		"not_modified_count": data.NotModifiedCount,
+		"bytes_saved":        data.BytesSaved,
+	}
+}
+
+// record fills in who made the request and from where.
+func record(r *http.Request, entry audit.Entry) {
+	entry.Actor = middleware.ActorFromContext(r.Context())
+	entry.Client = middleware.ClientKey(r)
+	audit.Record(r.Context(), entry)
+}
@@ -0,0 +1,316 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/hashes"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/rules"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const token = "0123456789abcdef"
+
+func newRouter(t *testing.T) (*mux.Router, string) {
+	t.Cleanup(func() { config.Reload() })
+	t.Setenv("ADMIN_TOKENS", "ops:"+token)
+	t.Setenv("RATE_LIMIT_INTERVAL", "0s")
+	_, err := config.Reload()
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	assert.NoError(t, audit.Open(audit.Options{Path: path}))
+	t.Cleanup(func() { audit.Close() })
+
+	router := mux.NewRouter()
+	dir := t.TempDir()
+	verdicts := service.NewVerdicts(filepath.Join(dir, "verdicts.json"))
+	engine := rules.NewEngine(filepath.Join(dir, "rules.yaml"), verdicts)
+	assert.NoError(t, engine.Load())
+	hashSet := hashes.NewSet(filepath.Join(dir, "hashes.json"), verdicts)
+	NewHandler(verdicts, engine, hashSet).RegisterRoutes(router, middleware.NewRateLimiter())
+	return router, path
+}
+
+func do(router *mux.Router, method, target string, body any) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &payload)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func auditEntries(t *testing.T, path string) []audit.Entry {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var entries []audit.Entry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry audit.Entry
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAdminRequiresCredentials(t *testing.T) {
+	router, _ := newRouter(t)
+
+	req := httptest.NewRequest("DELETE", "/admin/url?url=http://example.com", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+}
+
+func TestAdminURLActions(t *testing.T) {
+	router, path := newRouter(t)
+	const target = "http://admin-actions.example.com/"
+	utils.URLStore.Submit(target)
+	utils.URLStore.Submit(target)
+
+	w := do(router, "PUT", "/admin/url/pin?url="+target, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var data types.URLData
+	json.NewDecoder(w.Body).Decode(&data)
+	assert.True(t, data.Pinned)
+
+	w = do(router, "PUT", "/admin/url/exclude?url="+target, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, utils.URLStore.Excluded(target))
+	do(router, "DELETE", "/admin/url/exclude?url="+target, nil)
+	assert.False(t, utils.URLStore.Excluded(target))
+
+	w = do(router, "POST", "/admin/url/reset?url="+target, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.NewDecoder(w.Body).Decode(&data)
+	assert.Equal(t, 0, data.Count)
+	assert.True(t, data.Pinned, "reset keeps flags")
+
+	assert.Equal(t, http.StatusOK, do(router, "DELETE", "/admin/url?url="+target, nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(router, "DELETE", "/admin/url?url="+target, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, do(router, "DELETE", "/admin/url", nil).Code)
+
+	entries := auditEntries(t, path)
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+		assert.Equal(t, "token:ops", entry.Actor)
+		assert.Equal(t, target, entry.Target)
+	}
+	assert.Equal(t, []string{audit.ActionPin, audit.ActionExclude, audit.ActionInclude, audit.ActionReset, audit.ActionDelete, audit.ActionDelete}, actions)
+	assert.Equal(t, "URL not found", entries[5].Error)
+}
+
+func TestAdminBulkDelete(t *testing.T) {
+	router, path := newRouter(t)
+	utils.URLStore.Submit("http://bulk.example.net/a")
+	utils.URLStore.Submit("http://www.bulk.example.net/b")
+
+	w := do(router, "POST", "/admin/urls/delete", types.BulkDeletePayload{Domain: "bulk.example.net", DryRun: true})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result types.AdminResult
+	json.NewDecoder(w.Body).Decode(&result)
+	assert.Equal(t, 2, result.Affected)
+	assert.True(t, result.DryRun)
+	_, exists := utils.URLStore.Load("http://bulk.example.net/a")
+	assert.True(t, exists)
+
+	w = do(router, "POST", "/admin/urls/delete", types.BulkDeletePayload{Pattern: "http://*.bulk.example.net/*", Mode: utils.MatchGlob})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var deleted types.AdminResult
+	json.NewDecoder(w.Body).Decode(&deleted)
+	assert.Equal(t, []string{"http://www.bulk.example.net/b"}, deleted.URLs)
+	assert.False(t, deleted.DryRun)
+
+	assert.Equal(t, http.StatusBadRequest, do(router, "POST", "/admin/urls/delete", types.BulkDeletePayload{}).Code)
+	assert.Equal(t, http.StatusBadRequest,
+		do(router, "POST", "/admin/urls/delete", types.BulkDeletePayload{Pattern: "x", Mode: "fuzzy"}).Code)
+
+	entries := auditEntries(t, path)
+	assert.Len(t, entries, 3)
+	assert.True(t, entries[0].DryRun)
+	assert.Equal(t, "glob:http://*.bulk.example.net/*", entries[1].Target)
+	assert.Equal(t, 1, entries[1].Affected)
+	assert.NotEmpty(t, entries[2].Error)
+}
+
+func TestAuditQuery(t *testing.T) {
+	router, _ := newRouter(t)
+	const target = "http://audit-query.example.com/"
+	utils.URLStore.Submit(target)
+	do(router, "PUT", "/admin/url/pin?url="+target, nil)
+	do(router, "DELETE", "/admin/url?url="+target, nil)
+
+	w := do(router, "GET", "/audit?action=url.&limit=1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []audit.Entry
+	json.NewDecoder(w.Body).Decode(&entries)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, audit.ActionDelete, entries[0].Action)
+	assert.Equal(t, true, entries[0].Before.(map[string]any)["pinned"])
+	next := w.Header().Get("X-Next-Cursor")
+	assert.NotEmpty(t, next)
+
+	w = do(router, "GET", "/audit?action=url.&limit=1&cursor="+next, nil)
+	entries = nil
+	json.NewDecoder(w.Body).Decode(&entries)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, audit.ActionPin, entries[0].Action)
+	assert.Equal(t, map[string]any{"pinned": false}, entries[0].Before)
+	assert.Equal(t, map[string]any{"pinned": true}, entries[0].After)
+
+	assert.Equal(t, http.StatusBadRequest, do(router, "GET", "/audit?since=yesterday", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, do(router, "GET", "/audit?cursor=2026-10-19T03:08:10Z", nil).Code)
+
+	req := httptest.NewRequest("GET", "/audit", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAdminVerdicts(t *testing.T) {
+	router, path := newRouter(t)
+
+	w := do(router, "PUT", "/admin/verdicts", types.VerdictPayload{Scope: "domain", Key: "Evil.Example.",
+		Classification: types.VerdictMalicious, Reasons: []string{"phishing kit"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var verdict types.Verdict
+	json.NewDecoder(w.Body).Decode(&verdict)
+	assert.Equal(t, "evil.example", verdict.Key)
+	assert.Equal(t, "token:ops", verdict.SetBy)
+	assert.False(t, verdict.SetAt.IsZero())
+
+	for _, bad := range []types.VerdictPayload{
+		{Scope: "domain", Key: "www.evil.example", Classification: types.VerdictMalicious},
+		{Scope: "host", Key: "evil.example", Classification: types.VerdictUnknown},
+		{Scope: "url", Key: "", Classification: types.VerdictClean},
+		{Scope: "path", Key: "/x", Classification: types.VerdictClean},
+	} {
+		assert.Equal(t, http.StatusBadRequest, do(router, "PUT", "/admin/verdicts", bad).Code, "%+v", bad)
+	}
+
+	do(router, "PUT", "/admin/verdicts", types.VerdictPayload{Scope: "domain", Key: "evil.example", Classification: types.VerdictSuspicious})
+	w = do(router, "GET", "/admin/verdicts?classification=suspicious", nil)
+	var verdicts []types.Verdict
+	json.NewDecoder(w.Body).Decode(&verdicts)
+	assert.Len(t, verdicts, 1)
+
+	assert.Equal(t, http.StatusNoContent, do(router, "DELETE", "/admin/verdicts?scope=domain&key=EVIL.example", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(router, "DELETE", "/admin/verdicts?scope=domain&key=evil.example", nil).Code)
+
+	entries := auditEntries(t, path)
+	assert.Len(t, entries, 4)
+	assert.Equal(t, audit.ActionVerdictSet, entries[1].Action)
+	assert.Equal(t, "domain:evil.example", entries[1].Target)
+	assert.Equal(t, "malicious", entries[1].Before.(map[string]any)["classification"])
+	assert.Equal(t, "suspicious", entries[1].After.(map[string]any)["classification"])
+	assert.Equal(t, audit.ActionVerdictDelete, entries[2].Action)
+}
+
+func TestAdminTestRules(t *testing.T) {
+	router, _ := newRouter(t)
+	utils.URLStore.Submit("http://rules.example/wp-admin/shell.php")
+	t.Cleanup(func() { utils.URLStore.Remove("http://rules.example/wp-admin/shell.php") })
+
+	w := do(router, "GET", "/admin/rules", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	draft := []rules.Rule{
+		{Name: "webshell", When: rules.Condition{PathRegex: `\.php$`}, Verdict: types.VerdictMalicious, Scope: types.ScopeHost, Tags: []string{"webshell"}},
+		{Name: "popular", When: rules.Condition{MinSources: 3}, Tags: []string{"popular"}},
+	}
+	w = do(router, "POST", "/admin/rules/test", map[string]any{"url": "http://rules.example/wp-admin/shell.php", "rules": draft})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result struct {
+		Stored  bool          `json:"stored"`
+		Matches []rules.Match `json:"matches"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	assert.True(t, result.Stored)
+	assert.Equal(t, []rules.Match{{Rule: "webshell", Scope: "host", Key: "rules.example", Classification: "malicious", Tags: []string{"webshell"}}}, result.Matches)
+
+	// A dry run changes nothing.
+	_, exists := verdictsOf(t, router)["host:rules.example"]
+	assert.False(t, exists)
+
+	draft[0].When.PathRegex = "("
+	assert.Equal(t, http.StatusBadRequest, do(router, "POST", "/admin/rules/test", map[string]any{"url": "http://a.example", "rules": draft}).Code)
+	assert.Equal(t, http.StatusBadRequest, do(router, "POST", "/admin/rules/test", map[string]any{}).Code)
+}
+
+func verdictsOf(t *testing.T, router *mux.Router) map[string]types.Verdict {
+	var verdicts []types.Verdict
+	assert.NoError(t, json.NewDecoder(do(router, "GET", "/admin/verdicts", nil).Body).Decode(&verdicts))
+	byKey := map[string]types.Verdict{}
+	for _, verdict := range verdicts {
+		byKey[verdict.Scope+":"+verdict.Key] = verdict
+	}
+	return byKey
+}
+
+func TestAdminHashes(t *testing.T) {
+	router, path := newRouter(t)
+	const kitHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	target := "http://hashes.example/kit"
+	utils.URLStore.Submit(target)
+	t.Cleanup(func() { utils.URLStore.Remove(target) })
+	value, _ := utils.URLStore.Load(target)
+	utils.Mutex.Lock()
+	value.(*types.URLData).ContentHash = kitHash
+	utils.Mutex.Unlock()
+
+	w := do(router, "POST", "/hashes", types.HashesPayload{Hashes: []types.KnownHash{
+		{Value: strings.ToUpper(kitHash), Name: "kit-a"},
+	}})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var result types.HashesResult
+	json.NewDecoder(w.Body).Decode(&result)
+	assert.Equal(t, types.HashesResult{Added: 1, Total: 1}, result)
+
+	// Stored URLs are checked in the background.
+	assert.Eventually(t, func() bool { return len(auditEntries(t, path)) == 3 }, time.Second, 5*time.Millisecond)
+	utils.Mutex.RLock()
+	match := *value.(*types.URLData).HashMatch
+	utils.Mutex.RUnlock()
+	assert.Equal(t, kitHash, match.Hash)
+	assert.Equal(t, "kit-a", match.Name)
+
+	w = do(router, "GET", "/hashes", nil)
+	var known []types.KnownHash
+	json.NewDecoder(w.Body).Decode(&known)
+	assert.Len(t, known, 1)
+	assert.Equal(t, types.HashSHA256, known[0].Type)
+	assert.Equal(t, "token:ops", known[0].AddedBy)
+
+	assert.Equal(t, http.StatusBadRequest, do(router, "POST", "/hashes", types.HashesPayload{}).Code)
+	assert.Equal(t, http.StatusBadRequest, do(router, "POST", "/hashes", types.HashesPayload{Hashes: []types.KnownHash{{Value: "abc"}}}).Code)
+
+	assert.Equal(t, http.StatusNoContent, do(router, "DELETE", "/hashes?value="+kitHash, nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(router, "DELETE", "/hashes?value="+kitHash, nil).Code)
+
+	var actions []string
+	for _, entry := range auditEntries(t, path) {
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{audit.ActionHashesAdd, audit.ActionHashMatch, audit.ActionVerdictSet,
+		audit.ActionHashDelete, audit.ActionHashDelete}, actions)
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/rules"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+)
+
+// ruleTestPayload asks which rules would match a URL. Rules, when given,
+// are evaluated instead of the rules file, so a draft can be tried before
+// it is deployed.
+type ruleTestPayload struct {
+	URL   string       `json:"url"`
+	Rules []rules.Rule `json:"rules,omitempty"`
+}
+
+type ruleTestResult struct {
+	URL     string        `json:"url"`
+	Stored  bool          `json:"stored"`
+	Matches []rules.Match `json:"matches"`
+}
+
+func (h *Handler) handleListRules(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJson(w, http.StatusOK, h.rules.Rules())
+}
+
+// handleTestRules reports the rules that match a URL without setting
+// verdicts or tags.
+func (h *Handler) handleTestRules(w http.ResponseWriter, r *http.Request) {
+	var payload ruleTestPayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteError(w, utils.ParseErrorStatus(err), err)
+		return
+	}
+	if payload.URL == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("url is required"))
+		return
+	}
+
+	matches, stored, err := h.rules.DryRun(r.Context(), payload.Rules, payload.URL)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, ruleTestResult{URL: payload.URL, Stored: stored, Matches: matches})
+}
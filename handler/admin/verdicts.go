@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+)
+
+func (h *Handler) handleListVerdicts(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	scope, classification := params.Get("scope"), params.Get("classification")
+	switch scope {
+	case "", types.ScopeURL, types.ScopeHost, types.ScopeDomain:
+	default:
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("scope must be url, host or domain"))
+		return
+	}
+	switch classification {
+	case "", types.VerdictClean, types.VerdictSuspicious, types.VerdictMalicious:
+	default:
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("classification must be clean, suspicious or malicious"))
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, h.verdicts.List(scope, classification))
+}
+
+func (h *Handler) handleSetVerdict(w http.ResponseWriter, r *http.Request) {
+	var payload types.VerdictPayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteError(w, utils.ParseErrorStatus(err), err)
+		return
+	}
+
+	verdict := types.Verdict{
+		Scope:          payload.Scope,
+		Key:            payload.Key,
+		Classification: payload.Classification,
+		Reasons:        payload.Reasons,
+		SetBy:          middleware.ActorFromContext(r.Context()),
+	}
+	if err := service.NormalizeVerdict(&verdict); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	previous, existed := h.verdicts.Set(verdict)
+	entry := audit.Entry{Action: audit.ActionVerdictSet, Target: verdict.Scope + ":" + verdict.Key, Affected: 1,
+		After: classification(&verdict)}
+	if existed {
+		entry.Before = classification(previous)
+	}
+	record(r, entry)
+
+	saved, _ := h.verdicts.Get(verdict.Scope, verdict.Key)
+	utils.WriteJson(w, http.StatusOK, saved)
+}
+
+func (h *Handler) handleDeleteVerdict(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	scope := params.Get("scope")
+	key, err := service.NormalizeVerdictKey(scope, params.Get("key"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	previous, existed := h.verdicts.Remove(scope, key)
+	if !existed {
+		record(r, audit.Entry{Action: audit.ActionVerdictDelete, Target: scope + ":" + key, Error: "verdict not found"})
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("verdict not found"))
+		return
+	}
+
+	record(r, audit.Entry{Action: audit.ActionVerdictDelete, Target: scope + ":" + key, Affected: 1,
+		Before: classification(previous)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// classification is what the audit log keeps of a verdict.
+func classification(verdict *types.Verdict) map[string]any {
+	return map[string]any{
+		"classification": verdict.Classification,
+		"reasons":        verdict.Reasons,
+		"set_by":         verdict.SetBy,
+	}
+}
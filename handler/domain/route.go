@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middleware.RateLimiter) {
+	slog.Info("registering domain routes")
+
+	router.Handle("/domains", middleware.Limit(http.HandlerFunc(h.handleList))).Methods("GET")
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := utils.DomainQuery{
+		GroupBy:    utils.GroupByRegistrable,
+		Sort:       utils.DomainSortSubmissions,
+		Descending: true,
+		Limit:      config.Envs.MaxPageSize,
+		Cursor:     params.Get("cursor"),
+	}
+
+	switch by := params.Get("by"); by {
+	case "":
+	case utils.GroupByHost, utils.GroupByRegistrable:
+		query.GroupBy = by
+	default:
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("by must be host or registrable"))
+		return
+	}
+
+	if sortField := params.Get("sort"); sortField != "" {
+		if !utils.ValidDomainSort(sortField) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown sort field %q", sortField))
+			return
+		}
+		query.Sort = sortField
+	}
+
+	switch order := params.Get("order"); order {
+	case "":
+	case "asc":
+		query.Descending = false
+	case "desc":
+		query.Descending = true
+	default:
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order must be asc or desc"))
+		return
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("limit must be a positive integer"))
+			return
+		}
+		query.Limit = min(n, query.Limit)
+	}
+
+	domains, next, err := utils.Domains(query)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.SetNextCursor(w, r, next)
+	utils.WriteJson(w, http.StatusOK, domains)
+}
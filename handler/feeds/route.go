@@ -0,0 +1,94 @@
+package feeds
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/feeds"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	generator *feeds.Generator
+}
+
+func NewHandler(generator *feeds.Generator) *Handler {
+	return &Handler{generator: generator}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middleware.RateLimiter) {
+	slog.Info("registering feed routes")
+
+	router.Handle("/feeds", middleware.Limit(http.HandlerFunc(h.handleList))).Methods("GET")
+	router.Handle("/feeds/{name}", middleware.Limit(http.HandlerFunc(h.handleFeed))).Methods("GET")
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	list := make([]types.FeedInfo, 0, len(feeds.Feeds))
+	for _, feed := range feeds.Feeds {
+		info := types.FeedInfo{Name: feed.Name, ContentType: feed.ContentType, Scopes: feed.Scopes}
+		if rendered, exists := h.generator.Get(feed.Name); exists {
+			info.Serial, info.ETag, info.Entries = rendered.Serial, rendered.ETag, rendered.Count
+		}
+		list = append(list, info)
+	}
+	utils.WriteJson(w, http.StatusOK, list)
+}
+
+// handleFeed serves the last generated feed, or with ?since= the entries
+// added and removed since that verdict serial.
+func (h *Handler) handleFeed(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	feed, exists := feeds.Find(name)
+	if !exists {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("feed %q not found", name))
+		return
+	}
+
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		since, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("since must be a serial number"))
+			return
+		}
+		diff, err := h.generator.Diff(feed, since)
+		if errors.Is(err, feeds.ErrSerialExpired) || errors.Is(err, feeds.ErrSerialAhead) {
+			utils.WriteError(w, http.StatusGone, err)
+			return
+		}
+		utils.WriteJson(w, http.StatusOK, diff)
+		return
+	}
+
+	rendered, _ := h.generator.Get(name)
+	w.Header().Set("ETag", rendered.ETag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Feed-Serial", strconv.FormatUint(rendered.Serial, 10))
+	if matchesETag(r.Header.Get("If-None-Match"), rendered.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", feed.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(rendered.Body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(rendered.Body)
+}
+
+// matchesETag reports whether an If-None-Match header names etag, using
+// the weak comparison RFC 9110 asks for.
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
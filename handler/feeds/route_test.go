@@ -0,0 +1,69 @@
+package feeds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/feeds"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func get(router *mux.Router, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleFeed(t *testing.T) {
+	verdicts := service.NewVerdicts(filepath.Join(t.TempDir(), "verdicts.json"))
+	verdicts.Set(types.Verdict{Scope: types.ScopeDomain, Key: "evil.example", Classification: types.VerdictMalicious})
+	generator := feeds.NewGenerator(verdicts)
+	h := NewHandler(generator)
+	router := mux.NewRouter()
+	router.HandleFunc("/feeds", h.handleList)
+	router.HandleFunc("/feeds/{name}", h.handleFeed)
+
+	w := get(router, "/feeds/hosts")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "1", w.Header().Get("X-Feed-Serial"))
+	assert.Contains(t, w.Body.String(), "0.0.0.0 evil.example\n")
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = get(router, "/feeds/hosts", "If-None-Match", `"other", W/`+etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "new.example", Classification: types.VerdictSuspicious})
+	generator.Refresh()
+	assert.Equal(t, http.StatusOK, get(router, "/feeds/hosts", "If-None-Match", etag).Code)
+
+	w = get(router, "/feeds/domains.txt?since=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var diff feeds.Diff
+	json.NewDecoder(w.Body).Decode(&diff)
+	assert.Equal(t, uint64(2), diff.Serial)
+	assert.Equal(t, []any{"new.example"}, diff.Added)
+
+	w = get(router, "/feeds/domains.txt?since=7")
+	assert.Equal(t, http.StatusGone, w.Code, "a serial from the future means a full re-sync")
+	assert.Contains(t, w.Body.String(), "ahead")
+	assert.Equal(t, http.StatusBadRequest, get(router, "/feeds/domains.txt?since=x").Code)
+	assert.Equal(t, http.StatusNotFound, get(router, "/feeds/nope").Code)
+
+	var list []types.FeedInfo
+	json.NewDecoder(get(router, "/feeds").Body).Decode(&list)
+	assert.Len(t, list, len(feeds.Feeds))
+	assert.Equal(t, 2, list[1].Entries)
+}
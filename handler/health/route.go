@@ -0,0 +1,49 @@
+package health
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/health"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/version"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// RegisterRoutes mounts the probes without rate limiting so orchestrators
+// can poll them freely.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	slog.Info("registering health routes")
+
+	router.HandleFunc("/healthz", h.handleLiveness).Methods("GET")
+	router.HandleFunc("/readyz", h.handleReadiness).Methods("GET")
+	router.HandleFunc("/version", h.handleVersion).Methods("GET")
+}
+
+func (h *Handler) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *Handler) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Current()
+	report := health.Readiness(time.Now(), cfg.SnapshotMaxAge(), cfg.FetcherMaxAge())
+
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	utils.WriteJson(w, status, report)
+}
+
+func (h *Handler) handleVersion(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJson(w, http.StatusOK, version.Get())
+}
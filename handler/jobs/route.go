@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	queue *service.Queue
+}
+
+func NewHandler(queue *service.Queue) *Handler {
+	return &Handler{queue: queue}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middleware.RateLimiter) {
+	slog.Info("registering job routes")
+
+	router.Handle("/jobs", middleware.Limit(http.HandlerFunc(h.handleList))).Methods("GET")
+	router.Handle("/jobs/{id}", middleware.Limit(http.HandlerFunc(h.handleGet))).Methods("GET")
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	statuses := []types.JobStatus{types.JobPending, types.JobRunning}
+	if status := r.URL.Query().Get("status"); status != "" {
+		statuses = []types.JobStatus{types.JobStatus(status)}
+	}
+
+	utils.WriteJson(w, http.StatusOK, h.queue.List(statuses...))
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var wait time.Duration
+	if param := r.URL.Query().Get("wait"); param != "" {
+		var err error
+		wait, err = time.ParseDuration(param)
+		if err != nil || wait < 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid wait duration"))
+			return
+		}
+		if max := time.Duration(constants.MAX_JOB_WAIT) * time.Second; wait > max {
+			wait = max
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	job, exists := h.queue.Wait(ctx, id)
+	if !exists {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("job not found"))
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, job)
+}
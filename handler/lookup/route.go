@@ -0,0 +1,45 @@
+package lookup
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	verdicts *service.Verdicts
+}
+
+func NewHandler(verdicts *service.Verdicts) *Handler {
+	return &Handler{verdicts: verdicts}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middleware.RateLimiter) {
+	slog.Info("registering lookup routes")
+
+	router.Handle("/lookup", middleware.Limit(http.HandlerFunc(h.handleLookup))).Methods("GET")
+}
+
+// handleLookup reports the most specific verdict covering a URL. It reads
+// only the verdicts, never the URL store, so it stays fast under load.
+func (h *Handler) handleLookup(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("URL is required"))
+		return
+	}
+
+	result := h.verdicts.Lookup(target)
+	scope := "none"
+	if result.Match != nil {
+		scope = result.Match.Scope
+	}
+	metrics.Lookups.Inc(scope, result.Classification)
+	utils.WriteJson(w, http.StatusOK, result)
+}
@@ -0,0 +1,33 @@
+package lookup
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleLookup(t *testing.T) {
+	verdicts := service.NewVerdicts(filepath.Join(t.TempDir(), "verdicts.json"))
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "bad.example.com", Classification: types.VerdictMalicious,
+		Reasons: []string{"malware"}, SetBy: "token:ops"})
+	handler := NewHandler(verdicts)
+
+	w := httptest.NewRecorder()
+	handler.handleLookup(w, httptest.NewRequest("GET", "/lookup?url=http://bad.example.com/x", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result types.LookupResult
+	json.NewDecoder(w.Body).Decode(&result)
+	assert.True(t, result.Listed)
+	assert.Equal(t, types.VerdictMalicious, result.Classification)
+	assert.Equal(t, []string{"malware"}, result.Match.Reasons)
+
+	w = httptest.NewRecorder()
+	handler.handleLookup(w, httptest.NewRequest("GET", "/lookup", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,136 @@
+package snapshots
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/archive"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/gorilla/mux"
+)
+
+// errDisabled answers every request when no archive directory is set.
+var errDisabled = errors.New("snapshot archive is disabled")
+
+type Handler struct {
+	archive *archive.Archive
+}
+
+// NewHandler serves the snapshots kept in archive, which is nil when the
+// archive is disabled.
+func NewHandler(archive *archive.Archive) *Handler {
+	return &Handler{archive: archive}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middleware.RateLimiter) {
+	slog.Info("registering snapshot routes")
+
+	router.Handle("/url/snapshots", middleware.Limit(http.HandlerFunc(h.handleList))).Methods("GET")
+	router.Handle("/snapshots/{hash}", middleware.Limit(http.HandlerFunc(h.handleDownload))).Methods("GET")
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("URL is required"))
+		return
+	}
+	if h.archive == nil {
+		utils.WriteError(w, http.StatusNotFound, errDisabled)
+		return
+	}
+
+	versions := h.archive.Versions(target)
+	if _, exists := utils.URLStore.Load(target); !exists && len(versions) == 0 {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("URL not found"))
+		return
+	}
+	utils.WriteJson(w, http.StatusOK, types.SnapshotList{URL: target, Snapshots: versions})
+}
+
+// handleDownload sends an archived body as an attachment, never as a page
+// the browser would render: bodies are whatever the fetched URLs served.
+// Clients that accept gzip get the stored copy as is.
+func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request) {
+	hash := mux.Vars(r)["hash"]
+	if len(hash) != 64 || strings.Trim(hash, "0123456789abcdef") != "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("hash must be 64 lower-case hex digits"))
+		return
+	}
+	if h.archive == nil {
+		utils.WriteError(w, http.StatusNotFound, errDisabled)
+		return
+	}
+
+	stored, err := h.archive.Open(hash)
+	if errors.Is(err, archive.ErrNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer stored.Close()
+
+	var body io.Reader = stored
+	if acceptsGzip(r.Header.Values("Accept-Encoding")) {
+		w.Header().Set("Content-Encoding", "gzip")
+	} else {
+		decompressed, err := gzip.NewReader(stored)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		defer decompressed.Close()
+		body = decompressed
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+hash+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		slog.Debug("sending snapshot", "hash", hash, "error", err)
+	}
+}
+
+// acceptsGzip reports whether Accept-Encoding lists gzip, or *, with a
+// non-zero quality. An entry naming gzip overrides *.
+func acceptsGzip(headers []string) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, header := range headers {
+		for _, entry := range strings.Split(header, ",") {
+			coding, params, _ := strings.Cut(entry, ";")
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(param, "=")
+				if strings.EqualFold(strings.TrimSpace(name), "q") {
+					if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+						q = parsed
+					}
+				}
+			}
+			switch strings.ToLower(strings.TrimSpace(coding)) {
+			case "gzip", "x-gzip":
+				gzipQ = q
+			case "*":
+				anyQ = q
+			}
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
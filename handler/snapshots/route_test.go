@@ -0,0 +1,105 @@
+package snapshots
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/archive"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func get(router *mux.Router, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func newRouter(a *archive.Archive) *mux.Router {
+	h := NewHandler(a)
+	router := mux.NewRouter()
+	router.HandleFunc("/url/snapshots", h.handleList)
+	router.HandleFunc("/snapshots/{hash}", h.handleDownload)
+	return router
+}
+
+func TestHandleSnapshots(t *testing.T) {
+	a, err := archive.Open(archive.Options{Dir: t.TempDir(), MaxBody: 1 << 20, MaxBytes: 1 << 30})
+	assert.NoError(t, err)
+	defer a.Close()
+	router := newRouter(a)
+	const target = "http://snapshots.example/login"
+	const page = "<form action=/collect.php></form>"
+	utils.URLStore.Submit(target)
+	t.Cleanup(func() { utils.URLStore.Remove(target) })
+
+	w := get(router, "/url/snapshots?url="+target)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"url":"`+target+`","snapshots":[]}`, w.Body.String())
+
+	a.Store(target, []byte(page), "text/html")
+	w = get(router, "/url/snapshots?url="+target)
+	var list types.SnapshotList
+	json.NewDecoder(w.Body).Decode(&list)
+	assert.Len(t, list.Snapshots, 1)
+	hash := list.Snapshots[0].Hash
+
+	w = get(router, "/snapshots/"+hash)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, page, w.Body.String())
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+	w = get(router, "/snapshots/"+hash, "Accept-Encoding", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	body, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(body)
+	assert.Equal(t, page, string(data))
+
+	w = get(router, "/snapshots/"+hash, "Accept-Encoding", "br, GZIP;q=0")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, page, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get(router, "/snapshots/"+strings.Repeat("0", 64)).Code)
+	assert.Equal(t, http.StatusBadRequest, get(router, "/snapshots/"+strings.ToUpper(hash)).Code)
+	assert.Equal(t, http.StatusBadRequest, get(router, "/snapshots/abc").Code)
+	assert.Equal(t, http.StatusNotFound, get(router, "/url/snapshots?url=http://never-submitted.example/").Code)
+	assert.Equal(t, http.StatusBadRequest, get(router, "/url/snapshots").Code)
+}
+
+func TestHandleSnapshotsDisabled(t *testing.T) {
+	router := newRouter(nil)
+	assert.Equal(t, http.StatusNotFound, get(router, "/url/snapshots?url=http://snapshots.example/").Code)
+	assert.Equal(t, http.StatusNotFound, get(router, "/snapshots/"+strings.Repeat("0", 64)).Code)
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"GZip":                true,
+		"deflate, gzip;q=0.5": true,
+		"gzip;q=0":            false,
+		"gzip; q=0.0, br":     false,
+		"*":                   true,
+		"*;q=0":               false,
+		"*, gzip;q=0":         false,
+		"identity, *;q=0.1":   true,
+		"br, deflate":         false,
+	} {
+		assert.Equal(t, want, acceptsGzip([]string{header}), header)
+	}
+}
@@ -2,48 +2,85 @@ package url
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"sort"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/middleware"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
 	"github.com/gorilla/mux"
 )
 
 type Handler struct {
+	queue *service.Queue
 }
 
-func NewHandler() *Handler {
-	return &Handler{}
+func NewHandler(queue *service.Queue) *Handler {
+	return &Handler{queue: queue}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router, middleware *middleware.RateLimiter) {
-	log.Println("[INFO] Registering URL routes...")
+	slog.Info("registering url routes")
 
 	router.Handle("/url", middleware.Limit(http.HandlerFunc(h.handleSubmit))).Methods("POST")
 	router.Handle("/url", middleware.Limit(http.HandlerFunc(h.handleGet))).Methods("GET")
+	router.Handle("/url/fetch", middleware.Limit(http.HandlerFunc(h.handleFetch))).Methods("POST")
 	router.Handle("/urls", middleware.Limit(http.HandlerFunc(h.handleListAll))).Methods("GET")
+	router.Handle("/urls/trending", middleware.Limit(http.HandlerFunc(h.handleTrending))).Methods("GET")
+	router.Handle("/urls/search", middleware.Limit(http.HandlerFunc(h.handleSearch))).Methods("GET")
+	router.Handle("/stats", middleware.Limit(http.HandlerFunc(h.handleStats))).Methods("GET")
+	router.Handle("/stats/timings", middleware.Limit(http.HandlerFunc(h.handleTimings))).Methods("GET")
 }
 
 func (h *Handler) handleSubmit(w http.ResponseWriter, r *http.Request) {
 	//get JSON payload
-	log.Println("[INFO] Received request to submit URL...")
 	var payload types.RequestUrlPayload
 	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteError(w, utils.ParseErrorStatus(err), err)
+		return
+	}
+
+	if err := utils.NormalizeSubmission(&payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	if _, exists := utils.URLStore.Load(payload.URL); !exists {
-		utils.URLStore.Store(payload.URL, &types.URLData{URL: payload.URL, Count: 1, CreatedAt: time.Now()})
-	} else {
-		if data, ok := utils.URLStore.Load(payload.URL); ok {
-			data.(*types.URLData).Count++
-		}
+	data := utils.URLStore.SubmitWith(payload)
+	utils.Mutex.RLock()
+	count := data.Count
+	utils.Mutex.RUnlock()
+	logger.FromContext(r.Context()).Info("URL submitted", "url", payload.URL, "source", payload.Source)
+	after := map[string]any{"count": count}
+	if payload.Source != "" {
+		after["source"] = payload.Source
+	}
+	if len(payload.Tags) > 0 {
+		after["tags"] = payload.Tags
+	}
+	if payload.Confidence != nil {
+		after["confidence"] = *payload.Confidence
 	}
+	if payload.ReferenceID != "" {
+		after["reference_id"] = payload.ReferenceID
+	}
+	audit.Record(r.Context(), audit.Entry{
+		Actor:    middleware.ActorFromContext(r.Context()),
+		Action:   audit.ActionSubmit,
+		Target:   payload.URL,
+		Affected: 1,
+		Before:   map[string]int{"count": count - 1},
+		After:    after,
+		Client:   middleware.ClientKey(r),
+	})
+	utils.NotifyUpdate(r.Context(), payload.URL)
 	utils.WriteJson(w, http.StatusAccepted, payload)
 }
 
@@ -55,44 +92,278 @@ func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var urls []*types.URLData
+	data, exists := utils.URLStore.Get(query)
+	if !exists {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("URL not found"))
+		return
+	}
 
-	utils.URLStore.Range(func(_, value interface{}) bool {
-		urls = append(urls, value.(*types.URLData))
-		return true
-	})
+	utils.WriteJson(w, http.StatusOK, data)
+}
 
-	urls = utils.FilterByURL(urls, query)
+// handleFetch queues an immediate fetch of a stored URL and returns the job,
+// which can be polled through GET /jobs/{id}.
+func (h *Handler) handleFetch(w http.ResponseWriter, r *http.Request) {
+	var payload types.RequestUrlPayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteError(w, utils.ParseErrorStatus(err), err)
+		return
+	}
 
-	if len(urls) == 0 {
+	if _, exists := utils.URLStore.Load(payload.URL); !exists {
 		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("URL not found"))
 		return
 	}
+	if utils.URLStore.Excluded(payload.URL) {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("URL is excluded from fetching"))
+		return
+	}
 
-	utils.FetchURL(query)
+	job := h.queue.Enqueue(r.Context(), payload.URL, service.PriorityOnDemand)
 
-	utils.WriteJson(w, http.StatusOK, urls[0])
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	utils.WriteJson(w, http.StatusAccepted, job)
 }
 func (h *Handler) handleListAll(w http.ResponseWriter, r *http.Request) {
-	sortOrder := r.URL.Query().Get("sort")
-	var urls []*types.URLData
+	query, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 
-	utils.URLStore.Range(func(_, value interface{}) bool {
-		urls = append(urls, value.(*types.URLData))
-		return true
-	})
+	urls, next, err := utils.ListURLs(query)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.SetNextCursor(w, r, next)
+	utils.WriteJson(w, http.StatusOK, utils.Copies(urls))
+}
+
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := utils.SearchQuery{
+		Pattern: params.Get("q"),
+		Mode:    params.Get("mode"),
+		Host:    params.Get("host"),
+		Path:    params.Get("path"),
+		Params:  map[string]string{},
+		Limit:   config.Envs.MaxPageSize,
+		Cursor:  params.Get("cursor"),
+	}
+
+	if query.Mode == "" {
+		query.Mode = utils.MatchSubstring
+	} else if !utils.ValidMatchMode(query.Mode) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown match mode %q", query.Mode))
+		return
+	}
+
+	var err error
+	if query.MetadataFilter, err = parseMetadataFilter(params); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 
-	if sortOrder == "smallest" {
-		sort.Slice(urls, func(i, j int) bool { return urls[i].Count < urls[j].Count })
-	} else {
-		sort.Slice(urls, func(i, j int) bool {
-			return urls[i].CreatedAt.Format(time.RFC3339) > urls[j].CreatedAt.Format(time.RFC3339)
-		})
+	for _, param := range params["param"] {
+		name, value, _ := strings.Cut(param, "=")
+		query.Params[name] = value
 	}
 
-	if len(urls) > 50 {
-		urls = urls[:50]
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("limit must be a positive integer"))
+			return
+		}
+		query.Limit = min(n, query.Limit)
+	}
+
+	urls, next, err := utils.Search(query)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.SetNextCursor(w, r, next)
+	utils.WriteJson(w, http.StatusOK, utils.Copies(urls))
+}
+
+// parseMetadataFilter reads the source, tag and min_confidence filters
+// shared by GET /urls and GET /urls/search.
+func parseMetadataFilter(params url.Values) (utils.MetadataFilter, error) {
+	filter := utils.MetadataFilter{
+		Source: params.Get("source"),
+		Tag:    params.Get("tag"),
+	}
+	if minConfidence := params.Get("min_confidence"); minConfidence != "" {
+		n, err := strconv.Atoi(minConfidence)
+		if err != nil || n < 0 || n > 100 {
+			return filter, fmt.Errorf("min_confidence must be an integer between 0 and 100")
+		}
+		filter.MinConfidence = n
+	}
+	return filter, nil
+}
+
+// parseListQuery reads paging, sorting and filter parameters for GET /urls.
+// sort=smallest is kept as an alias for ascending count.
+func parseListQuery(params url.Values) (utils.ListQuery, error) {
+	maxPageSize := config.Envs.MaxPageSize
+	query := utils.ListQuery{
+		Limit:      maxPageSize,
+		Cursor:     params.Get("cursor"),
+		Sort:       utils.SortCreatedAt,
+		Descending: true,
+		Domain:     params.Get("domain"),
+		Scheme:     params.Get("scheme"),
+		Status:     params.Get("status"),
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return query, fmt.Errorf("limit must be a positive integer")
+		}
+		query.Limit = min(n, maxPageSize)
+	}
+
+	switch sortField := params.Get("sort"); {
+	case sortField == "smallest":
+		query.Sort = utils.SortCount
+		query.Descending = false
+	case sortField == "" || sortField == "latest":
+	case utils.ValidSort(sortField):
+		query.Sort = sortField
+	default:
+		return query, fmt.Errorf("unknown sort field %q", sortField)
+	}
+
+	switch order := params.Get("order"); order {
+	case "":
+	case "asc":
+		query.Descending = false
+	case "desc":
+		query.Descending = true
+	default:
+		return query, fmt.Errorf("order must be asc or desc")
+	}
+
+	switch query.Status {
+	case "", types.FetchStatusOK, types.FetchStatusNotModified, types.FetchStatusFailed, types.FetchStatusHTTPError,
+		types.FetchStatusNever:
+	default:
+		return query, fmt.Errorf("unknown fetch status %q", query.Status)
+	}
+
+	var err error
+	if query.MetadataFilter, err = parseMetadataFilter(params); err != nil {
+		return query, err
+	}
+
+	if minCount := params.Get("min_count"); minCount != "" {
+		n, err := strconv.Atoi(minCount)
+		if err != nil {
+			return query, fmt.Errorf("min_count must be an integer")
+		}
+		query.MinCount = n
+	}
+
+	for name, target := range map[string]*time.Time{
+		"created_after":  &query.CreatedAfter,
+		"created_before": &query.CreatedBefore,
+	} {
+		if value := params.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*target = t
+		}
+	}
+
+	return query, nil
+}
+
+func (h *Handler) handleTrending(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	window := time.Hour
+	if param := params.Get("window"); param != "" {
+		var err error
+		if window, err = time.ParseDuration(param); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid window duration"))
+			return
+		}
+	}
+
+	limit := config.Envs.MaxPageSize
+	if param := params.Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("limit must be a positive integer"))
+			return
+		}
+		limit = min(n, limit)
+	}
+
+	trending, err := utils.Trending(window, limit)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, trending)
+}
+
+func (h *Handler) handleStats(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJson(w, http.StatusOK, utils.CollectStats())
+}
+
+// handleTimings reports fetch timing percentiles for one URL (?url=), one
+// host (?host=), or every URL or host (?by=url|host), slowest first.
+func (h *Handler) handleTimings(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	if u := params.Get("url"); u != "" {
+		stats, exists := utils.URLStore.URLTimings(u)
+		if !exists {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("URL not found"))
+			return
+		}
+		utils.WriteJson(w, http.StatusOK, stats)
+		return
+	}
+	if host := params.Get("host"); host != "" {
+		stats, exists := utils.URLStore.HostTimings(strings.ToLower(host))
+		if !exists {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("host not found"))
+			return
+		}
+		utils.WriteJson(w, http.StatusOK, stats)
+		return
+	}
+
+	by := params.Get("by")
+	switch by {
+	case "":
+		by = utils.TimingsByHost
+	case utils.TimingsByURL, utils.TimingsByHost:
+	default:
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("by must be url or host"))
+		return
+	}
+
+	limit := config.Envs.MaxPageSize
+	if param := params.Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("limit must be a positive integer"))
+			return
+		}
+		limit = min(n, limit)
 	}
 
-	utils.WriteJson(w, http.StatusOK, urls)
+	utils.WriteJson(w, http.StatusOK, utils.URLStore.Timings(by, limit))
 }
@@ -2,15 +2,21 @@ package url
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestHandleSubmit(t *testing.T) {
@@ -240,3 +246,180 @@ func TestHandleListAll_LimitTo50(t *testing.T) {
 		t.Errorf("Expected at most 50 URLs but got %d", len(urls))
 	}
 }
+
+func TestHandleFetch_QueuesJob(t *testing.T) {
+	queue := service.NewQueue(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, string) error { return nil })
+	handler := NewHandler(queue)
+
+	testURL := "http://example-fetch.com"
+	utils.URLStore.Store(testURL, &types.URLData{URL: testURL, Count: 1})
+
+	jsonPayload, _ := json.Marshal(types.RequestUrlPayload{URL: testURL})
+	req := httptest.NewRequest("POST", "/url/fetch", bytes.NewBuffer(jsonPayload))
+	w := httptest.NewRecorder()
+
+	handler.handleFetch(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected status %d but got %d", http.StatusAccepted, res.StatusCode)
+	}
+
+	var job types.Job
+	json.NewDecoder(res.Body).Decode(&job)
+	if job.ID == "" || job.URL != testURL || job.Priority != service.PriorityOnDemand {
+		t.Errorf("Expected an on-demand job for %s but got %+v", testURL, job)
+	}
+	if location := res.Header.Get("Location"); location != "/api/v1/jobs/"+job.ID {
+		t.Errorf("Expected Location header for job %s but got %q", job.ID, location)
+	}
+}
+
+func TestHandleFetch_NotFound(t *testing.T) {
+	queue := service.NewQueue(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, string) error { return nil })
+	handler := NewHandler(queue)
+
+	jsonPayload, _ := json.Marshal(types.RequestUrlPayload{URL: "http://notfound.com"})
+	req := httptest.NewRequest("POST", "/url/fetch", bytes.NewBuffer(jsonPayload))
+	w := httptest.NewRecorder()
+
+	handler.handleFetch(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d but got %d", http.StatusNotFound, res.StatusCode)
+	}
+}
+
+func TestHandleListAll_Cursor(t *testing.T) {
+	handler := &Handler{}
+
+	for i := 1; i <= 3; i++ {
+		u := fmt.Sprintf("http://cursor%d.example.org", i)
+		utils.URLStore.Store(u, &types.URLData{URL: u, Count: 100 + i, CreatedAt: time.Now()})
+	}
+
+	req := httptest.NewRequest("GET", "/urls?domain=example.org&sort=count&order=desc&limit=2", nil)
+	w := httptest.NewRecorder()
+	handler.handleListAll(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	var urls []types.URLData
+	json.NewDecoder(res.Body).Decode(&urls)
+	if len(urls) != 2 || urls[0].URL != "http://cursor3.example.org" {
+		t.Fatalf("Expected first page to start with cursor3, got %v", urls)
+	}
+	next := res.Header.Get("X-Next-Cursor")
+	if next == "" {
+		t.Fatalf("Expected a next cursor")
+	}
+
+	req = httptest.NewRequest("GET", "/urls?domain=example.org&sort=count&order=desc&limit=2&cursor="+next, nil)
+	w = httptest.NewRecorder()
+	handler.handleListAll(w, req)
+
+	urls = nil
+	json.NewDecoder(w.Result().Body).Decode(&urls)
+	if len(urls) != 1 || urls[0].URL != "http://cursor1.example.org" {
+		t.Errorf("Expected second page to hold cursor1 only, got %v", urls)
+	}
+	if w.Result().Header.Get("X-Next-Cursor") != "" {
+		t.Errorf("Expected no cursor on the last page")
+	}
+}
+
+func TestHandleListAll_InvalidSort(t *testing.T) {
+	handler := &Handler{}
+
+	req := httptest.NewRequest("GET", "/urls?sort=bogus", nil)
+	w := httptest.NewRecorder()
+	handler.handleListAll(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d but got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestHandleTimings_BadRequests(t *testing.T) {
+	handler := &Handler{}
+
+	for target, want := range map[string]int{
+		"/stats/timings?by=bogus":                     http.StatusBadRequest,
+		"/stats/timings?limit=0":                      http.StatusBadRequest,
+		"/stats/timings?url=https://missing.example/": http.StatusNotFound,
+		"/stats/timings?host=missing.example":         http.StatusNotFound,
+		"/stats/timings?by=url":                       http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		handler.handleTimings(w, req)
+
+		if w.Code != want {
+			t.Errorf("%s: expected status %d but got %d", target, want, w.Code)
+		}
+	}
+}
+
+func TestHandleSubmitIsAudited(t *testing.T) {
+	assert.NoError(t, audit.Open(audit.Options{Path: filepath.Join(t.TempDir(), "audit.log")}))
+	defer audit.Close()
+
+	handler := &Handler{}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/url", bytes.NewBufferString(`{"url":"http://audited.example.com"}`))
+		req = req.WithContext(logger.WithRequestID(req.Context(), fmt.Sprint("submit-", i)))
+		handler.handleSubmit(httptest.NewRecorder(), req)
+	}
+
+	entries, err := audit.Query(audit.Filter{Action: audit.ActionSubmit, Target: "http://audited.example.com"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "anonymous", entries[0].Actor)
+	assert.Equal(t, "submit-1", entries[0].RequestID)
+	assert.Equal(t, "192.0.2.1", entries[0].Client)
+	assert.Equal(t, map[string]any{"count": float64(1)}, entries[0].Before)
+	assert.Equal(t, map[string]any{"count": float64(2)}, entries[0].After)
+}
+
+func TestHandleSubmit_Metadata(t *testing.T) {
+	handler := &Handler{}
+	submit := func(body string) int {
+		w := httptest.NewRecorder()
+		handler.handleSubmit(w, httptest.NewRequest("POST", "/url", bytes.NewBufferString(body)))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, submit(`{"url":"http://meta.example.com","source":"feed-a","tags":["Phishing"],"confidence":70,"reference_id":"T-1"}`))
+	assert.Equal(t, http.StatusAccepted, submit(`{"url":"http://meta.example.com","source":"feed-b","tags":["phishing","bank"]}`))
+	assert.Equal(t, http.StatusBadRequest, submit(`{"url":"http://meta.example.com","confidence":70}`))
+	assert.Equal(t, http.StatusBadRequest, submit(`{"url":"http://meta.example.com","source":"feed a"}`))
+
+	value, _ := utils.URLStore.Load("http://meta.example.com")
+	data := value.(*types.URLData)
+	assert.Equal(t, map[string]int{"phishing": 2, "bank": 1}, data.Tags)
+	assert.Equal(t, "T-1", data.Sources["feed-a"].ReferenceID)
+	assert.Equal(t, 1, data.Sources["feed-b"].Submissions)
+
+	w := httptest.NewRecorder()
+	handler.handleListAll(w, httptest.NewRequest("GET", "/urls?source=feed-b&tag=bank&min_confidence=60", nil))
+	var urls []types.URLData
+	json.NewDecoder(w.Body).Decode(&urls)
+	assert.Len(t, urls, 1)
+	assert.Equal(t, "http://meta.example.com", urls[0].URL)
+
+	w = httptest.NewRecorder()
+	handler.handleSearch(w, httptest.NewRequest("GET", "/urls/search?q=meta.example&source=feed-c", nil))
+	urls = nil
+	json.NewDecoder(w.Body).Decode(&urls)
+	assert.Empty(t, urls)
+
+	w = httptest.NewRecorder()
+	handler.handleSearch(w, httptest.NewRequest("GET", "/urls/search?q=meta&min_confidence=high", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,404 @@
+// Package hashes keeps the set of known-bad content hashes and checks
+// fetched bodies against it. A body matches a hash when its SHA-256 is the
+// same, or when its fuzzy hash scores at least hash_match_threshold against
+// a known fuzzy hash; the URL then gets the hash's verdict.
+package hashes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/fuzzyhash"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+)
+
+const maxNameLength = 128
+
+// Set is the known-bad hashes, saved to a file after every change.
+type Set struct {
+	path     string
+	verdicts *service.Verdicts
+
+	mutex   sync.RWMutex
+	byValue map[string]*types.KnownHash
+	// fuzzy holds the fuzzy hashes parsed, by block size and value, so a
+	// body is only compared against those it can score against.
+	fuzzy map[uint32]map[string]fuzzyhash.Digest
+
+	// dirty is set under mutex when the hashes change; flush writes them
+	// out under saving, so matching never waits on the disk.
+	dirty  bool
+	saving sync.Mutex
+
+	scheduler *service.Scheduler
+
+	// rescanning is set while a background rescan runs, and again when the
+	// set changed during it and it must run once more.
+	rescanMutex sync.Mutex
+	rescanning  bool
+	again       bool
+}
+
+// NewSet keeps its hashes in path and stores the verdicts of matches in
+// verdicts.
+func NewSet(path string, verdicts *service.Verdicts) *Set {
+	s := &Set{path: path, verdicts: verdicts, byValue: map[string]*types.KnownHash{},
+		fuzzy: map[uint32]map[string]fuzzyhash.Digest{}}
+	s.scheduler = service.NewScheduler(func(ctx context.Context, rawURL string) { s.Apply(ctx, rawURL) })
+	return s
+}
+
+// Start launches workers goroutines that Apply the set to the URLs passed
+// to Schedule.
+func (s *Set) Start(workers int) {
+	s.scheduler.Start(workers)
+}
+
+// Schedule queues rawURL for Apply, merging repeat updates to a URL that
+// is still waiting.
+func (s *Set) Schedule(ctx context.Context, rawURL string) {
+	s.scheduler.Schedule(ctx, rawURL)
+}
+
+// Pending returns how many URLs wait for Apply.
+func (s *Set) Pending() int {
+	return s.scheduler.Pending()
+}
+
+// Load restores the hashes saved by a previous run.
+func (s *Set) Load() {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		slog.Info("no existing hashes file found, starting with none", "file", s.path)
+		return
+	}
+	var saved []*types.KnownHash
+	if err := json.Unmarshal(data, &saved); err != nil {
+		slog.Error("loading hashes file", "file", s.path, "error", err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, hash := range saved {
+		s.put(hash)
+	}
+	slog.Info("restored known-bad hashes", "count", len(s.byValue))
+}
+
+// Normalize checks hash, tells its type from its value when it is not
+// given, lower-cases SHA-256 digests and defaults the classification to
+// malicious.
+func Normalize(hash *types.KnownHash) error {
+	hash.Value = strings.TrimSpace(hash.Value)
+	if hash.Type == "" {
+		hash.Type = types.HashSHA256
+		if strings.Contains(hash.Value, ":") {
+			hash.Type = types.HashFuzzy
+		}
+	}
+	switch hash.Type {
+	case types.HashSHA256:
+		hash.Value = strings.ToLower(hash.Value)
+		if len(hash.Value) != 64 || strings.Trim(hash.Value, "0123456789abcdef") != "" {
+			return fmt.Errorf("sha256 hashes must be 64 hex digits, got %q", hash.Value)
+		}
+	case types.HashFuzzy:
+		if !fuzzyhash.Valid(hash.Value) {
+			return fmt.Errorf("fuzzy hashes must be blocksize:digest:digest, got %q", hash.Value)
+		}
+		if !fuzzyhash.Comparable(hash.Value) {
+			return fmt.Errorf("fuzzy hash %q is of too little content to match on", hash.Value)
+		}
+	default:
+		return fmt.Errorf("type must be sha256 or fuzzy, got %q", hash.Type)
+	}
+
+	if hash.Classification == "" {
+		hash.Classification = types.VerdictMalicious
+	}
+	if hash.Classification != types.VerdictSuspicious && hash.Classification != types.VerdictMalicious {
+		return fmt.Errorf("classification must be suspicious or malicious, got %q", hash.Classification)
+	}
+	if len(hash.Name) > maxNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxNameLength)
+	}
+	return nil
+}
+
+// Add stores hashes, which must be normalized, replacing any with the same
+// value, and returns how many were new. It adds none when that would make
+// the set larger than MAX_KNOWN_HASHES.
+func (s *Set) Add(hashes []types.KnownHash) (int, error) {
+	defer s.flush()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	added := 0
+	for _, hash := range hashes {
+		if _, exists := s.byValue[hash.Value]; !exists {
+			added++
+		}
+	}
+	if len(s.byValue)+added > constants.MAX_KNOWN_HASHES {
+		return 0, fmt.Errorf("at most %d known hashes; %d are stored", constants.MAX_KNOWN_HASHES, len(s.byValue))
+	}
+	now := time.Now().UTC()
+	for _, hash := range hashes {
+		if hash.AddedAt.IsZero() {
+			hash.AddedAt = now
+		}
+		s.put(&hash)
+	}
+	s.dirty = true
+	return added, nil
+}
+
+// put stores hash, parsing it once if it is fuzzy. It must be called with
+// the mutex held.
+func (s *Set) put(hash *types.KnownHash) {
+	s.byValue[hash.Value] = hash
+	if hash.Type != types.HashFuzzy {
+		return
+	}
+	digest, err := fuzzyhash.Parse(hash.Value)
+	if err != nil {
+		slog.Error("skipping invalid fuzzy hash", "hash", hash.Value, "error", err)
+		return
+	}
+	if s.fuzzy[digest.BlockSize()] == nil {
+		s.fuzzy[digest.BlockSize()] = map[string]fuzzyhash.Digest{}
+	}
+	s.fuzzy[digest.BlockSize()][hash.Value] = digest
+}
+
+// Remove deletes the hash with value and returns it.
+func (s *Set) Remove(value string) (types.KnownHash, bool) {
+	defer s.flush()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	hash, exists := s.byValue[value]
+	if !exists {
+		return types.KnownHash{}, false
+	}
+	delete(s.byValue, value)
+	for blockSize, digests := range s.fuzzy {
+		delete(digests, value)
+		if len(digests) == 0 {
+			delete(s.fuzzy, blockSize)
+		}
+	}
+	s.dirty = true
+	return *hash, true
+}
+
+// List returns every hash, ordered by type and value.
+func (s *Set) List() []types.KnownHash {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	hashes := make([]types.KnownHash, 0, len(s.byValue))
+	for _, hash := range s.byValue {
+		hashes = append(hashes, *hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		if hashes[i].Type != hashes[j].Type {
+			return hashes[i].Type < hashes[j].Type
+		}
+		return hashes[i].Value < hashes[j].Value
+	})
+	return hashes
+}
+
+// Count returns how many hashes are stored.
+func (s *Set) Count() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.byValue)
+}
+
+// Match returns the known hash a body with contentHash and fuzzyHash
+// matches: an exact SHA-256 first, else the fuzzy hash scoring highest,
+// if it reaches threshold.
+func (s *Set) Match(contentHash, fuzzyHash string, threshold int) *types.HashMatch {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if known, exists := s.byValue[contentHash]; exists && contentHash != "" && known.Type == types.HashSHA256 {
+		return newMatch(known, 100)
+	}
+	digest, err := fuzzyhash.Parse(fuzzyHash)
+	if err != nil {
+		return nil
+	}
+	blockSizes := []uint32{digest.BlockSize(), digest.BlockSize() * 2}
+	if digest.BlockSize()%2 == 0 {
+		blockSizes = append(blockSizes, digest.BlockSize()/2)
+	}
+	var best *types.KnownHash
+	bestScore := 0
+	for _, blockSize := range blockSizes {
+		for value, known := range s.fuzzy[blockSize] {
+			score := digest.Compare(known)
+			// Break ties by value so the same body always matches the same
+			// hash.
+			if score >= threshold && (best == nil || score > bestScore || score == bestScore && value < best.Value) {
+				best, bestScore = s.byValue[value], score
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return newMatch(best, bestScore)
+}
+
+func newMatch(known *types.KnownHash, score int) *types.HashMatch {
+	return &types.HashMatch{
+		Type:           known.Type,
+		Hash:           known.Value,
+		Name:           known.Name,
+		Classification: known.Classification,
+		Score:          score,
+		MatchedAt:      time.Now().UTC(),
+	}
+}
+
+// Apply checks the last full body fetched from rawURL against the set and
+// records the result on its record. On a new match it audits the match.
+// On every match it sets the hash's verdict on the URL, unless an analyst
+// set one, so a verdict another source replaced is restored on the next
+// fetch; a body that no longer matches keeps its verdict. It reports
+// whether rawURL newly matched.
+func (s *Set) Apply(ctx context.Context, rawURL string) bool {
+	contentHash, fuzzyHash, exists := utils.URLStore.ContentHashes(rawURL)
+	if !exists {
+		return false
+	}
+	match := s.Match(contentHash, fuzzyHash, config.Current().HashMatchThreshold)
+	newly := utils.URLStore.SetHashMatch(rawURL, match)
+	if match == nil {
+		return false
+	}
+
+	actor := "hash:" + label(match)
+	if newly {
+		metrics.HashMatches.Inc(match.Type)
+		audit.Record(ctx, audit.Entry{Actor: actor, Action: audit.ActionHashMatch, Target: rawURL, Affected: 1,
+			After: map[string]any{"type": match.Type, "hash": match.Hash, "name": match.Name, "score": match.Score}})
+		slog.Warn("fetched content matches a known-bad hash", "url", rawURL, "type", match.Type, "hash", match.Hash,
+			"name", match.Name, "score", match.Score)
+	}
+
+	verdict := types.Verdict{Scope: types.ScopeURL, Key: rawURL, Classification: match.Classification,
+		Reasons: []string{"content matches known-bad hash " + label(match)}, SetBy: actor}
+	previous, existed, stored := s.verdicts.SetAutomatic(verdict)
+	if stored {
+		entry := audit.Entry{Actor: actor, Action: audit.ActionVerdictSet, Target: verdict.Scope + ":" + verdict.Key,
+			Affected: 1, After: map[string]any{"classification": verdict.Classification, "reasons": verdict.Reasons}}
+		if existed {
+			entry.Before = map[string]any{"classification": previous.Classification, "reasons": previous.Reasons,
+				"set_by": previous.SetBy}
+		}
+		audit.Record(ctx, entry)
+	}
+	return newly
+}
+
+// Rescan applies the set to every stored URL with a fetched body, as after
+// the set changed, and returns the URLs that newly matched, in order.
+func (s *Set) Rescan(ctx context.Context) []string {
+	var urls []string
+	utils.URLStore.Range(func(key, _ interface{}) bool {
+		urls = append(urls, key.(string))
+		return true
+	})
+	matched := []string{}
+	for _, url := range urls {
+		if s.Apply(ctx, url) {
+			matched = append(matched, url)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+// RescanLater runs Rescan in the background, as after the set changed.
+// Calls made while a rescan runs are merged into one more rescan after it.
+func (s *Set) RescanLater(ctx context.Context) {
+	s.rescanMutex.Lock()
+	defer s.rescanMutex.Unlock()
+	if s.rescanning {
+		s.again = true
+		return
+	}
+	s.rescanning = true
+	go func() {
+		for {
+			s.Rescan(ctx)
+			s.rescanMutex.Lock()
+			if !s.again {
+				s.rescanning = false
+				s.rescanMutex.Unlock()
+				return
+			}
+			s.again = false
+			s.rescanMutex.Unlock()
+		}
+	}()
+}
+
+// label names a match in verdict reasons and actors: by the hash's name,
+// else by the start of its value.
+func label(match *types.HashMatch) string {
+	if match.Name != "" {
+		return match.Name
+	}
+	return match.Hash[:min(16, len(match.Hash))]
+}
+
+// flush writes the hashes to disk if they changed since the last write.
+// It must be called without the mutex held; only the list of hashes,
+// which are never changed in place, is taken under it.
+func (s *Set) flush() {
+	s.saving.Lock()
+	defer s.saving.Unlock()
+
+	s.mutex.Lock()
+	if !s.dirty {
+		s.mutex.Unlock()
+		return
+	}
+	s.dirty = false
+	saved := make([]*types.KnownHash, 0, len(s.byValue))
+	for _, hash := range s.byValue {
+		saved = append(saved, hash)
+	}
+	s.mutex.Unlock()
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		slog.Error("marshaling hashes", "error", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		slog.Error("writing hashes file", "file", tmp, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		slog.Error("replacing hashes file", "file", s.path, "error", err)
+	}
+}
@@ -0,0 +1,202 @@
+package hashes
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/fuzzyhash"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const badHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+// kit is a phishing page; a copy with a changed heading is still close to
+// it.
+var kit = strings.Repeat("<form action=/collect.php><input name=user><input type=password name=pass></form>\n", 20) +
+	strings.Repeat("<p>Your account has been suspended. Verify your details to restore access.</p>\n", 20)
+
+func newSet(t *testing.T) (*Set, *service.Verdicts, string) {
+	dir := t.TempDir()
+	verdicts := service.NewVerdicts(filepath.Join(dir, "verdicts.json"))
+	path := filepath.Join(dir, "hashes.json")
+	return NewSet(path, verdicts), verdicts, path
+}
+
+// fetched stores url as if its body had just been fetched.
+func fetched(t *testing.T, url, contentHash, body string) {
+	utils.URLStore.Submit(url)
+	t.Cleanup(func() { utils.URLStore.Remove(url) })
+	value, _ := utils.URLStore.Load(url)
+	utils.Mutex.Lock()
+	value.(*types.URLData).ContentHash = contentHash
+	value.(*types.URLData).FuzzyHash = fuzzyhash.Hash([]byte(body))
+	utils.Mutex.Unlock()
+}
+
+func hashMatch(url string) *types.HashMatch {
+	value, _ := utils.URLStore.Load(url)
+	utils.Mutex.RLock()
+	defer utils.Mutex.RUnlock()
+	return value.(*types.URLData).HashMatch
+}
+
+func TestNormalize(t *testing.T) {
+	hash := types.KnownHash{Value: " " + strings.ToUpper(badHash) + " "}
+	assert.NoError(t, Normalize(&hash))
+	assert.Equal(t, types.KnownHash{Type: types.HashSHA256, Value: badHash, Classification: types.VerdictMalicious}, hash)
+
+	hash = types.KnownHash{Value: fuzzyhash.Hash([]byte(kit)), Classification: types.VerdictSuspicious}
+	assert.NoError(t, Normalize(&hash))
+	assert.Equal(t, types.HashFuzzy, hash.Type)
+
+	for _, bad := range []types.KnownHash{
+		{Value: "abc"},
+		{Type: types.HashFuzzy, Value: badHash},
+		{Value: "3::"},
+		{Type: "md5", Value: badHash},
+		{Value: badHash, Classification: types.VerdictClean},
+		{Value: badHash, Name: strings.Repeat("x", 129)},
+	} {
+		assert.Error(t, Normalize(&bad), "%+v", bad)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	set, _, path := newSet(t)
+	kitHash := fuzzyhash.Hash([]byte(kit))
+	added, err := set.Add([]types.KnownHash{
+		{Type: types.HashSHA256, Value: badHash, Name: "kit-a", Classification: types.VerdictMalicious},
+		{Type: types.HashFuzzy, Value: kitHash, Name: "kit-b", Classification: types.VerdictSuspicious},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	match := set.Match(badHash, kitHash, 80)
+	assert.Equal(t, "kit-a", match.Name)
+	assert.Equal(t, 100, match.Score)
+
+	variant := fuzzyhash.Hash([]byte(strings.Replace(kit, "suspended", "locked", 1)))
+	match = set.Match("", variant, 80)
+	assert.Equal(t, "kit-b", match.Name)
+	assert.GreaterOrEqual(t, match.Score, 80)
+	assert.Less(t, match.Score, 100)
+	assert.Nil(t, set.Match("", variant, 100))
+	assert.Nil(t, set.Match("", fuzzyhash.Hash([]byte(strings.Repeat("unrelated page ", 200))), 1))
+
+	// The set survives a restart.
+	restored := NewSet(path, service.NewVerdicts(filepath.Join(t.TempDir(), "verdicts.json")))
+	restored.Load()
+	assert.Equal(t, set.List(), restored.List())
+
+	removed, existed := set.Remove(badHash)
+	assert.True(t, existed)
+	assert.Equal(t, "kit-a", removed.Name)
+	assert.Nil(t, set.Match(badHash, "", 80))
+}
+
+func TestApply(t *testing.T) {
+	set, verdicts, _ := newSet(t)
+	ctx := context.Background()
+	target := "http://hashes.example/login"
+	fetched(t, target, badHash, kit)
+
+	assert.False(t, set.Apply(ctx, target))
+	assert.Nil(t, hashMatch(target))
+
+	set.Add([]types.KnownHash{{Type: types.HashSHA256, Value: badHash, Name: "kit-a", Classification: types.VerdictMalicious}})
+	assert.True(t, set.Apply(ctx, target))
+	assert.Equal(t, "kit-a", hashMatch(target).Name)
+	verdict, exists := verdicts.Get(types.ScopeURL, target)
+	assert.True(t, exists)
+	assert.Equal(t, types.VerdictMalicious, verdict.Classification)
+	assert.Equal(t, "hash:kit-a", verdict.SetBy)
+
+	// Matching the same hash again is not news.
+	matchedAt := hashMatch(target).MatchedAt
+	assert.False(t, set.Apply(ctx, target))
+	assert.Equal(t, matchedAt, hashMatch(target).MatchedAt)
+
+	// A verdict set by an admin is left alone.
+	other := "http://hashes.example/other"
+	fetched(t, other, "", kit)
+	verdicts.Set(types.Verdict{Scope: types.ScopeURL, Key: other, Classification: types.VerdictClean, SetBy: "token:ops"})
+	set.Add([]types.KnownHash{{Type: types.HashFuzzy, Value: fuzzyhash.Hash([]byte(kit)), Classification: types.VerdictSuspicious}})
+	assert.Equal(t, []string{other}, set.Rescan(ctx))
+	assert.Equal(t, types.HashFuzzy, hashMatch(other).Type)
+	verdict, _ = verdicts.Get(types.ScopeURL, other)
+	assert.Equal(t, "token:ops", verdict.SetBy)
+
+	// Once the hash is gone the match is cleared; the verdict stays.
+	set.Remove(badHash)
+	set.Remove(fuzzyhash.Hash([]byte(kit)))
+	assert.Empty(t, set.Rescan(ctx))
+	assert.Nil(t, hashMatch(target))
+	_, exists = verdicts.Get(types.ScopeURL, target)
+	assert.True(t, exists)
+
+	assert.False(t, set.Apply(ctx, "http://never-submitted.example/"))
+}
+
+func TestApplyRestoresHashVerdict(t *testing.T) {
+	set, verdicts, _ := newSet(t)
+	ctx := context.Background()
+	target := "http://hashes.example/restore"
+	fetched(t, target, badHash, kit)
+	set.Add([]types.KnownHash{{Type: types.HashSHA256, Value: badHash, Name: "kit-a", Classification: types.VerdictMalicious}})
+	assert.True(t, set.Apply(ctx, target))
+
+	// A rule does not downgrade the hash's verdict.
+	for _, classification := range []string{types.VerdictClean, types.VerdictSuspicious} {
+		_, _, stored := verdicts.SetAutomatic(types.Verdict{Scope: types.ScopeURL, Key: target,
+			Classification: classification, SetBy: "rule:x"})
+		assert.False(t, stored, classification)
+	}
+	verdict, _ := verdicts.Get(types.ScopeURL, target)
+	assert.Equal(t, "hash:kit-a", verdict.SetBy)
+
+	// A verdict the rule had set first is replaced, and the next fetch of
+	// the same body restores the hash's verdict after the rule lowers its own.
+	verdicts.Remove(types.ScopeURL, target)
+	verdicts.SetAutomatic(types.Verdict{Scope: types.ScopeURL, Key: target, Classification: types.VerdictMalicious, SetBy: "rule:x"})
+	verdicts.SetAutomatic(types.Verdict{Scope: types.ScopeURL, Key: target, Classification: types.VerdictClean, SetBy: "rule:x"})
+	assert.False(t, set.Apply(ctx, target), "the match is not new")
+	verdict, _ = verdicts.Get(types.ScopeURL, target)
+	assert.Equal(t, types.VerdictMalicious, verdict.Classification)
+	assert.Equal(t, "hash:kit-a", verdict.SetBy)
+}
+
+func TestRescanLater(t *testing.T) {
+	set, _, _ := newSet(t)
+	target := "http://hashes.example/later"
+	fetched(t, target, badHash, kit)
+
+	set.RescanLater(context.Background())
+	set.Add([]types.KnownHash{{Type: types.HashSHA256, Value: badHash, Name: "kit-a", Classification: types.VerdictMalicious}})
+	// A change made while a rescan may be running is still picked up.
+	set.RescanLater(context.Background())
+	assert.Eventually(t, func() bool { return hashMatch(target) != nil }, time.Second, 5*time.Millisecond)
+}
+
+func TestSchedule(t *testing.T) {
+	set, verdicts, _ := newSet(t)
+	target := "http://hashes.example/scheduled"
+	fetched(t, target, badHash, kit)
+	set.Add([]types.KnownHash{{Type: types.HashSHA256, Value: badHash, Name: "kit-a", Classification: types.VerdictMalicious}})
+
+	for range 10 {
+		set.Schedule(context.Background(), target)
+	}
+	assert.Equal(t, 1, set.Pending(), "repeat updates are merged")
+
+	set.Start(1)
+	assert.Eventually(t, func() bool {
+		_, exists := verdicts.Get(types.ScopeURL, target)
+		return exists
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,110 @@
+// Package health tracks the state behind the liveness and readiness probes:
+// whether the store has been loaded, when the last snapshot succeeded and
+// whether the background fetch loop is still ticking.
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+type Check struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type Report struct {
+	Ready  bool             `json:"ready"`
+	Checks map[string]Check `json:"checks"`
+}
+
+var (
+	mutex            sync.Mutex
+	startedAt        = time.Now()
+	storeLoaded      bool
+	lastSnapshot     time.Time
+	lastSnapshotErr  error
+	fetcherHeartbeat time.Time
+)
+
+// MarkStoreLoaded records that the persisted store has been read.
+func MarkStoreLoaded() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	storeLoaded = true
+}
+
+// RecordSnapshot records the outcome of a snapshot save.
+func RecordSnapshot(err error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	lastSnapshotErr = err
+	if err == nil {
+		lastSnapshot = time.Now()
+	}
+}
+
+// FetcherHeartbeat records that the background fetch loop ran.
+func FetcherHeartbeat() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	fetcherHeartbeat = time.Now()
+}
+
+// Readiness evaluates every check at now. A snapshot or heartbeat that has
+// not happened yet counts from process start, so a fresh process gets one
+// full threshold before it is reported unready.
+func Readiness(now time.Time, snapshotMaxAge, fetcherMaxAge time.Duration) Report {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	report := Report{Ready: true, Checks: map[string]Check{}}
+	add := func(name string, ok bool, detail string) {
+		report.Checks[name] = Check{OK: ok, Detail: detail}
+		report.Ready = report.Ready && ok
+	}
+
+	if storeLoaded {
+		add("store", true, "")
+	} else {
+		add("store", false, "data file not loaded")
+	}
+
+	since := lastSnapshot
+	if since.IsZero() {
+		since = startedAt
+	}
+	switch age := now.Sub(since); {
+	case age <= snapshotMaxAge && lastSnapshotErr != nil:
+		add("snapshot", true, "last attempt failed: "+lastSnapshotErr.Error())
+	case age <= snapshotMaxAge:
+		add("snapshot", true, "")
+	case lastSnapshotErr != nil:
+		add("snapshot", false, "no successful snapshot for "+age.Round(time.Second).String()+": "+lastSnapshotErr.Error())
+	default:
+		add("snapshot", false, "no successful snapshot for "+age.Round(time.Second).String())
+	}
+
+	since = fetcherHeartbeat
+	if since.IsZero() {
+		since = startedAt
+	}
+	if age := now.Sub(since); age <= fetcherMaxAge {
+		add("fetcher", true, "")
+	} else {
+		add("fetcher", false, "fetch loop idle for "+age.Round(time.Second).String())
+	}
+
+	return report
+}
+
+// reset restores the initial state; used by tests.
+func reset(start time.Time) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	startedAt = start
+	storeLoaded = false
+	lastSnapshot = time.Time{}
+	lastSnapshotErr = nil
+	fetcherHeartbeat = time.Time{}
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessRequiresLoadedStore(t *testing.T) {
+	reset(time.Now())
+
+	report := Readiness(time.Now(), time.Minute, time.Minute)
+	assert.False(t, report.Ready)
+	assert.False(t, report.Checks["store"].OK)
+
+	MarkStoreLoaded()
+	report = Readiness(time.Now(), time.Minute, time.Minute)
+	assert.True(t, report.Ready)
+}
+
+func TestReadinessTracksSnapshotAge(t *testing.T) {
+	reset(time.Now())
+	MarkStoreLoaded()
+	FetcherHeartbeat()
+
+	RecordSnapshot(nil)
+	RecordSnapshot(errors.New("disk full"))
+	report := Readiness(time.Now(), time.Minute, time.Hour)
+	assert.True(t, report.Ready)
+	assert.Contains(t, report.Checks["snapshot"].Detail, "disk full")
+
+	report = Readiness(time.Now().Add(2*time.Minute), time.Minute, time.Hour)
+	assert.False(t, report.Ready)
+	assert.False(t, report.Checks["snapshot"].OK)
+}
+
+func TestReadinessTracksFetcherHeartbeat(t *testing.T) {
+	start := time.Now()
+	reset(start)
+	MarkStoreLoaded()
+	RecordSnapshot(nil)
+
+	assert.True(t, Readiness(start.Add(30*time.Second), time.Hour, time.Minute).Ready)
+
+	report := Readiness(start.Add(2*time.Minute), time.Hour, time.Minute)
+	assert.False(t, report.Ready)
+	assert.False(t, report.Checks["fetcher"].OK)
+}
@@ -0,0 +1,67 @@
+// Package logger configures the process-wide slog logger and carries request
+// IDs through contexts so log lines from one request can be correlated.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/tracing"
+)
+
+type contextKey struct{}
+
+// Init installs the default logger writing to stderr at level ("debug",
+// "info", "warn" or "error") in format ("json" or "text").
+func Init(level, format string) error {
+	handler, err := NewHandler(os.Stderr, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func NewHandler(w io.Writer, level, format string) (slog.Handler, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	options := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.NewJSONHandler(w, options), nil
+	case "text":
+		return slog.NewTextHandler(w, options), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q", format)
+}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// FromContext returns the default logger, tagged with the request ID and
+// trace ID when ctx carries them.
+func FromContext(ctx context.Context) *slog.Logger {
+	log := slog.Default()
+	if id := RequestID(ctx); id != "" {
+		log = log.With("request_id", id)
+	}
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		log = log.With("trace_id", span.Context.TraceID.String())
+	}
+	return log
+}
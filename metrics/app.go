@@ -0,0 +1,43 @@
+package metrics
+
+// Metrics exported by the daemon. Gauges that read live state (store size,
+// semaphore occupancy, queue depth) are registered by the packages owning
+// that state.
+var (
+	HTTPRequests = NewCounter("http_requests_total",
+		"HTTP requests served, by route template, method and status code.",
+		"route", "method", "status")
+	HTTPRequestDuration = NewHistogram("http_request_duration_seconds",
+		"Time spent serving HTTP requests, by route template and method.",
+		DefaultBuckets, "route", "method")
+	RateLimitRejections = NewCounter("rate_limit_rejections_total",
+		"Requests rejected by the rate limiter.")
+
+	FetchDuration = NewHistogram("fetch_duration_seconds",
+		"Time taken by outbound fetches, by outcome.",
+		DefaultBuckets, "outcome")
+	FetchOutcomes = NewCounter("fetch_outcomes_total",
+		"Outbound fetches by outcome (ok, not_modified, failed) and reason.",
+		"outcome", "reason")
+
+	Lookups = NewCounter("lookups_total",
+		"Reputation lookups, by the scope that matched (url, host, domain or none) and classification.",
+		"scope", "classification")
+	DNSBLQueries = NewCounter("dnsbl_queries_total",
+		"DNSBL queries answered, by query type and response code.",
+		"type", "rcode")
+	FeedRegenerations = NewCounter("feed_regenerations_total",
+		"Times the blocklist feeds were regenerated after verdicts changed.")
+	ArchiveWrites = NewCounter("archive_writes_total",
+		"Fetched bodies offered to the snapshot archive, by result (stored, duplicate, unchanged, too_large or error).",
+		"result")
+	HashMatches = NewCounter("hash_matches_total",
+		"Fetched bodies that newly matched a known-bad hash, by hash type (sha256 or fuzzy).",
+		"type")
+
+	SnapshotSaveDuration = NewHistogram("snapshot_save_duration_seconds",
+		"Time taken to write the data file.",
+		DefaultBuckets)
+	SnapshotSaveFailures = NewCounter("snapshot_save_failures_total",
+		"Failed attempts to write the data file.")
+)
@@ -0,0 +1,288 @@
+// Package metrics keeps counters, gauges and histograms in memory and serves
+// them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit latencies measured in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      = map[string]collector{}
+)
+
+func register(name string, c collector) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, exists := registry[name]; exists {
+		panic("metrics: duplicate metric " + name)
+	}
+	registry[name] = c
+}
+
+// WriteTo writes every registered metric, sorted by name.
+func WriteTo(w io.Writer) {
+	registryMutex.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, registry[name])
+	}
+	registryMutex.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler serves the registered metrics for scraping.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteTo(w)
+	})
+}
+
+// series holds one value per distinct set of label values.
+type series struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mutex  sync.Mutex
+	values map[string]*float64
+	keys   map[string][]string
+}
+
+func newSeries(name, help, kind string, labels []string) *series {
+	return &series{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		values: make(map[string]*float64),
+		keys:   make(map[string][]string),
+	}
+}
+
+func (s *series) update(labelValues []string, fn func(*float64)) {
+	if len(labelValues) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", s.name, len(s.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, exists := s.values[key]
+	if !exists {
+		value = new(float64)
+		s.values[key] = value
+		s.keys[key] = append([]string(nil), labelValues...)
+	}
+	fn(value)
+}
+
+func (s *series) add(delta float64, labelValues []string) {
+	s.update(labelValues, func(value *float64) { *value += delta })
+}
+
+func (s *series) set(v float64, labelValues []string) {
+	s.update(labelValues, func(value *float64) { *value = v })
+}
+
+func (s *series) write(w io.Writer) {
+	writeHeader(w, s.name, s.help, s.kind)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", s.name, formatLabels(s.labels, s.keys[key], "", ""), formatValue(*s.values[key]))
+	}
+}
+
+// Counter is a monotonically increasing value per label set.
+type Counter struct{ *series }
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newSeries(name, help, "counter", labels)}
+	if len(labels) == 0 {
+		c.add(0, nil)
+	}
+	register(name, c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) { c.add(1, labelValues) }
+
+func (c *Counter) Add(v float64, labelValues ...string) { c.add(v, labelValues) }
+
+// Gauge is a value per label set that can go up and down.
+type Gauge struct{ *series }
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newSeries(name, help, "gauge", labels)}
+	if len(labels) == 0 {
+		g.add(0, nil)
+	}
+	register(name, g)
+	return g
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) { g.set(v, labelValues) }
+
+func (g *Gauge) Add(v float64, labelValues ...string) { g.add(v, labelValues) }
+
+// gaugeFunc is a gauge read from fn at scrape time.
+type gaugeFunc struct {
+	name, help string
+	fn         func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is computed on each scrape.
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(name, &gaugeFunc{name: name, help: help, fn: fn})
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatValue(g.fn()))
+}
+
+// Histogram counts observations into cumulative buckets per label set.
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mutex sync.Mutex
+	data  map[string]*histogramData
+}
+
+type histogramData struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		data:    make(map[string]*histogramData),
+	}
+	register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.name, len(h.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	data, exists := h.data[key]
+	if !exists {
+		data = &histogramData{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.data[key] = data
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			data.counts[i]++
+		}
+	}
+	data.count++
+	data.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	keys := make([]string, 0, len(h.data))
+	for key := range h.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		data := h.data[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, data.labelValues, "le", formatValue(bound)), data.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, data.labelValues, "le", "+Inf"), data.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, data.labelValues, "", ""), formatValue(data.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, data.labelValues, "", ""), data.count)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape() string {
+	var b strings.Builder
+	WriteTo(&b)
+	return b.String()
+}
+
+func TestCounterExposition(t *testing.T) {
+	c := NewCounter("test_requests_total", "Requests.", "route", "status")
+	c.Inc("/a", "200")
+	c.Inc("/a", "200")
+	c.Add(3, `/b"q`, "500")
+
+	out := scrape()
+	assert.Contains(t, out, "# HELP test_requests_total Requests.\n# TYPE test_requests_total counter\n")
+	assert.Contains(t, out, `test_requests_total{route="/a",status="200"} 2`+"\n")
+	assert.Contains(t, out, `test_requests_total{route="/b\"q",status="500"} 3`+"\n")
+}
+
+func TestUnlabelledMetricsStartAtZero(t *testing.T) {
+	NewCounter("test_rejections_total", "Rejections.")
+	NewGauge("test_depth", "Depth.")
+
+	out := scrape()
+	assert.Contains(t, out, "test_rejections_total 0\n")
+	assert.Contains(t, out, "test_depth 0\n")
+}
+
+func TestHistogramExposition(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "Durations.", []float64{0.1, 1}, "outcome")
+	h.Observe(0.05, "ok")
+	h.Observe(0.5, "ok")
+	h.Observe(5, "ok")
+
+	out := scrape()
+	assert.Contains(t, out, "# TYPE test_duration_seconds histogram\n")
+	assert.Contains(t, out, `test_duration_seconds_bucket{outcome="ok",le="0.1"} 1`+"\n")
+	assert.Contains(t, out, `test_duration_seconds_bucket{outcome="ok",le="1"} 2`+"\n")
+	assert.Contains(t, out, `test_duration_seconds_bucket{outcome="ok",le="+Inf"} 3`+"\n")
+	assert.Contains(t, out, `test_duration_seconds_sum{outcome="ok"} 5.55`+"\n")
+	assert.Contains(t, out, `test_duration_seconds_count{outcome="ok"} 3`+"\n")
+}
+
+func TestGaugeFunc(t *testing.T) {
+	value := 7.0
+	NewGaugeFunc("test_live_value", "Live value.", func() float64 { return value })
+
+	assert.Contains(t, scrape(), "test_live_value 7\n")
+	value = 9
+	assert.Contains(t, scrape(), "test_live_value 9\n")
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+)
+
+type actorKey struct{}
+
+// AdminAuth lets a request through when it carries one of the configured
+// admin bearer tokens, or a verified client certificate whose common name
+// is on the admin list, and records who made it for the audit log.
+// Everyone else gets 401.
+func AdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := adminActor(r)
+		if actor == "" {
+			logger.FromContext(r.Context()).Warn("admin request refused", "client", ClientKey(r))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("admin credentials required"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+	})
+}
+
+func adminActor(r *http.Request) string {
+	cfg := config.Current()
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		// Validate has already rejected malformed token lists.
+		credentials, _ := cfg.AdminCredentials()
+		// Compare against every token so the time taken does not reveal
+		// which one came closest.
+		var actor string
+		for known, name := range credentials {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(known)) == 1 {
+				actor = "token:" + name
+			}
+		}
+		return actor
+	}
+
+	if identity := IdentityFromContext(r.Context()); identity != nil {
+		if slices.Contains(cfg.AdminClients(), identity.CommonName) {
+			return "cert:" + identity.CommonName
+		}
+	}
+	return ""
+}
+
+// ActorFromContext names who made the request for the audit log: the
+// admin AdminAuth let through, else the verified client certificate, else
+// "anonymous".
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok {
+		return actor
+	}
+	if identity := IdentityFromContext(ctx); identity != nil {
+		return "cert:" + identity.CommonName
+	}
+	return "anonymous"
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuth(t *testing.T) {
+	t.Cleanup(func() { config.Reload() })
+	t.Setenv("ADMIN_TOKENS", "ops:0123456789abcdef")
+	t.Setenv("ADMIN_CLIENT_NAMES", "admin-a")
+	_, err := config.Reload()
+	assert.NoError(t, err)
+
+	var actor string
+	handler := Identity(AdminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = ActorFromContext(r.Context())
+	})))
+	serve := func(req *http.Request) int {
+		actor = ""
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	assert.Equal(t, http.StatusUnauthorized, serve(req))
+	assert.Equal(t, "anonymous", ActorFromContext(req.Context()))
+
+	req.Header.Set("Authorization", "Bearer 0123456789abcdeX")
+	assert.Equal(t, http.StatusUnauthorized, serve(req))
+
+	req.Header.Set("Authorization", "Bearer 0123456789abcdef")
+	assert.Equal(t, http.StatusOK, serve(req))
+	assert.Equal(t, "token:ops", actor)
+
+	cert := func(commonName string) *tls.ConnectionState {
+		c := &x509.Certificate{Raw: []byte(commonName), Subject: pkix.Name{CommonName: commonName}, SerialNumber: big.NewInt(1)}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{c}}}
+	}
+	req = httptest.NewRequest("GET", "/admin", nil)
+	req.TLS = cert("someone-else")
+	assert.Equal(t, http.StatusUnauthorized, serve(req))
+	req.TLS = cert("admin-a")
+	assert.Equal(t, http.StatusOK, serve(req))
+	assert.Equal(t, "cert:admin-a", actor)
+}
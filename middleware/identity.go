@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// ClientIdentity describes the verified certificate a client presented
+// over mutual TLS.
+type ClientIdentity struct {
+	Subject     string   `json:"subject"`
+	CommonName  string   `json:"common_name"`
+	Issuer      string   `json:"issuer"`
+	Serial      string   `json:"serial"`
+	DNSNames    []string `json:"dns_names,omitempty"`
+	Emails      []string `json:"emails,omitempty"`
+	URIs        []string `json:"uris,omitempty"`
+	Fingerprint string   `json:"fingerprint_sha256"`
+}
+
+type identityKey struct{}
+
+// Identity stores the verified client certificate, if any, in the request
+// context for handlers and audit records.
+func Identity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// VerifiedChains is only filled in when the certificate checked out
+		// against the configured CA bundle.
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cert := r.TLS.VerifiedChains[0][0]
+		fingerprint := sha256.Sum256(cert.Raw)
+		identity := &ClientIdentity{
+			Subject:     cert.Subject.String(),
+			CommonName:  cert.Subject.CommonName,
+			Issuer:      cert.Issuer.String(),
+			Serial:      cert.SerialNumber.Text(16),
+			DNSNames:    cert.DNSNames,
+			Emails:      cert.EmailAddresses,
+			Fingerprint: hex.EncodeToString(fingerprint[:]),
+		}
+		for _, u := range cert.URIs {
+			identity.URIs = append(identity.URIs, u.String())
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	})
+}
+
+// IdentityFromContext returns the client's certificate identity, or nil
+// when the request was not made over verified mutual TLS.
+func IdentityFromContext(ctx context.Context) *ClientIdentity {
+	identity, _ := ctx.Value(identityKey{}).(*ClientIdentity)
+	return identity
+}
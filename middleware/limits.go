@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+)
+
+// BodyLimit caps request bodies at n bytes. Reading past the cap fails,
+// which ParseJson reports as ErrBodyTooLarge; a declared Content-Length
+// over the cap is refused with 413 before the handler runs.
+func BodyLimit(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				utils.WriteError(w, http.StatusRequestEntityTooLarge, utils.ErrBodyTooLarge)
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
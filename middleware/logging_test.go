@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDPropagatesIncomingHeader(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logger.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, "abc-123", seen)
+	assert.Equal(t, "abc-123", w.Header().Get("X-Request-ID"))
+}
+
+func TestRequestIDGeneratesWhenMissingOrInvalid(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logger.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "bad id\n")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Len(t, seen, 32)
+	assert.Equal(t, seen, w.Header().Get("X-Request-ID"))
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := logger.NewHandler(&buf, "info", "json")
+	assert.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	defer slog.SetDefault(previous)
+
+	chain := RequestID(AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})))
+	req := httptest.NewRequest("POST", "/api/v1/url", nil)
+	req.RemoteAddr = "192.0.2.7:5555"
+	req.Header.Set("X-Request-ID", "req-1")
+	chain.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "request", line["msg"])
+	assert.Equal(t, "POST", line["method"])
+	assert.Equal(t, "/api/v1/url", line["path"])
+	assert.Equal(t, float64(http.StatusTeapot), line["status"])
+	assert.Equal(t, "192.0.2.7", line["client"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Contains(t, line, "latency_ms")
+}
+
+func TestTracingContinuesIncomingTrace(t *testing.T) {
+	var span *tracing.Span
+	handler := Tracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span = tracing.SpanFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/api/v1/url", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.NotNil(t, span)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.Context.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.String())
+	assert.Equal(t, span.Context.Traceparent(), w.Header().Get("traceparent"))
+	assert.Equal(t, 200, span.Attributes["http.response.status_code"])
+}
+
+func TestIdentityFromVerifiedCertificate(t *testing.T) {
+	cert := &x509.Certificate{
+		Raw:            []byte("raw"),
+		Subject:        pkix.Name{CommonName: "client-a", Organization: []string{"Example"}},
+		Issuer:         pkix.Name{CommonName: "test CA"},
+		SerialNumber:   big.NewInt(255),
+		EmailAddresses: []string{"ops@example.com"},
+	}
+
+	var identity *ClientIdentity
+	handler := Identity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity = IdentityFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Nil(t, identity)
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Nil(t, identity, "unverified certificates carry no identity")
+
+	req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.NotNil(t, identity)
+	assert.Equal(t, "client-a", identity.CommonName)
+	assert.Equal(t, "CN=client-a,O=Example", identity.Subject)
+	assert.Equal(t, "CN=test CA", identity.Issuer)
+	assert.Equal(t, "ff", identity.Serial)
+	assert.Equal(t, []string{"ops@example.com"}, identity.Emails)
+	assert.Len(t, identity.Fingerprint, 64)
+}
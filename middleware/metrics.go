@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
+)
+
+// Metrics records request counts and latencies per route template.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		metrics.HTTPRequests.Inc(route, r.Method, strconv.Itoa(recorder.status))
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
@@ -2,11 +2,13 @@ package middleware
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
 )
 
@@ -22,11 +24,12 @@ func NewRateLimiter() *RateLimiter {
 }
 
 func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
-	log.Println("[INFO] Rate limiter initialized")
+	slog.Debug("rate limiter initialized")
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rl.mutex.Lock()
 		if lastVisit, found := rl.visitors[r.RemoteAddr]; found {
-			if time.Since(lastVisit) < 1*time.Second {
+			if time.Since(lastVisit) < config.Current().RateLimitInterval {
+				metrics.RateLimitRejections.Inc()
 				utils.WriteError(w, http.StatusTooManyRequests, fmt.Errorf("%s", "Too many requests"))
 				rl.mutex.Unlock()
 				return
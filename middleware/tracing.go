@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/tracing"
+	"github.com/gorilla/mux"
+)
+
+const traceparentHeader = "traceparent"
+
+// Tracing wraps each request in a server span that continues the caller's
+// trace when a valid traceparent header is present, and returns the span's
+// own traceparent so callers can find it.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent, err := tracing.ParseTraceparent(r.Header.Get(traceparentHeader)); err == nil {
+			ctx = tracing.WithRemoteParent(ctx, parent)
+		}
+
+		route := routeTemplate(r)
+		ctx, span := tracing.Start(ctx, r.Method+" "+route, tracing.KindServer)
+		defer span.End()
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("url.path", r.URL.Path)
+		span.SetAttribute("client.address", ClientKey(r))
+
+		w.Header().Set(traceparentHeader, span.Context.Traceparent())
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttribute("http.response.status_code", recorder.status)
+		if recorder.status >= http.StatusInternalServerError {
+			span.RecordError(errors.New(http.StatusText(recorder.status)))
+		}
+	})
+}
+
+// routeTemplate names the matched route by its template, so /jobs/{id} is
+// one route rather than one per job.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,104 @@
+// Package publicsuffix finds the public suffix and registrable domain
+// (eTLD+1) of a host using an embedded copy of the Public Suffix List.
+package publicsuffix
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+//go:embed public_suffix_list.dat
+var listData string
+
+type rules struct {
+	exact     map[string]struct{}
+	wildcard  map[string]struct{}
+	exception map[string]struct{}
+}
+
+var list = parse(listData)
+
+func parse(data string) rules {
+	r := rules{
+		exact:     make(map[string]struct{}),
+		wildcard:  make(map[string]struct{}),
+		exception: make(map[string]struct{}),
+	}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		// Rules end at the first whitespace. Internationalized rules are
+		// matched in their punycode form, as hosts arrive in it.
+		line = strings.ToLower(strings.Fields(line)[0])
+		if ascii, err := idna.ToASCII(line); err == nil {
+			line = ascii
+		}
+		switch {
+		case strings.HasPrefix(line, "!"):
+			r.exception[line[1:]] = struct{}{}
+		case strings.HasPrefix(line, "*."):
+			r.wildcard[line[2:]] = struct{}{}
+		default:
+			r.exact[line] = struct{}{}
+		}
+	}
+	return r
+}
+
+// PublicSuffix returns the public suffix of host, e.g. "co.uk" for
+// "www.example.co.uk". Hosts matching no rule fall back to their last label.
+func PublicSuffix(host string) string {
+	host = normalize(host)
+	labels := strings.Split(host, ".")
+
+	// Walk from the longest candidate to the shortest; the first rule hit is
+	// the longest match, with exceptions overriding wildcards.
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		if _, ok := list.exception[candidate]; ok {
+			return strings.Join(labels[i+1:], ".")
+		}
+		if _, ok := list.wildcard[candidate]; ok && i > 0 {
+			return strings.Join(labels[i-1:], ".")
+		}
+		if _, ok := list.exact[candidate]; ok {
+			return candidate
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+// RegistrableDomain returns the public suffix of host plus one label, e.g.
+// "example.co.uk" for "www.example.co.uk". IP addresses are returned as they
+// are. It fails when host is itself a public suffix.
+func RegistrableDomain(host string) (string, error) {
+	host = normalize(host)
+	if host == "" {
+		return "", fmt.Errorf("empty host")
+	}
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+
+	suffix := PublicSuffix(host)
+	if host == suffix {
+		return "", fmt.Errorf("%s is a public suffix", host)
+	}
+	rest := strings.TrimSuffix(host, "."+suffix)
+	if i := strings.LastIndex(rest, "."); i >= 0 {
+		rest = rest[i+1:]
+	}
+	return rest + "." + suffix, nil
+}
+
+func normalize(host string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+}
@@ -0,0 +1,51 @@
+package publicsuffix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrableDomain(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{host: "example.com", want: "example.com"},
+		{host: "WWW.Example.COM.", want: "example.com"},
+		{host: "a.b.example.co.uk", want: "example.co.uk"},
+		{host: "user.github.io", want: "user.github.io"},
+		{host: "x.y.kawasaki.jp", want: "x.y.kawasaki.jp"},
+		{host: "www.city.kawasaki.jp", want: "city.kawasaki.jp"},
+		{host: "foo.bar.ck", want: "foo.bar.ck"},
+		{host: "www.ck", want: "www.ck"},
+		{host: "www.shop.xn--55qx5d.cn", want: "shop.xn--55qx5d.cn"},
+		{host: "app.example.blogspot.com.br", want: "example.blogspot.com.br"},
+		{host: "host.unknowntld", want: "host.unknowntld"},
+		{host: "192.0.2.1", want: "192.0.2.1"},
+		{host: "co.uk", wantErr: true},
+		{host: "com", wantErr: true},
+		{host: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := RegistrableDomain(tt.host)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPublicSuffix(t *testing.T) {
+	assert.Equal(t, "co.uk", PublicSuffix("www.example.co.uk"))
+	assert.Equal(t, "com", PublicSuffix("example.com"))
+	assert.Equal(t, "y.kawasaki.jp", PublicSuffix("x.y.kawasaki.jp"))
+	assert.Equal(t, "kawasaki.jp", PublicSuffix("city.kawasaki.jp"))
+	assert.Equal(t, "unknowntld", PublicSuffix("host.unknowntld"))
+}
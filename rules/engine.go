@@ -0,0 +1,349 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/audit"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+)
+
+const (
+	// checkInterval limits how often evaluations stat the rules file for
+	// changes.
+	checkInterval = time.Second
+	// resolveTimeout bounds the host lookup of a host_cidrs condition.
+	resolveTimeout = 2 * time.Second
+)
+
+// Match is a rule that holds for a URL and what it assigns.
+type Match struct {
+	Rule           string   `json:"rule"`
+	Scope          string   `json:"scope,omitempty"`
+	Key            string   `json:"key,omitempty"`
+	Classification string   `json:"classification,omitempty"`
+	Reasons        []string `json:"reasons,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+}
+
+// Engine evaluates the rules in a file, reloading them when the file
+// changes.
+type Engine struct {
+	path     string
+	verdicts *service.Verdicts
+	// Resolve looks up the addresses of a host for host_cidrs conditions.
+	Resolve func(ctx context.Context, host string) ([]net.IP, error)
+
+	mutex   sync.Mutex
+	checked time.Time
+	modTime time.Time
+	rules   []*compiled
+
+	scheduler *service.Scheduler
+}
+
+// NewEngine evaluates the rules in path and stores their verdicts in
+// verdicts. Load must be called before the first evaluation.
+func NewEngine(path string, verdicts *service.Verdicts) *Engine {
+	e := &Engine{
+		path:     path,
+		verdicts: verdicts,
+		Resolve: func(ctx context.Context, host string) ([]net.IP, error) {
+			return net.DefaultResolver.LookupIP(ctx, "ip", host)
+		},
+	}
+	e.scheduler = service.NewScheduler(func(ctx context.Context, rawURL string) { e.Apply(ctx, rawURL) })
+	return e
+}
+
+// Start launches workers goroutines that Apply the rules to the URLs
+// passed to Schedule.
+func (e *Engine) Start(workers int) {
+	e.scheduler.Start(workers)
+}
+
+// Schedule queues rawURL for Apply. A URL already waiting is applied once,
+// with the context of its latest update, so bursts of updates to one URL
+// cost one evaluation.
+func (e *Engine) Schedule(ctx context.Context, rawURL string) {
+	e.scheduler.Schedule(ctx, rawURL)
+}
+
+// Pending returns how many URLs wait for Apply.
+func (e *Engine) Pending() int {
+	return e.scheduler.Pending()
+}
+
+// Load reads the rules file. A missing file means no rules; an invalid one
+// is an error and leaves the rules in use unchanged.
+func (e *Engine) Load() error {
+	// Record the time before reading so a write racing the read is seen
+	// as a change on the next check.
+	info, err := os.Stat(e.path)
+	if os.IsNotExist(err) {
+		e.swap(nil, time.Time{})
+		slog.Info("no rules file found, running without rules", "file", e.path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return err
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("parsing rules file %s: %w", e.path, err)
+	}
+	rules, err := compileAll(parsed)
+	if err != nil {
+		return fmt.Errorf("rules file %s: %w", e.path, err)
+	}
+	e.swap(rules, info.ModTime())
+	slog.Info("loaded rules", "file", e.path, "count", len(rules))
+	return nil
+}
+
+func (e *Engine) swap(rules []*compiled, modTime time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.rules, e.modTime, e.checked = rules, modTime, time.Now()
+}
+
+// current returns the rules in use, first reloading them if the file
+// changed since the last check.
+func (e *Engine) current() []*compiled {
+	e.mutex.Lock()
+	changed := false
+	if time.Since(e.checked) >= checkInterval {
+		e.checked = time.Now()
+		info, err := os.Stat(e.path)
+		changed = (err == nil && !info.ModTime().Equal(e.modTime)) || (err != nil && e.rules != nil)
+	}
+	e.mutex.Unlock()
+
+	if changed {
+		if err := e.Load(); err != nil {
+			slog.Error("reloading rules, keeping the previous ones", "error", err)
+		}
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.rules
+}
+
+// Rules returns the rules in use.
+func (e *Engine) Rules() []Rule {
+	compiled := e.current()
+	rules := make([]Rule, 0, len(compiled))
+	for _, c := range compiled {
+		rules = append(rules, c.Rule)
+	}
+	return rules
+}
+
+// Evaluate returns the rules in use that match data, in file order.
+func (e *Engine) Evaluate(ctx context.Context, data types.URLData) []Match {
+	return e.evaluate(ctx, e.current(), data)
+}
+
+// DryRun evaluates draft rules, or the rules in use when draft is nil,
+// against the stored record of rawURL without applying them. A URL that
+// was never submitted is evaluated as a record holding only the URL; the
+// second result reports whether it was stored.
+func (e *Engine) DryRun(ctx context.Context, draft []Rule, rawURL string) ([]Match, bool, error) {
+	rules := e.current()
+	if draft != nil {
+		var err error
+		if rules, err = compileAll(draft); err != nil {
+			return nil, false, err
+		}
+	}
+	data, stored := record(rawURL)
+	if !stored {
+		data = types.URLData{URL: rawURL}
+	}
+	return e.evaluate(ctx, rules, data), stored, nil
+}
+
+// record returns a copy of the stored record of rawURL that is safe to read
+// without the store locks.
+func record(rawURL string) (types.URLData, bool) {
+	value, exists := utils.URLStore.Load(rawURL)
+	if !exists {
+		return types.URLData{}, false
+	}
+	utils.Mutex.RLock()
+	defer utils.Mutex.RUnlock()
+	data := *value.(*types.URLData)
+	data.Sources = make(map[string]*types.SourceStats, len(data.Sources))
+	for name, stats := range value.(*types.URLData).Sources {
+		copied := *stats
+		data.Sources[name] = &copied
+	}
+	data.Tags, data.History, data.Timings = nil, nil, nil
+	return data, true
+}
+
+func (e *Engine) evaluate(ctx context.Context, rules []*compiled, data types.URLData) []Match {
+	matches := []Match{}
+	if len(rules) == 0 {
+		return matches
+	}
+	host, domain := service.HostAndDomain(data.URL)
+	var addrs []net.IP
+	resolved := false
+	for _, rule := range rules {
+		if len(rule.cidrs) > 0 && !resolved {
+			addrs, resolved = e.addresses(ctx, host), true
+		}
+		if !rule.matches(data, addrs, time.Now()) {
+			continue
+		}
+		match := Match{Rule: rule.Name, Tags: rule.Tags}
+		if rule.Verdict != "" {
+			match.Scope, match.Classification, match.Reasons = rule.Scope, rule.Verdict, rule.Reasons
+			switch rule.Scope {
+			case types.ScopeURL:
+				match.Key = data.URL
+			case types.ScopeHost:
+				match.Key = host
+			case types.ScopeDomain:
+				match.Key = domain
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// addresses returns host itself when it is an IP address, else what it
+// resolves to. A failed lookup matches no range.
+func (e *Engine) addresses(ctx context.Context, host string) []net.IP {
+	if host == "" {
+		return nil
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return []net.IP{ip}
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := e.Resolve(ctx, host)
+	if err != nil {
+		slog.Debug("resolving host for rules", "host", host, "error", err)
+		return nil
+	}
+	return addrs
+}
+
+// matches reports whether every condition of the rule holds for data,
+// whose host resolved to addrs.
+func (c *compiled) matches(data types.URLData, addrs []net.IP, now time.Time) bool {
+	if c.When.MinSources > 0 {
+		sources := 0
+		for _, stats := range data.Sources {
+			if c.within == 0 || now.Sub(stats.LastSeen) <= c.within {
+				sources++
+			}
+		}
+		if sources < c.When.MinSources {
+			return false
+		}
+	}
+	if len(c.cidrs) > 0 && !inAny(addrs, c.cidrs) {
+		return false
+	}
+	if c.path != nil {
+		parsed, err := url.Parse(data.URL)
+		if err != nil || !c.path.MatchString(parsed.Path) {
+			return false
+		}
+	}
+	if len(c.hashes) > 0 && !c.hashes[data.ContentHash] {
+		return false
+	}
+	return true
+}
+
+func inAny(addrs []net.IP, networks []*net.IPNet) bool {
+	for _, addr := range addrs {
+		for _, network := range networks {
+			if network.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply evaluates the rules against the stored record of rawURL, sets the
+// verdicts and attaches the tags of those that match, and returns the
+// matches. A rule does not replace a verdict set by an admin, and an
+// unchanged verdict is not set again.
+func (e *Engine) Apply(ctx context.Context, rawURL string) []Match {
+	data, exists := record(rawURL)
+	if !exists {
+		return nil
+	}
+
+	matches := e.Evaluate(ctx, data)
+	// When rules disagree on a key, the most severe verdict wins, so that
+	// they do not overwrite each other on every evaluation.
+	winners := map[string]Match{}
+	var keys []string
+	for _, match := range matches {
+		if added := utils.URLStore.AddTags(rawURL, match.Tags); len(added) > 0 {
+			audit.Record(ctx, audit.Entry{Actor: "rule:" + match.Rule, Action: audit.ActionTag, Target: rawURL,
+				Affected: 1, After: map[string]any{"tags": added}})
+		}
+		if match.Classification == "" || match.Key == "" {
+			continue
+		}
+		key := match.Scope + ":" + match.Key
+		winner, exists := winners[key]
+		if !exists {
+			keys = append(keys, key)
+		}
+		if !exists || service.Severity(match.Classification) > service.Severity(winner.Classification) {
+			winners[key] = match
+		}
+	}
+	for _, key := range keys {
+		e.setVerdict(ctx, winners[key])
+	}
+	return matches
+}
+
+func (e *Engine) setVerdict(ctx context.Context, match Match) {
+	actor := "rule:" + match.Rule
+	verdict := types.Verdict{Scope: match.Scope, Key: match.Key, Classification: match.Classification,
+		Reasons: match.Reasons, SetBy: actor}
+	if err := service.NormalizeVerdict(&verdict); err != nil {
+		slog.Warn("rule produced an invalid verdict", "rule", match.Rule, "key", match.Key, "error", err)
+		return
+	}
+	previous, exists, stored := e.verdicts.SetAutomatic(verdict)
+	if !stored {
+		return
+	}
+
+	entry := audit.Entry{Actor: actor, Action: audit.ActionVerdictSet, Target: verdict.Scope + ":" + verdict.Key,
+		Affected: 1, After: map[string]any{"classification": verdict.Classification, "reasons": verdict.Reasons}}
+	if exists {
+		entry.Before = map[string]any{"classification": previous.Classification, "reasons": previous.Reasons,
+			"set_by": previous.SetBy}
+	}
+	audit.Record(ctx, entry)
+	slog.Info("rule set verdict", "rule", match.Rule, "scope", verdict.Scope, "key", verdict.Key,
+		"classification", verdict.Classification)
+}
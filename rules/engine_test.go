@@ -0,0 +1,214 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const badHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+const rulesFile = `
+rules:
+  - name: many-sources
+    when:
+      min_sources: 3
+      within: 1h
+    verdict: suspicious
+    reasons: [reported by several sources]
+  - name: bad-hosting
+    when:
+      host_cidrs: [203.0.113.0/24]
+    verdict: malicious
+    scope: domain
+    tags: [bad-hosting]
+  - name: login-path
+    when:
+      path_regex: '(?i)/(login|signin)'
+    tags: [login]
+  - name: known-kit
+    when:
+      content_hashes: [` + badHash + `]
+    verdict: malicious
+    reasons: [known phishing kit]
+`
+
+func newEngine(t *testing.T, contents string) (*Engine, *service.Verdicts, string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	if contents != "" {
+		assert.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+	verdicts := service.NewVerdicts(filepath.Join(dir, "verdicts.json"))
+	engine := NewEngine(path, verdicts)
+	engine.Resolve = func(_ context.Context, host string) ([]net.IP, error) {
+		if host == "www.hosted.example" {
+			return []net.IP{net.ParseIP("203.0.113.7")}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+	assert.NoError(t, engine.Load())
+	return engine, verdicts, path
+}
+
+func names(matches []Match) []string {
+	var names []string
+	for _, match := range matches {
+		names = append(names, match.Rule)
+	}
+	return names
+}
+
+func TestEvaluate(t *testing.T) {
+	engine, _, _ := newEngine(t, rulesFile)
+	ctx := context.Background()
+	now := time.Now()
+
+	sources := map[string]*types.SourceStats{
+		"a": {LastSeen: now},
+		"b": {LastSeen: now.Add(-10 * time.Minute)},
+		"c": {LastSeen: now.Add(-2 * time.Hour)},
+	}
+	assert.Empty(t, engine.Evaluate(ctx, types.URLData{URL: "http://x.example/", Sources: sources}))
+	sources["c"].LastSeen = now
+	assert.Equal(t, []string{"many-sources"}, names(engine.Evaluate(ctx, types.URLData{URL: "http://x.example/", Sources: sources})))
+
+	matches := engine.Evaluate(ctx, types.URLData{URL: "https://www.hosted.example/Login"})
+	assert.Equal(t, []string{"bad-hosting", "login-path"}, names(matches))
+	assert.Equal(t, "hosted.example", matches[0].Key)
+	assert.Empty(t, matches[1].Classification)
+
+	assert.Equal(t, []string{"bad-hosting"}, names(engine.Evaluate(ctx, types.URLData{URL: "http://203.0.113.9/"})))
+	assert.Equal(t, []string{"known-kit"}, names(engine.Evaluate(ctx, types.URLData{URL: "http://k.example/", ContentHash: badHash})))
+}
+
+func TestCompileRejectsInvalidRules(t *testing.T) {
+	for _, rule := range []Rule{
+		{Name: "", When: Condition{PathRegex: "x"}, Tags: []string{"t"}},
+		{Name: "no-condition", Tags: []string{"t"}},
+		{Name: "no-action", When: Condition{PathRegex: "x"}},
+		{Name: "bad-regex", When: Condition{PathRegex: "("}, Tags: []string{"t"}},
+		{Name: "bad-cidr", When: Condition{HostCIDRs: []string{"10.0.0.0"}}, Tags: []string{"t"}},
+		{Name: "bad-hash", When: Condition{ContentHashes: []string{"abc"}}, Tags: []string{"t"}},
+		{Name: "within-alone", When: Condition{Within: "1h"}, Tags: []string{"t"}},
+		{Name: "bad-verdict", When: Condition{PathRegex: "x"}, Verdict: "unknown"},
+		{Name: "bad-scope", When: Condition{PathRegex: "x"}, Verdict: "clean", Scope: "path"},
+		{Name: "bad-tag", When: Condition{PathRegex: "x"}, Tags: []string{"Not A Tag"}},
+	} {
+		_, err := compileAll([]Rule{rule})
+		assert.Error(t, err, rule.Name)
+	}
+
+	_, err := compileAll([]Rule{{Name: "a", When: Condition{PathRegex: "x"}, Tags: []string{"t"}}, {Name: "a", When: Condition{PathRegex: "y"}, Tags: []string{"t"}}})
+	assert.ErrorContains(t, err, "used twice")
+
+	_, err = Parse([]byte("rules:\n  - name: typo\n    wehn: {}\n"))
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	engine, verdicts, _ := newEngine(t, rulesFile+`
+  - name: clean-login
+    when:
+      path_regex: /login
+    verdict: clean
+    scope: domain
+`)
+	ctx := context.Background()
+	target := "https://www.hosted.example/login"
+	utils.URLStore.Submit(target)
+	t.Cleanup(func() { utils.URLStore.Remove(target) })
+
+	matches := engine.Apply(ctx, target)
+	assert.Equal(t, []string{"bad-hosting", "login-path", "clean-login"}, names(matches))
+
+	// The more severe of the two domain verdicts wins.
+	verdict, exists := verdicts.Get(types.ScopeDomain, "hosted.example")
+	assert.True(t, exists)
+	assert.Equal(t, types.VerdictMalicious, verdict.Classification)
+	assert.Equal(t, "rule:bad-hosting", verdict.SetBy)
+
+	value, _ := utils.URLStore.Load(target)
+	utils.Mutex.RLock()
+	assert.Equal(t, map[string]int{"bad-hosting": 1, "login": 1}, value.(*types.URLData).Tags)
+	utils.Mutex.RUnlock()
+
+	// A verdict set by an admin is left alone.
+	verdicts.Set(types.Verdict{Scope: types.ScopeDomain, Key: "hosted.example", Classification: types.VerdictClean, SetBy: "token:ops"})
+	engine.Apply(ctx, target)
+	verdict, _ = verdicts.Get(types.ScopeDomain, "hosted.example")
+	assert.Equal(t, "token:ops", verdict.SetBy)
+
+	assert.Nil(t, engine.Apply(ctx, "http://never-submitted.example/"))
+}
+
+func TestApplyDoesNotDowngradeAcrossURLs(t *testing.T) {
+	engine, verdicts, _ := newEngine(t, rulesFile+`
+  - name: clean-login
+    when:
+      path_regex: /login
+    verdict: clean
+    scope: domain
+`)
+	ctx := context.Background()
+	hosted, login := "https://www.hosted.example/", "https://other.hosted.example/login"
+	for _, target := range []string{hosted, login} {
+		utils.URLStore.Submit(target)
+		t.Cleanup(func() { utils.URLStore.Remove(target) })
+	}
+
+	engine.Apply(ctx, hosted)
+	engine.Apply(ctx, login)
+	engine.Apply(ctx, hosted)
+	verdict, _ := verdicts.Get(types.ScopeDomain, "hosted.example")
+	assert.Equal(t, "rule:bad-hosting", verdict.SetBy)
+	changes, _, _ := verdicts.ChangesSince(0)
+	assert.Len(t, changes, 1, "the verdict is set once, not flipped back and forth")
+}
+
+func TestSchedule(t *testing.T) {
+	engine, verdicts, _ := newEngine(t, rulesFile)
+	target := "https://www.hosted.example/scheduled"
+	utils.URLStore.Submit(target)
+	t.Cleanup(func() { utils.URLStore.Remove(target) })
+
+	for range 10 {
+		engine.Schedule(context.Background(), target)
+	}
+	assert.Equal(t, 1, engine.Pending(), "repeat updates are merged")
+
+	engine.Start(2)
+	assert.Eventually(t, func() bool {
+		_, exists := verdicts.Get(types.ScopeDomain, "hosted.example")
+		return exists
+	}, time.Second, 5*time.Millisecond)
+	assert.Zero(t, engine.Pending())
+}
+
+func TestRulesReloadWhenFileChanges(t *testing.T) {
+	engine, _, path := newEngine(t, "")
+	assert.Empty(t, engine.Rules())
+
+	assert.NoError(t, os.WriteFile(path, []byte(rulesFile), 0644))
+	engine.checked = time.Time{}
+	assert.Len(t, engine.Rules(), 4)
+
+	// An invalid file keeps the previous rules.
+	assert.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: broken\n"), 0644))
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	engine.checked = time.Time{}
+	assert.Len(t, engine.Rules(), 4)
+
+	os.Remove(path)
+	engine.checked = time.Time{}
+	assert.Empty(t, engine.Rules())
+}
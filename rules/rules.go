@@ -0,0 +1,171 @@
+// Package rules classifies URLs automatically. Rules are read from a YAML
+// or JSON file and evaluated whenever a submission or fetch updates a
+// record; a rule whose conditions all hold sets a verdict and attaches
+// tags.
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/service"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// maxRules bounds the rules file so evaluation stays cheap.
+const maxRules = 1000
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// File is the layout of the rules file.
+type File struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+}
+
+// Rule sets Verdict on Scope (the URL, its host or its registrable domain)
+// and attaches Tags to the URL when every condition in When holds. At
+// least one of Verdict and Tags is required.
+type Rule struct {
+	Name    string    `yaml:"name" json:"name"`
+	When    Condition `yaml:"when" json:"when"`
+	Verdict string    `yaml:"verdict" json:"verdict,omitempty"`
+	Scope   string    `yaml:"scope" json:"scope,omitempty"`
+	Reasons []string  `yaml:"reasons" json:"reasons,omitempty"`
+	Tags    []string  `yaml:"tags" json:"tags,omitempty"`
+}
+
+// Condition lists what a record must show for a rule to match. Unset
+// fields are not checked; at least one must be set.
+type Condition struct {
+	// MinSources distinct sources submitted the URL, within the Within
+	// duration (such as "1h") when it is set.
+	MinSources int    `yaml:"min_sources" json:"min_sources,omitempty"`
+	Within     string `yaml:"within" json:"within,omitempty"`
+	// HostCIDRs holds when the host, or an address it resolves to, is in
+	// one of the ranges.
+	HostCIDRs []string `yaml:"host_cidrs" json:"host_cidrs,omitempty"`
+	// PathRegex is matched against the URL path.
+	PathRegex string `yaml:"path_regex" json:"path_regex,omitempty"`
+	// ContentHashes are hex SHA-256 digests of known-bad bodies.
+	ContentHashes []string `yaml:"content_hashes" json:"content_hashes,omitempty"`
+}
+
+// compiled is a Rule checked and ready to evaluate.
+type compiled struct {
+	Rule
+	within time.Duration
+	cidrs  []*net.IPNet
+	path   *regexp.Regexp
+	hashes map[string]bool
+}
+
+// Parse reads a rules file. Unknown keys are errors so typos do not go
+// unnoticed.
+func Parse(data []byte) ([]Rule, error) {
+	var file File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return file.Rules, nil
+}
+
+// compileAll checks rules and prepares them for evaluation, reporting the
+// first invalid one.
+func compileAll(rules []Rule) ([]*compiled, error) {
+	if len(rules) > maxRules {
+		return nil, fmt.Errorf("at most %d rules", maxRules)
+	}
+	names := map[string]bool{}
+	result := make([]*compiled, 0, len(rules))
+	for i, rule := range rules {
+		c, err := compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, rule.Name, err)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("rule %d: name %q is used twice", i+1, c.Name)
+		}
+		names[c.Name] = true
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func compile(rule Rule) (*compiled, error) {
+	if !namePattern.MatchString(rule.Name) {
+		return nil, fmt.Errorf("name must be 1-64 letters, digits, '.', '_' or '-'")
+	}
+	c := &compiled{Rule: rule, hashes: map[string]bool{}}
+	when := rule.When
+
+	if when.MinSources < 0 {
+		return nil, fmt.Errorf("min_sources must not be negative")
+	}
+	if when.Within != "" {
+		within, err := time.ParseDuration(when.Within)
+		if err != nil || within <= 0 {
+			return nil, fmt.Errorf("within must be a positive duration, got %q", when.Within)
+		}
+		if when.MinSources == 0 {
+			return nil, fmt.Errorf("within needs min_sources")
+		}
+		c.within = within
+	}
+	for _, cidr := range when.HostCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("host_cidrs: %w", err)
+		}
+		c.cidrs = append(c.cidrs, network)
+	}
+	if when.PathRegex != "" {
+		path, err := regexp.Compile(when.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("path_regex: %w", err)
+		}
+		c.path = path
+	}
+	for _, hash := range when.ContentHashes {
+		hash = strings.ToLower(hash)
+		if len(hash) != 64 || strings.Trim(hash, "0123456789abcdef") != "" {
+			return nil, fmt.Errorf("content_hashes must be hex SHA-256 digests, got %q", hash)
+		}
+		c.hashes[hash] = true
+	}
+	if when.MinSources == 0 && len(c.cidrs) == 0 && c.path == nil && len(c.hashes) == 0 {
+		return nil, fmt.Errorf("when needs at least one condition")
+	}
+
+	if rule.Verdict == "" && len(rule.Tags) == 0 {
+		return nil, fmt.Errorf("verdict or tags is required")
+	}
+	if c.Scope == "" {
+		c.Scope = types.ScopeURL
+	}
+	if rule.Verdict != "" {
+		// Check the classification and reasons against a placeholder key;
+		// the real one comes from each URL.
+		check := types.Verdict{Scope: types.ScopeURL, Key: "x", Classification: rule.Verdict, Reasons: rule.Reasons}
+		if err := service.NormalizeVerdict(&check); err != nil {
+			return nil, err
+		}
+		if _, err := service.NormalizeVerdictKey(c.Scope, "example.com"); err != nil {
+			return nil, err
+		}
+	}
+	payload := types.RequestUrlPayload{URL: "x", Tags: rule.Tags}
+	if err := utils.NormalizeSubmission(&payload); err != nil {
+		return nil, err
+	}
+	c.Tags = payload.Tags
+	return c, nil
+}
@@ -1,43 +1,77 @@
 package service
 
 import (
-	"log"
-	"sort"
+	"context"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/config"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
-	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/health"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
 )
 
-func StartBackgroundFetch() {
-	ticker := time.NewTicker(time.Duration(constants.FETCH_INTERVAL) * time.Second)
+// StartBackgroundFetch queues the selected URLs every fetch interval,
+// following reloads of the interval.
+func StartBackgroundFetch(queue *Queue) {
+	interval := config.Current().FetchInterval
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	var intervalMutex sync.Mutex
+	config.OnReload(func(cfg config.Config) {
+		intervalMutex.Lock()
+		defer intervalMutex.Unlock()
+		if cfg.FetchInterval != interval {
+			interval = cfg.FetchInterval
+			ticker.Reset(interval)
+		}
+	})
+
+	health.FetcherHeartbeat()
 	for range ticker.C {
-		log.Println("[INFO] Running background fetch...")
-		var urls []*types.URLData
-		utils.URLStore.Range(func(_, value interface{}) bool {
-			urls = append(urls, value.(*types.URLData))
+		health.FetcherHeartbeat()
+		slog.Info("running background fetch")
+		urls := selectURLs()
 
-			return true
-		})
+		for _, url := range urls {
+			queue.Enqueue(context.Background(), url, PriorityScheduled)
+		}
+		slog.Info("background fetch scheduled", "urls", len(urls))
+	}
+}
 
-		sort.Slice(urls, func(i, j int) bool { return urls[i].Count > urls[j].Count })
-		if len(urls) > 10 {
-			urls = urls[:10]
+// selectURLs picks the URLs for one background run according to
+// FETCH_SELECTION. Pinned URLs always come first and excluded URLs are
+// never picked.
+func selectURLs() []string {
+	urls := utils.URLStore.Pinned()
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		seen[u] = true
+	}
+	add := func(u string) {
+		if !seen[u] && !utils.URLStore.Excluded(u) {
+			seen[u] = true
+			urls = append(urls, u)
 		}
+	}
 
-		var wg sync.WaitGroup
-		for _, urlData := range urls {
-			wg.Add(1)
-			go func(url string) {
-				defer wg.Done()
-				utils.FetchURL(url)
-			}(urlData.URL)
+	if config.Envs.FetchSelection == constants.FETCH_BY_TRENDING {
+		trending, err := utils.Trending(config.Envs.TrendingWindow, constants.TOP_URLS)
+		if err != nil {
+			slog.Error("selecting trending URLs", "error", err)
+			return urls
+		}
+		for _, t := range trending {
+			add(t.URL)
 		}
-		wg.Wait()
-		log.Println("[INFO] Background fetch completed")
+		return urls
+	}
+
+	for _, data := range utils.URLStore.TopFetchable(constants.TOP_URLS) {
+		add(data.URL)
 	}
+	return urls
 }
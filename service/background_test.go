@@ -0,0 +1,25 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectURLsHonoursPinAndExclude(t *testing.T) {
+	saved := utils.URLStore
+	t.Cleanup(func() { utils.URLStore = saved })
+	utils.URLStore = utils.NewStore()
+
+	utils.URLStore.Submit("http://popular.com")
+	utils.URLStore.Submit("http://popular.com")
+	utils.URLStore.Submit("http://excluded.com")
+	utils.URLStore.Submit("http://excluded.com")
+	utils.URLStore.Submit("http://excluded.com")
+	utils.URLStore.Submit("http://rare.com")
+	utils.URLStore.SetExcluded("http://excluded.com", true)
+	utils.URLStore.SetPinned("http://rare.com", true)
+
+	assert.Equal(t, []string{"http://rare.com", "http://popular.com"}, selectURLs())
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/tracing"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+const (
+	PriorityScheduled = 0
+	PriorityOnDemand  = 10
+)
+
+// Queue is a persistent, prioritised fetch queue. Pending jobs for the same
+// URL are collapsed into one, and every state change is written to disk so
+// queued and interrupted jobs are picked up again after a restart.
+type Queue struct {
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	filePath string
+	fetch    func(context.Context, string) error
+	closed   bool
+	running  sync.WaitGroup
+
+	// dirty is set under mutex when the jobs change; flush writes them
+	// out under saving, so changes made while a write is in progress are
+	// written once by the next.
+	dirty  bool
+	saving sync.Mutex
+
+	// workers is how many workers are running and target how many should
+	// be; surplus workers exit after their current job.
+	workers int
+	target  int
+
+	jobs      map[string]*types.Job
+	pending   jobHeap
+	pendingBy map[string]*types.Job
+	finished  []string
+	waiters   map[string]chan struct{}
+}
+
+func NewQueue(filePath string, fetch func(context.Context, string) error) *Queue {
+	q := &Queue{
+		filePath:  filePath,
+		fetch:     fetch,
+		jobs:      make(map[string]*types.Job),
+		pendingBy: make(map[string]*types.Job),
+		waiters:   make(map[string]chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+// Load restores jobs saved by a previous run. Jobs that were running when
+// the process stopped are queued again.
+func (q *Queue) Load() {
+	data, err := os.ReadFile(q.filePath)
+	if err != nil {
+		slog.Info("no existing job file found, starting with an empty queue", "file", q.filePath)
+		return
+	}
+	var saved []*types.Job
+	if err := json.Unmarshal(data, &saved); err != nil {
+		slog.Error("loading job file", "file", q.filePath, "error", err)
+		return
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	sort.Slice(saved, func(i, j int) bool { return saved[i].CreatedAt.Before(saved[j].CreatedAt) })
+	for _, job := range saved {
+		q.jobs[job.ID] = job
+		switch job.Status {
+		case types.JobRunning, types.JobPending:
+			job.Status = types.JobPending
+			job.StartedAt = nil
+			q.push(job)
+		default:
+			q.finished = append(q.finished, job.ID)
+		}
+	}
+	slog.Info("restored pending jobs", "count", len(q.pending))
+}
+
+// Enqueue adds a fetch job for url, tagged with the request ID and span in
+// ctx. If a job for the same URL is already pending it is returned instead,
+// raised to priority if that is higher.
+func (q *Queue) Enqueue(ctx context.Context, url string, priority int) types.Job {
+	defer q.flush()
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if job, exists := q.pendingBy[url]; exists {
+		if priority > job.Priority {
+			job.Priority = priority
+			heap.Fix(&q.pending, q.pending.index(job))
+			q.dirty = true
+		}
+		return *job
+	}
+
+	job := &types.Job{
+		ID:        newJobID(),
+		URL:       url,
+		Priority:  priority,
+		Status:    types.JobPending,
+		RequestID: logger.RequestID(ctx),
+		CreatedAt: time.Now(),
+	}
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		job.Traceparent = span.Context.Traceparent()
+	}
+	q.jobs[job.ID] = job
+	q.push(job)
+	q.dirty = true
+	q.cond.Signal()
+
+	return *job
+}
+
+func (q *Queue) Get(id string) (types.Job, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, exists := q.jobs[id]
+	if !exists {
+		return types.Job{}, false
+	}
+	return *job, true
+}
+
+// Wait blocks until the job has finished or ctx is done, then returns the
+// job as it stands.
+func (q *Queue) Wait(ctx context.Context, id string) (types.Job, bool) {
+	q.mutex.Lock()
+	job, exists := q.jobs[id]
+	if !exists {
+		q.mutex.Unlock()
+		return types.Job{}, false
+	}
+	if job.Status == types.JobSucceeded || job.Status == types.JobFailed {
+		defer q.mutex.Unlock()
+		return *job, true
+	}
+	done, ok := q.waiters[id]
+	if !ok {
+		done = make(chan struct{})
+		q.waiters[id] = done
+	}
+	q.mutex.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return q.Get(id)
+}
+
+// List returns the jobs matching any of statuses, highest priority first.
+func (q *Queue) List(statuses ...types.JobStatus) []types.Job {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	jobs := []types.Job{}
+	for _, job := range q.jobs {
+		for _, status := range statuses {
+			if job.Status == status {
+				jobs = append(jobs, *job)
+				break
+			}
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobLess(&jobs[i], &jobs[j]) })
+	return jobs
+}
+
+// Pending returns the number of jobs waiting for a worker.
+func (q *Queue) Pending() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.pending)
+}
+
+// Start launches workers goroutines that drain the queue until Close.
+func (q *Queue) Start(workers int) {
+	slog.Info("starting fetch workers", "workers", max(workers, 1))
+	q.SetWorkers(workers)
+}
+
+// SetWorkers grows or shrinks the worker pool to n workers. Workers
+// beyond n stop once they finish their current job.
+func (q *Queue) SetWorkers(n int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.target = max(n, 1)
+	for q.workers < q.target {
+		q.workers++
+		q.running.Add(1)
+		go q.work()
+	}
+	q.cond.Broadcast()
+}
+
+// Workers returns how many workers are running.
+func (q *Queue) Workers() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.workers
+}
+
+// Close stops the workers and waits for their current job to finish, so
+// no job is saved as pending while its fetch is still running. Jobs still
+// pending stay on disk for the next run.
+func (q *Queue) Close() {
+	q.mutex.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mutex.Unlock()
+	q.running.Wait()
+	q.flush()
+}
+
+func (q *Queue) work() {
+	defer q.running.Done()
+	for {
+		q.mutex.Lock()
+		for len(q.pending) == 0 && !q.closed && q.workers <= q.target {
+			q.cond.Wait()
+		}
+		if q.closed || q.workers > q.target {
+			q.workers--
+			q.mutex.Unlock()
+			return
+		}
+		job := heap.Pop(&q.pending).(*types.Job)
+		delete(q.pendingBy, job.URL)
+		now := time.Now()
+		job.Status = types.JobRunning
+		job.StartedAt = &now
+		q.dirty = true
+		q.mutex.Unlock()
+		q.flush()
+
+		ctx := context.Background()
+		if job.RequestID != "" {
+			ctx = logger.WithRequestID(ctx, job.RequestID)
+		}
+		if parent, err := tracing.ParseTraceparent(job.Traceparent); err == nil {
+			ctx = tracing.WithRemoteParent(ctx, parent)
+		}
+		ctx, span := tracing.Start(ctx, "fetch.job", tracing.KindInternal)
+		span.SetAttribute("job.id", job.ID)
+		span.SetAttribute("job.priority", job.Priority)
+		span.SetAttribute("url.full", job.URL)
+		err := q.fetch(ctx, job.URL)
+		span.RecordError(err)
+		span.End()
+
+		q.mutex.Lock()
+		finished := time.Now()
+		job.FinishedAt = &finished
+		if err != nil {
+			job.Status = types.JobFailed
+			job.Error = err.Error()
+		} else {
+			job.Status = types.JobSucceeded
+		}
+		q.retire(job)
+		q.dirty = true
+		if done, ok := q.waiters[job.ID]; ok {
+			close(done)
+			delete(q.waiters, job.ID)
+		}
+		q.mutex.Unlock()
+		q.flush()
+	}
+}
+
+func (q *Queue) push(job *types.Job) {
+	heap.Push(&q.pending, job)
+	q.pendingBy[job.URL] = job
+}
+
+// retire records a finished job, forgetting the oldest ones beyond
+// JOB_HISTORY.
+func (q *Queue) retire(job *types.Job) {
+	q.finished = append(q.finished, job.ID)
+	for len(q.finished) > constants.JOB_HISTORY {
+		delete(q.jobs, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+}
+
+// flush writes the jobs to disk if they changed since the last write. It
+// must be called without the mutex held; only the copy of the jobs is
+// taken under it.
+func (q *Queue) flush() {
+	q.saving.Lock()
+	defer q.saving.Unlock()
+
+	q.mutex.Lock()
+	if !q.dirty {
+		q.mutex.Unlock()
+		return
+	}
+	q.dirty = false
+	jobs := make([]types.Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	q.mutex.Unlock()
+
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		slog.Error("marshaling jobs", "error", err)
+		return
+	}
+	tmp := q.filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		slog.Error("writing job file", "file", tmp, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, q.filePath); err != nil {
+		slog.Error("replacing job file", "file", q.filePath, "error", err)
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func jobLess(a, b *types.Job) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+// jobHeap orders pending jobs by priority, then by age.
+type jobHeap []*types.Job
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return jobLess(h[i], h[j]) }
+func (h jobHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x any)        { *h = append(*h, x.(*types.Job)) }
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	job := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return job
+}
+
+func (h jobHeap) index(job *types.Job) int {
+	for i, j := range h {
+		if j == job {
+			return i
+		}
+	}
+	return -1
+}
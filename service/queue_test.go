@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueDeduplicatesPendingURLs(t *testing.T) {
+	queue := NewQueue(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, string) error { return nil })
+
+	first := queue.Enqueue(context.Background(), "http://example.com", PriorityScheduled)
+	second := queue.Enqueue(context.Background(), "http://example.com", PriorityOnDemand)
+
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, PriorityOnDemand, second.Priority)
+	assert.Len(t, queue.List(types.JobPending), 1)
+}
+
+func TestQueueRunsOnDemandBeforeScheduled(t *testing.T) {
+	var mutex sync.Mutex
+	var order []string
+	done := make(chan struct{}, 3)
+	queue := NewQueue(filepath.Join(t.TempDir(), "jobs.json"), func(_ context.Context, url string) error {
+		mutex.Lock()
+		order = append(order, url)
+		mutex.Unlock()
+		done <- struct{}{}
+		return nil
+	})
+
+	queue.Enqueue(context.Background(), "http://scheduled-1.com", PriorityScheduled)
+	queue.Enqueue(context.Background(), "http://scheduled-2.com", PriorityScheduled)
+	queue.Enqueue(context.Background(), "http://on-demand.com", PriorityOnDemand)
+	queue.Start(1)
+	defer queue.Close()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for jobs")
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{"http://on-demand.com", "http://scheduled-1.com", "http://scheduled-2.com"}, order)
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jobs.json")
+
+	queue := NewQueue(file, func(context.Context, string) error { return nil })
+	job := queue.Enqueue(context.Background(), "http://example.com", PriorityOnDemand)
+
+	restored := NewQueue(file, func(context.Context, string) error { return nil })
+	restored.Load()
+
+	got, exists := restored.Get(job.ID)
+	assert.True(t, exists)
+	assert.Equal(t, types.JobPending, got.Status)
+	assert.Equal(t, "http://example.com", got.URL)
+
+	// A restored job still deduplicates new submissions.
+	again := restored.Enqueue(context.Background(), "http://example.com", PriorityScheduled)
+	assert.Equal(t, job.ID, again.ID)
+}
+
+func TestQueueWaitReturnsFinishedJob(t *testing.T) {
+	release := make(chan struct{})
+	queue := NewQueue(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, string) error {
+		<-release
+		return nil
+	})
+	queue.Start(1)
+	defer queue.Close()
+
+	job := queue.Enqueue(context.Background(), "http://example.com", PriorityOnDemand)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	got, _ := queue.Wait(ctx, job.ID)
+	assert.NotEqual(t, types.JobSucceeded, got.Status)
+
+	close(release)
+	got, exists := queue.Wait(context.Background(), job.ID)
+	assert.True(t, exists)
+	assert.Equal(t, types.JobSucceeded, got.Status)
+	assert.NotNil(t, got.FinishedAt)
+	assert.True(t, got.StartedAt.Before(*got.FinishedAt), "the start time is kept")
+}
+
+func TestQueueCarriesRequestIDToFetch(t *testing.T) {
+	seen := make(chan string, 1)
+	queue := NewQueue(filepath.Join(t.TempDir(), "jobs.json"), func(ctx context.Context, _ string) error {
+		seen <- logger.RequestID(ctx)
+		return nil
+	})
+	queue.Start(1)
+	defer queue.Close()
+
+	job := queue.Enqueue(logger.WithRequestID(context.Background(), "req-42"), "http://example.com", PriorityOnDemand)
+	assert.Equal(t, "req-42", job.RequestID)
+
+	select {
+	case id := <-seen:
+		assert.Equal(t, "req-42", id)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for fetch")
+	}
+}
+
+func TestQueueSetWorkersResizesPool(t *testing.T) {
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	running, peak := 0, 0
+	queue := NewQueue(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, string) error {
+		mutex.Lock()
+		running++
+		peak = max(peak, running)
+		mutex.Unlock()
+		<-release
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return nil
+	})
+	defer queue.Close()
+
+	queue.Start(1)
+	queue.SetWorkers(3)
+	assert.Equal(t, 3, queue.Workers())
+
+	for _, url := range []string{"http://a.com", "http://b.com", "http://c.com", "http://d.com"} {
+		queue.Enqueue(context.Background(), url, PriorityScheduled)
+	}
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return running == 3
+	}, time.Second, 5*time.Millisecond)
+
+	queue.SetWorkers(1)
+	close(release)
+	assert.Eventually(t, func() bool { return queue.Workers() == 1 }, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool { return len(queue.List(types.JobSucceeded)) == 4 }, time.Second, 5*time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 3, peak)
+}
+
+func TestQueueCloseWaitsForRunningJobs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jobs.json")
+	started, release := make(chan struct{}), make(chan struct{})
+	queue := NewQueue(file, func(context.Context, string) error {
+		close(started)
+		<-release
+		return nil
+	})
+	queue.Start(1)
+	job := queue.Enqueue(context.Background(), "http://example.com", PriorityOnDemand)
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		queue.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a fetch was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+
+	// The job was saved as finished, so a restart does not fetch it again.
+	restored := NewQueue(file, func(context.Context, string) error { return nil })
+	restored.Load()
+	got, _ := restored.Get(job.ID)
+	assert.Equal(t, types.JobSucceeded, got.Status)
+	assert.Zero(t, restored.Pending())
+}
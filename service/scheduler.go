@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// Scheduler runs fn on the URLs passed to Schedule, on a fixed number of
+// workers, so bursts of updates cost bounded work. A URL scheduled again
+// while it waits runs once, with the context of its latest call.
+type Scheduler struct {
+	fn func(ctx context.Context, url string)
+
+	mutex   sync.Mutex
+	queued  *sync.Cond
+	waiting map[string]context.Context
+	order   []string
+}
+
+func NewScheduler(fn func(ctx context.Context, url string)) *Scheduler {
+	s := &Scheduler{fn: fn, waiting: make(map[string]context.Context)}
+	s.queued = sync.NewCond(&s.mutex)
+	return s
+}
+
+// Start launches workers goroutines that run fn on the scheduled URLs.
+func (s *Scheduler) Start(workers int) {
+	for range max(workers, 1) {
+		go s.work()
+	}
+}
+
+// Schedule queues url for fn, merging it with a call already waiting.
+func (s *Scheduler) Schedule(ctx context.Context, url string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, exists := s.waiting[url]; !exists {
+		s.order = append(s.order, url)
+		s.queued.Signal()
+	}
+	s.waiting[url] = ctx
+}
+
+// Pending returns how many URLs wait for a worker.
+func (s *Scheduler) Pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.order)
+}
+
+func (s *Scheduler) work() {
+	for {
+		s.mutex.Lock()
+		for len(s.order) == 0 {
+			s.queued.Wait()
+		}
+		url := s.order[0]
+		s.order = s.order[1:]
+		ctx := s.waiting[url]
+		delete(s.waiting, url)
+		s.mutex.Unlock()
+
+		s.fn(ctx, url)
+	}
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerMergesRepeats(t *testing.T) {
+	var mutex sync.Mutex
+	runs := map[string]int{}
+	scheduler := NewScheduler(func(_ context.Context, url string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		runs[url]++
+	})
+
+	for range 10 {
+		scheduler.Schedule(context.Background(), "http://a.com")
+	}
+	scheduler.Schedule(context.Background(), "http://b.com")
+	assert.Equal(t, 2, scheduler.Pending())
+
+	scheduler.Start(2)
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return runs["http://a.com"] == 1 && runs["http://b.com"] == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Zero(t, scheduler.Pending())
+}
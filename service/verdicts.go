@@ -0,0 +1,471 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/publicsuffix"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+const (
+	maxVerdictReasons      = 10
+	maxVerdictReasonLength = 256
+)
+
+// Verdicts holds the classifications given to URLs, hosts and registrable
+// domains, and answers lookups against them. Every change is written to
+// the verdicts file and numbered with a serial, so consumers can ask what
+// changed since a serial they saw.
+type Verdicts struct {
+	filePath string
+
+	mutex   sync.RWMutex
+	byScope map[string]map[string]*types.Verdict
+	serial  uint64
+	// changes holds the most recent changes, oldest first.
+	changes []VerdictChange
+	hooks   []func()
+
+	// dirty is set under mutex when the verdicts change; flush writes them
+	// out under saving, so lookups never wait on the disk.
+	dirty  bool
+	saving sync.Mutex
+}
+
+// VerdictChange is one numbered change to the verdict on a key. Before or
+// After is nil when the verdict was added or removed.
+type VerdictChange struct {
+	Serial uint64
+	Scope  string
+	Key    string
+	Before *types.Verdict
+	After  *types.Verdict
+}
+
+// verdictsFile is the layout of the verdicts file.
+type verdictsFile struct {
+	Serial   uint64           `json:"serial"`
+	Verdicts []*types.Verdict `json:"verdicts"`
+}
+
+func NewVerdicts(filePath string) *Verdicts {
+	return &Verdicts{
+		filePath: filePath,
+		byScope: map[string]map[string]*types.Verdict{
+			types.ScopeURL:    {},
+			types.ScopeHost:   {},
+			types.ScopeDomain: {},
+		},
+	}
+}
+
+// Load restores verdicts saved by a previous run.
+func (v *Verdicts) Load() {
+	data, err := os.ReadFile(v.filePath)
+	if err != nil {
+		slog.Info("no existing verdicts file found, starting with none", "file", v.filePath)
+		return
+	}
+	var saved verdictsFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		// Files written before serials were kept hold a bare array. Number
+		// its contents as one change, so no diff claims to start before it.
+		if err := json.Unmarshal(data, &saved.Verdicts); err != nil {
+			slog.Error("loading verdicts file", "file", v.filePath, "error", err)
+			return
+		}
+		if len(saved.Verdicts) > 0 {
+			saved.Serial = 1
+		}
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for _, verdict := range saved.Verdicts {
+		if keys, exists := v.byScope[verdict.Scope]; exists {
+			keys[verdict.Key] = verdict
+		}
+	}
+	v.serial = saved.Serial
+	slog.Info("restored verdicts", "count", len(saved.Verdicts), "serial", saved.Serial)
+}
+
+// OnChange registers fn to be called after every change. It is called
+// without the lock held and must not block.
+func (v *Verdicts) OnChange(fn func()) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.hooks = append(v.hooks, fn)
+}
+
+// NormalizeVerdict checks a verdict and puts its key in the form lookups
+// use: hosts and domains lower-cased without a trailing dot, and domains
+// registrable.
+func NormalizeVerdict(verdict *types.Verdict) error {
+	switch verdict.Classification {
+	case types.VerdictClean, types.VerdictSuspicious, types.VerdictMalicious:
+	case types.VerdictUnknown:
+		return fmt.Errorf("unknown is the absence of a verdict; delete the verdict instead")
+	default:
+		return fmt.Errorf("classification must be clean, suspicious or malicious, got %q", verdict.Classification)
+	}
+	if len(verdict.Reasons) > maxVerdictReasons {
+		return fmt.Errorf("at most %d reasons", maxVerdictReasons)
+	}
+	for _, reason := range verdict.Reasons {
+		if reason == "" || len(reason) > maxVerdictReasonLength {
+			return fmt.Errorf("reasons must be 1-%d characters", maxVerdictReasonLength)
+		}
+		if hasControl(reason) {
+			return fmt.Errorf("reasons must not contain control characters")
+		}
+	}
+
+	key, err := NormalizeVerdictKey(verdict.Scope, verdict.Key)
+	if err != nil {
+		return err
+	}
+	verdict.Key = key
+	return nil
+}
+
+// NormalizeVerdictKey puts key in the form stored for scope. Hosts are
+// DNS names or IP addresses; domains are DNS names.
+func NormalizeVerdictKey(scope, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("key is required")
+	}
+	if hasControl(key) {
+		return "", fmt.Errorf("key must not contain control characters")
+	}
+	switch scope {
+	case types.ScopeURL:
+		return key, nil
+	case types.ScopeHost, types.ScopeDomain:
+		host := strings.ToLower(strings.TrimSuffix(key, "."))
+		if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil && scope == types.ScopeHost {
+			return ip.String(), nil
+		}
+		if !ValidHostName(host) {
+			return "", fmt.Errorf("%s must be a DNS name, got %q", scope, key)
+		}
+		if scope == types.ScopeDomain {
+			registrable, err := publicsuffix.RegistrableDomain(host)
+			if err != nil {
+				return "", err
+			}
+			if registrable != host {
+				return "", fmt.Errorf("%s is not a registrable domain; use %s or scope host", host, registrable)
+			}
+		}
+		return host, nil
+	}
+	return "", fmt.Errorf("scope must be url, host or domain, got %q", scope)
+}
+
+// ValidHostName reports whether name is a DNS name made of letter, digit
+// and hyphen labels. A name whose last label is all digits is not, so IP
+// addresses are not DNS names.
+func ValidHostName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return strings.Trim(labels[len(labels)-1], "0123456789") != ""
+}
+
+func hasControl(s string) bool {
+	return strings.ContainsFunc(s, unicode.IsControl)
+}
+
+// Set stores verdict, whose key must be normalized, and returns the one it
+// replaced, if any.
+func (v *Verdicts) Set(verdict types.Verdict) (*types.Verdict, bool) {
+	previous, existed, _ := v.set(verdict, func(*types.Verdict) bool { return true })
+	return previous, existed
+}
+
+// SetAutomatic stores verdict, set by a rule or a hash match, unless its key
+// holds one set by an analyst, one with the same classification, or a more
+// severe one set automatically by another actor, so that rules and hashes
+// that disagree do not overwrite each other. It returns the verdict on the
+// key before, if any, and whether verdict was stored.
+func (v *Verdicts) SetAutomatic(verdict types.Verdict) (*types.Verdict, bool, bool) {
+	return v.set(verdict, func(previous *types.Verdict) bool {
+		return automatic(previous.SetBy) && previous.Classification != verdict.Classification &&
+			(previous.SetBy == verdict.SetBy || Severity(verdict.Classification) > Severity(previous.Classification))
+	})
+}
+
+// Severity ranks classifications from clean, the least severe, up.
+func Severity(classification string) int {
+	switch classification {
+	case types.VerdictClean:
+		return 1
+	case types.VerdictSuspicious:
+		return 2
+	case types.VerdictMalicious:
+		return 3
+	}
+	return 0
+}
+
+// automatic reports whether setBy names a rule or a hash match rather than
+// an analyst.
+func automatic(setBy string) bool {
+	return strings.HasPrefix(setBy, "rule:") || strings.HasPrefix(setBy, "hash:")
+}
+
+// set stores verdict unless its key holds a verdict that replace refuses.
+func (v *Verdicts) set(verdict types.Verdict, replace func(previous *types.Verdict) bool) (*types.Verdict, bool, bool) {
+	if verdict.SetAt.IsZero() {
+		verdict.SetAt = time.Now().UTC()
+	}
+
+	v.mutex.Lock()
+	keys := v.byScope[verdict.Scope]
+	previous, existed := keys[verdict.Key]
+	if existed && !replace(previous) {
+		v.mutex.Unlock()
+		return previous, true, false
+	}
+	keys[verdict.Key] = &verdict
+	hooks := v.record(verdict.Scope, verdict.Key, previous, &verdict)
+	v.mutex.Unlock()
+	v.flush()
+
+	for _, fn := range hooks {
+		fn()
+	}
+	return previous, existed, true
+}
+
+// Remove deletes the verdict on key and returns it.
+func (v *Verdicts) Remove(scope, key string) (*types.Verdict, bool) {
+	v.mutex.Lock()
+	keys, exists := v.byScope[scope]
+	if !exists {
+		v.mutex.Unlock()
+		return nil, false
+	}
+	previous, existed := keys[key]
+	if !existed {
+		v.mutex.Unlock()
+		return nil, false
+	}
+	delete(keys, key)
+	hooks := v.record(scope, key, previous, nil)
+	v.mutex.Unlock()
+	v.flush()
+
+	for _, fn := range hooks {
+		fn()
+	}
+	return previous, true
+}
+
+// record numbers a change and remembers it. It must be called with the
+// mutex held; the caller flushes the file once it is released, then calls
+// the hooks it returns.
+func (v *Verdicts) record(scope, key string, before, after *types.Verdict) []func() {
+	v.serial++
+	v.changes = append(v.changes, VerdictChange{Serial: v.serial, Scope: scope, Key: key, Before: before, After: after})
+	if excess := len(v.changes) - constants.VERDICT_HISTORY; excess > 0 {
+		v.changes = append(v.changes[:0:0], v.changes[excess:]...)
+	}
+	v.dirty = true
+	return v.hooks
+}
+
+// Serial numbers the latest change; it is zero before the first one.
+func (v *Verdicts) Serial() uint64 {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.serial
+}
+
+// Snapshot returns the current serial and every verdict, ordered by scope
+// and key, as of that serial.
+func (v *Verdicts) Snapshot() (uint64, []types.Verdict) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.serial, v.list("", "")
+}
+
+// ChangesSince returns the changes numbered after since, oldest first, and
+// the current serial. It reports false when some of those changes are no
+// longer kept, as after a restart or more than VERDICT_HISTORY changes.
+func (v *Verdicts) ChangesSince(since uint64) ([]VerdictChange, uint64, bool) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	if since >= v.serial {
+		return nil, v.serial, since == v.serial
+	}
+	if len(v.changes) == 0 || v.changes[0].Serial > since+1 {
+		return nil, v.serial, false
+	}
+	first := int(since + 1 - v.changes[0].Serial)
+	return append([]VerdictChange(nil), v.changes[first:]...), v.serial, true
+}
+
+// Get returns a copy of the verdict on key.
+func (v *Verdicts) Get(scope, key string) (types.Verdict, bool) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	verdict, exists := v.byScope[scope][key]
+	if !exists {
+		return types.Verdict{}, false
+	}
+	return *verdict, true
+}
+
+// List returns the verdicts in scope with classification, either of which
+// may be empty to match all, ordered by scope and key.
+func (v *Verdicts) List(scope, classification string) []types.Verdict {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.list(scope, classification)
+}
+
+// list must be called with the mutex held.
+func (v *Verdicts) list(scope, classification string) []types.Verdict {
+	verdicts := []types.Verdict{}
+	for s, keys := range v.byScope {
+		if scope != "" && s != scope {
+			continue
+		}
+		for _, verdict := range keys {
+			if classification == "" || verdict.Classification == classification {
+				verdicts = append(verdicts, *verdict)
+			}
+		}
+	}
+
+	sort.Slice(verdicts, func(i, j int) bool {
+		if verdicts[i].Scope != verdicts[j].Scope {
+			return verdicts[i].Scope < verdicts[j].Scope
+		}
+		return verdicts[i].Key < verdicts[j].Key
+	})
+	return verdicts
+}
+
+// Lookup finds the most specific verdict covering rawURL: one on the exact
+// URL, else on its host, else on its registrable domain. A bare host may
+// be given instead of a URL.
+func (v *Verdicts) Lookup(rawURL string) types.LookupResult {
+	result := types.LookupResult{URL: rawURL, Classification: types.VerdictUnknown}
+	result.Host, result.Domain = HostAndDomain(rawURL)
+
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	for _, candidate := range []struct{ scope, key string }{
+		{types.ScopeURL, rawURL},
+		{types.ScopeHost, result.Host},
+		{types.ScopeDomain, result.Domain},
+	} {
+		if candidate.key == "" {
+			continue
+		}
+		if verdict, exists := v.byScope[candidate.scope][candidate.key]; exists {
+			match := *verdict
+			result.Match = &match
+			result.Classification = match.Classification
+			result.Listed = match.Listed()
+			break
+		}
+	}
+	return result
+}
+
+// HostAndDomain returns the lower-cased host of rawURL, or of a bare host,
+// and its registrable domain. Either is empty when it cannot be found.
+func HostAndDomain(rawURL string) (string, string) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		parsed, err = url.Parse("http://" + rawURL)
+		if err != nil {
+			return "", ""
+		}
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "" {
+		return "", ""
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), ""
+	}
+	domain, err := publicsuffix.RegistrableDomain(host)
+	if err != nil {
+		return host, ""
+	}
+	return host, domain
+}
+
+// Count returns how many verdicts are stored.
+func (v *Verdicts) Count() int {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	n := 0
+	for _, keys := range v.byScope {
+		n += len(keys)
+	}
+	return n
+}
+
+// flush writes the verdicts to disk if they changed since the last write.
+// It must be called without the mutex held; only the list of verdicts,
+// which are never changed in place, is taken under it.
+func (v *Verdicts) flush() {
+	v.saving.Lock()
+	defer v.saving.Unlock()
+
+	v.mutex.Lock()
+	if !v.dirty {
+		v.mutex.Unlock()
+		return
+	}
+	v.dirty = false
+	saved := verdictsFile{Serial: v.serial, Verdicts: make([]*types.Verdict, 0)}
+	for _, keys := range v.byScope {
+		for _, verdict := range keys {
+			saved.Verdicts = append(saved.Verdicts, verdict)
+		}
+	}
+	v.mutex.Unlock()
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		slog.Error("marshaling verdicts", "error", err)
+		return
+	}
+	tmp := v.filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		slog.Error("writing verdicts file", "file", tmp, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, v.filePath); err != nil {
+		slog.Error("replacing verdicts file", "file", v.filePath, "error", err)
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerdictLookupPrefersMostSpecific(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "verdicts.json")
+	verdicts := NewVerdicts(path)
+	verdicts.Set(types.Verdict{Scope: types.ScopeDomain, Key: "example.co.uk", Classification: types.VerdictMalicious})
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "safe.example.co.uk", Classification: types.VerdictClean})
+	verdicts.Set(types.Verdict{Scope: types.ScopeURL, Key: "http://safe.example.co.uk/kit.zip", Classification: types.VerdictSuspicious})
+
+	result := verdicts.Lookup("http://www.example.co.uk/login")
+	assert.True(t, result.Listed)
+	assert.Equal(t, types.ScopeDomain, result.Match.Scope)
+	assert.Equal(t, "www.example.co.uk", result.Host)
+	assert.Equal(t, "example.co.uk", result.Domain)
+
+	result = verdicts.Lookup("https://SAFE.example.co.uk./index.html")
+	assert.False(t, result.Listed)
+	assert.Equal(t, types.VerdictClean, result.Classification)
+	assert.Equal(t, types.ScopeHost, result.Match.Scope)
+
+	result = verdicts.Lookup("http://safe.example.co.uk/kit.zip")
+	assert.True(t, result.Listed)
+	assert.Equal(t, types.ScopeURL, result.Match.Scope)
+
+	result = verdicts.Lookup("other.org")
+	assert.Equal(t, types.VerdictUnknown, result.Classification)
+	assert.Nil(t, result.Match)
+	assert.Equal(t, "other.org", result.Host)
+
+	restored := NewVerdicts(path)
+	restored.Load()
+	assert.Equal(t, 3, restored.Count())
+	assert.True(t, restored.Lookup("http://a.example.co.uk").Listed)
+}
+
+func TestNormalizeVerdict(t *testing.T) {
+	verdict := types.Verdict{Scope: types.ScopeHost, Key: "WWW.Example.COM.", Classification: types.VerdictSuspicious}
+	assert.NoError(t, NormalizeVerdict(&verdict))
+	assert.Equal(t, "www.example.com", verdict.Key)
+
+	verdict = types.Verdict{Scope: types.ScopeHost, Key: "[0:0::1]", Classification: types.VerdictSuspicious}
+	assert.NoError(t, NormalizeVerdict(&verdict))
+	assert.Equal(t, "::1", verdict.Key)
+
+	for _, bad := range []types.Verdict{
+		{Scope: types.ScopeHost, Key: "http://example.com/", Classification: types.VerdictClean},
+		{Scope: types.ScopeHost, Key: "evil.com\n$INCLUDE\tx", Classification: types.VerdictClean},
+		{Scope: types.ScopeHost, Key: "a;b.com", Classification: types.VerdictClean},
+		{Scope: types.ScopeHost, Key: "a_b.com", Classification: types.VerdictClean},
+		{Scope: types.ScopeHost, Key: "-a.com", Classification: types.VerdictClean},
+		{Scope: types.ScopeHost, Key: "a..com", Classification: types.VerdictClean},
+		{Scope: types.ScopeDomain, Key: "1.2.3.4", Classification: types.VerdictClean},
+		{Scope: types.ScopeDomain, Key: "::1", Classification: types.VerdictClean},
+		{Scope: types.ScopeURL, Key: "http://a.com/\r\nx", Classification: types.VerdictClean},
+		{Scope: types.ScopeURL, Key: "http://a.com", Classification: types.VerdictClean, Reasons: []string{"a\nb"}},
+		{Scope: types.ScopeDomain, Key: "co.uk", Classification: types.VerdictClean},
+		{Scope: types.ScopeDomain, Key: "www.example.com", Classification: types.VerdictClean},
+		{Scope: types.ScopeURL, Key: "http://a.com", Classification: "bad"},
+		{Scope: types.ScopeURL, Key: "http://a.com", Classification: types.VerdictClean, Reasons: []string{""}},
+	} {
+		assert.Error(t, NormalizeVerdict(&bad), "%+v", bad)
+	}
+}
+
+func TestVerdictSerialsAndChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "verdicts.json")
+	verdicts := NewVerdicts(path)
+	changed := 0
+	verdicts.OnChange(func() { changed++ })
+
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "a.example", Classification: types.VerdictMalicious})
+	verdicts.Set(types.Verdict{Scope: types.ScopeHost, Key: "b.example", Classification: types.VerdictSuspicious})
+	verdicts.Remove(types.ScopeHost, "a.example")
+	verdicts.Remove(types.ScopeHost, "missing.example")
+	assert.Equal(t, uint64(3), verdicts.Serial())
+	assert.Equal(t, 3, changed)
+
+	changes, serial, ok := verdicts.ChangesSince(1)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), serial)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, "b.example", changes[0].Key)
+	assert.Nil(t, changes[0].Before)
+	assert.Equal(t, "a.example", changes[1].Key)
+	assert.Nil(t, changes[1].After)
+
+	changes, _, ok = verdicts.ChangesSince(3)
+	assert.True(t, ok)
+	assert.Empty(t, changes)
+
+	// The serial survives a restart but the changes before it do not.
+	restored := NewVerdicts(path)
+	restored.Load()
+	assert.Equal(t, uint64(3), restored.Serial())
+	_, _, ok = restored.ChangesSince(1)
+	assert.False(t, ok)
+	_, _, ok = restored.ChangesSince(3)
+	assert.True(t, ok)
+	_, _, ok = restored.ChangesSince(4)
+	assert.False(t, ok)
+}
+
+func TestLoadVerdictsWithoutSerial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "verdicts.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"scope":"domain","key":"old.example","classification":"malicious"}]`), 0644))
+	verdicts := NewVerdicts(path)
+	verdicts.Load()
+	assert.Equal(t, 1, verdicts.Count())
+	assert.Equal(t, uint64(1), verdicts.Serial())
+	_, _, ok := verdicts.ChangesSince(0)
+	assert.False(t, ok)
+}
+
+func TestSetAutomaticDoesNotDowngrade(t *testing.T) {
+	verdicts := NewVerdicts(filepath.Join(t.TempDir(), "verdicts.json"))
+	set := func(classification, setBy string) bool {
+		_, _, stored := verdicts.SetAutomatic(types.Verdict{Scope: types.ScopeDomain, Key: "auto.example",
+			Classification: classification, SetBy: setBy})
+		return stored
+	}
+
+	assert.True(t, set(types.VerdictSuspicious, "rule:a"))
+	assert.True(t, set(types.VerdictMalicious, "hash:kit"))
+	assert.False(t, set(types.VerdictClean, "rule:a"))
+	assert.False(t, set(types.VerdictSuspicious, "rule:b"))
+	assert.False(t, set(types.VerdictMalicious, "rule:b"), "same classification")
+	// The actor that set a verdict may change it.
+	assert.True(t, set(types.VerdictSuspicious, "hash:kit"))
+
+	verdicts.Set(types.Verdict{Scope: types.ScopeDomain, Key: "auto.example", Classification: types.VerdictClean, SetBy: "token:ops"})
+	assert.False(t, set(types.VerdictMalicious, "hash:kit"))
+}
@@ -0,0 +1,328 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ExporterNone = "none"
+	ExporterFile = "file"
+	ExporterOTLP = "otlp"
+
+	batchSize     = 100
+	batchInterval = 2 * time.Second
+	queueSize     = 2048
+)
+
+// Exporter delivers finished spans encoded as one OTLP/JSON request.
+type Exporter interface {
+	Export(payload []byte) error
+}
+
+// FileExporter appends each batch as one JSON line.
+type FileExporter struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{file: file}, nil
+}
+
+func (e *FileExporter) Export(payload []byte) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	_, err := e.file.Write(append(payload, '\n'))
+	return err
+}
+
+// HTTPExporter posts each batch to an OTLP/HTTP collector, e.g.
+// http://localhost:4318/v1/traces.
+type HTTPExporter struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+func (e *HTTPExporter) Export(payload []byte) error {
+	resp, err := e.Client.Post(e.Endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+type pipeline struct {
+	service  string
+	exporter Exporter
+	spans    chan *Span
+	flush    chan chan struct{}
+}
+
+var (
+	pipelineMutex sync.RWMutex
+	active        *pipeline
+)
+
+// Init starts exporting spans for service through exporter ("none",
+// "file" or "otlp"); target is the file path or collector URL.
+func Init(service, exporter, target string) error {
+	var e Exporter
+	switch exporter {
+	case "", ExporterNone:
+		return nil
+	case ExporterFile:
+		fe, err := NewFileExporter(target)
+		if err != nil {
+			return err
+		}
+		e = fe
+	case ExporterOTLP:
+		e = &HTTPExporter{Endpoint: target, Client: &http.Client{Timeout: 10 * time.Second}}
+	default:
+		return fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	SetExporter(service, e)
+	return nil
+}
+
+// SetExporter replaces the active exporter, flushing the previous one.
+func SetExporter(service string, exporter Exporter) {
+	p := &pipeline{
+		service:  service,
+		exporter: exporter,
+		spans:    make(chan *Span, queueSize),
+		flush:    make(chan chan struct{}),
+	}
+	go p.run()
+
+	pipelineMutex.Lock()
+	previous := active
+	active = p
+	pipelineMutex.Unlock()
+	if previous != nil {
+		previous.shutdown()
+	}
+}
+
+// Shutdown exports the spans still buffered and stops exporting.
+func Shutdown() {
+	pipelineMutex.Lock()
+	p := active
+	active = nil
+	pipelineMutex.Unlock()
+	if p != nil {
+		p.shutdown()
+	}
+}
+
+// Enabled reports whether finished spans go anywhere.
+func Enabled() bool {
+	pipelineMutex.RLock()
+	defer pipelineMutex.RUnlock()
+	return active != nil
+}
+
+func export(span *Span) {
+	pipelineMutex.RLock()
+	defer pipelineMutex.RUnlock()
+	if active == nil {
+		return
+	}
+	select {
+	case active.spans <- span:
+	default:
+		// Dropping spans beats blocking the request path.
+	}
+}
+
+func (p *pipeline) run() {
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		payload, err := json.Marshal(encode(p.service, batch))
+		if err == nil {
+			err = p.exporter.Export(payload)
+		}
+		if err != nil {
+			slog.Warn("exporting spans", "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case span := <-p.spans:
+			batch = append(batch, span)
+			if len(batch) >= batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-p.flush:
+			for len(p.spans) > 0 {
+				batch = append(batch, <-p.spans)
+			}
+			send()
+			close(done)
+			return
+		}
+	}
+}
+
+func (p *pipeline) shutdown() {
+	done := make(chan struct{})
+	p.flush <- done
+	<-done
+}
+
+// The OTLP/JSON wire format, trimmed to the fields spans here use.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+// Status codes follow the OTLP enum: 0 unset, 2 error.
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func encode(service string, spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mutex.Lock()
+		span := otlpSpan{
+			TraceID:           s.Context.TraceID.String(),
+			SpanID:            s.Context.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: unixNano(s.StartTime),
+			EndTimeUnixNano:   unixNano(s.EndTime),
+			Attributes:        attributes(s.Attributes),
+		}
+		if s.Parent.IsValid() {
+			span.ParentSpanID = s.Parent.String()
+		}
+		for _, e := range s.Events {
+			span.Events = append(span.Events, otlpEvent{
+				TimeUnixNano: unixNano(e.Time),
+				Name:         e.Name,
+				Attributes:   attributes(e.Attributes),
+			})
+		}
+		if s.ErrorMessage != "" {
+			span.Status = otlpStatus{Code: 2, Message: s.ErrorMessage}
+		}
+		s.mutex.Unlock()
+		encoded = append(encoded, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: attributes(map[string]any{"service.name": service})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/Dev-AustinPeter/spamhaus-take-home-task/tracing"},
+			Spans: encoded,
+		}},
+	}}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func attributes(values map[string]any) []otlpKeyValue {
+	if len(values) == 0 {
+		return nil
+	}
+	kvs := make([]otlpKeyValue, 0, len(values))
+	for key, value := range values {
+		kvs = append(kvs, otlpKeyValue{Key: key, Value: anyValue(value)})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+// anyValue encodes v as an OTLP AnyValue; 64-bit integers are strings in
+// OTLP/JSON.
+func anyValue(v any) map[string]any {
+	switch v := v.(type) {
+	case string:
+		return map[string]any{"stringValue": v}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case int:
+		return map[string]any{"intValue": strconv.FormatInt(int64(v), 10)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]any{"doubleValue": v}
+	case time.Duration:
+		return map[string]any{"intValue": strconv.FormatInt(int64(v), 10)}
+	}
+	return map[string]any{"stringValue": fmt.Sprint(v)}
+}
@@ -0,0 +1,201 @@
+// Package tracing records OpenTelemetry-style spans, propagates them with
+// the W3C traceparent header and exports them as OTLP/JSON.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+
+type SpanKind int
+
+// Values follow the OTLP SpanKind enum.
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// SpanContext identifies a span, local or received from a caller.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]any
+}
+
+type Span struct {
+	Name         string
+	Kind         SpanKind
+	Context      SpanContext
+	Parent       SpanID
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]any
+	Events       []Event
+	ErrorMessage string
+
+	mutex sync.Mutex
+	ended bool
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start begins a span named name as a child of the span in ctx, or of the
+// remote parent carried by ctx, or as a new trace root.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		Name:       name,
+		Kind:       kind,
+		StartTime:  time.Now(),
+		Attributes: map[string]any{},
+	}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.Context.TraceID = parent.Context.TraceID
+		span.Context.Sampled = parent.Context.Sampled
+		span.Parent = parent.Context.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		span.Context.TraceID = remote.TraceID
+		span.Context.Sampled = remote.Sampled
+		span.Parent = remote.SpanID
+	} else {
+		rand.Read(span.Context.TraceID[:])
+		span.Context.Sampled = true
+	}
+	rand.Read(span.Context.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Record adds an already finished child span of the span in ctx, for phases
+// whose timings were captured by other means.
+func Record(ctx context.Context, name string, start, end time.Time, attributes map[string]any) {
+	_, span := Start(ctx, name, KindInternal)
+	span.StartTime = start
+	if attributes != nil {
+		span.Attributes = attributes
+	}
+	span.finish(end)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// WithRemoteParent returns a copy of ctx whose next span continues the
+// trace described by sc.
+func WithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Attributes[key] = value
+}
+
+func (s *Span) AddEvent(name string, attributes map[string]any) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Events = append(s.Events, Event{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+// RecordError marks the span as failed with err.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ErrorMessage = err.Error()
+}
+
+// End finishes the span and hands it to the exporter. Later calls are no-ops.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.finish(time.Now())
+}
+
+func (s *Span) finish(end time.Time) {
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = end
+	s.mutex.Unlock()
+
+	if s.Context.Sampled {
+		export(s)
+	}
+}
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(header string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, fmt.Errorf("malformed traceparent")
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, fmt.Errorf("malformed traceparent")
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("malformed traceparent")
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("malformed trace id")
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("malformed span id")
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, fmt.Errorf("malformed trace flags")
+	}
+	if !sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return sc, fmt.Errorf("all-zero trace or span id")
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryExporter struct {
+	mutex    sync.Mutex
+	payloads []otlpRequest
+}
+
+func (e *memoryExporter) Export(payload []byte) error {
+	var request otlpRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.payloads = append(e.payloads, request)
+	return nil
+}
+
+func (e *memoryExporter) spans() []otlpSpan {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var spans []otlpSpan
+	for _, p := range e.payloads {
+		for _, rs := range p.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, err := ParseTraceparent(header)
+		assert.Error(t, err, header)
+	}
+}
+
+func TestStartInheritsTrace(t *testing.T) {
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := Start(WithRemoteParent(context.Background(), remote), "root", KindServer)
+	_, child := Start(ctx, "child", KindInternal)
+
+	assert.Equal(t, remote.TraceID, root.Context.TraceID)
+	assert.Equal(t, remote.SpanID, root.Parent)
+	assert.Equal(t, root.Context.TraceID, child.Context.TraceID)
+	assert.Equal(t, root.Context.SpanID, child.Parent)
+	assert.NotEqual(t, root.Context.SpanID, child.Context.SpanID)
+
+	_, fresh := Start(context.Background(), "fresh", KindInternal)
+	assert.True(t, fresh.Context.TraceID.IsValid())
+	assert.False(t, fresh.Parent.IsValid())
+}
+
+func TestExportOTLPJSON(t *testing.T) {
+	exporter := &memoryExporter{}
+	SetExporter("test-service", exporter)
+
+	ctx, span := Start(context.Background(), "fetch", KindClient)
+	span.SetAttribute("url.full", "https://example.com")
+	span.SetAttribute("http.response.status_code", 200)
+	span.AddEvent("semaphore.acquired", nil)
+	start := time.Now()
+	Record(ctx, "fetch.dns", start, start.Add(time.Millisecond), nil)
+	span.RecordError(errors.New("boom"))
+	span.End()
+	span.End()
+
+	Shutdown()
+	assert.False(t, Enabled())
+
+	spans := exporter.spans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "fetch.dns", spans[0].Name)
+	assert.Equal(t, span.Context.SpanID.String(), spans[0].ParentSpanID)
+
+	fetch := spans[1]
+	assert.Equal(t, "fetch", fetch.Name)
+	assert.Equal(t, KindClient, fetch.Kind)
+	assert.Equal(t, span.Context.TraceID.String(), fetch.TraceID)
+	assert.Empty(t, fetch.ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: 2, Message: "boom"}, fetch.Status)
+	assert.Len(t, fetch.Events, 1)
+	assert.Equal(t, []otlpKeyValue{
+		{Key: "http.response.status_code", Value: map[string]any{"intValue": "200"}},
+		{Key: "url.full", Value: map[string]any{"stringValue": "https://example.com"}},
+	}, fetch.Attributes)
+
+	resource := exporter.payloads[0].ResourceSpans[0].Resource
+	assert.Equal(t, "service.name", resource.Attributes[0].Key)
+	assert.Equal(t, map[string]any{"stringValue": "test-service"}, resource.Attributes[0].Value)
+}
+
+func TestUnsampledSpansAreNotExported(t *testing.T) {
+	exporter := &memoryExporter{}
+	SetExporter("test-service", exporter)
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span := Start(WithRemoteParent(context.Background(), remote), "root", KindServer)
+	span.End()
+
+	Shutdown()
+	assert.Empty(t, exporter.spans())
+}
@@ -2,8 +2,25 @@ package types
 
 import "time"
 
+// RequestUrlPayload is a submission. Everything but URL is optional
+// metadata from the submitting feed; Confidence and ReferenceID need a
+// Source to be attributed to.
 type RequestUrlPayload struct {
-	URL string `json:"url"`
+	URL         string   `json:"url"`
+	Source      string   `json:"source,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Confidence  *int     `json:"confidence,omitempty"`
+	ReferenceID string   `json:"reference_id,omitempty"`
+}
+
+// SourceStats aggregates the submissions of a URL by one source.
+// Confidence and ReferenceID are the latest the source reported.
+type SourceStats struct {
+	Submissions int       `json:"submissions"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Confidence  *int      `json:"confidence,omitempty"`
+	ReferenceID string    `json:"reference_id,omitempty"`
 }
 
 type URLData struct {
@@ -14,4 +31,288 @@ type URLData struct {
 	SuccessCount int       `json:"success_count"`
 	FailureCount int       `json:"failure_count"`
 	CreatedAt    time.Time `json:"created_at"`
+	// LastSubmitted is when the URL was last submitted; zero for records
+	// saved before it was tracked.
+	LastSubmitted time.Time `json:"last_submitted,omitempty"`
+	// History counts recent submissions in rolling minute, hour and day
+	// buckets.
+	History *SubmissionHistory `json:"history,omitempty"`
+
+	// LastFetchStatus is the outcome of the most recent fetch: one of the
+	// FetchStatus values, empty if the URL was never fetched.
+	LastFetchStatus string `json:"last_fetch_status,omitempty"`
+
+	// Validators from the last full response, replayed as If-None-Match and
+	// If-Modified-Since so unchanged resources come back as 304.
+	ETag             string `json:"etag,omitempty"`
+	LastModified     string `json:"last_modified,omitempty"`
+	ContentLength    int64  `json:"content_length,omitempty"`
+	NotModifiedCount int    `json:"not_modified_count"`
+	// ContentHash is the hex SHA-256 of the last full body, empty when it
+	// was cut off at MAX_FETCH_BODY.
+	ContentHash string `json:"content_hash,omitempty"`
+	FuzzyHash   string `json:"fuzzy_hash,omitempty"`
+	BytesSaved  int64  `json:"bytes_saved"`
+	// HashMatch is the known-bad hash the last full body matched, if any.
+	HashMatch *HashMatch `json:"hash_match,omitempty"`
+
+	// Timings holds the phase breakdown of the most recent fetches, oldest
+	// first.
+	Timings []FetchTimings `json:"timings,omitempty"`
+
+	// Pinned URLs are always queued by the background fetch; Excluded URLs
+	// are never fetched. Both are set through the admin API.
+	Pinned   bool `json:"pinned,omitempty"`
+	Excluded bool `json:"excluded,omitempty"`
+
+	// Sources aggregates submissions per source name and Tags counts how
+	// often each tag was attached.
+	Sources map[string]*SourceStats `json:"sources,omitempty"`
+	Tags    map[string]int          `json:"tags,omitempty"`
+}
+
+// FetchTimings breaks one fetch down by phase, in seconds. DNS, Connect and
+// TLS are zero when a kept-alive connection was reused.
+type FetchTimings struct {
+	At      time.Time `json:"at"`
+	DNS     float64   `json:"dns"`
+	Connect float64   `json:"connect"`
+	TLS     float64   `json:"tls"`
+	TTFB    float64   `json:"ttfb"`
+	Body    float64   `json:"body"`
+	Total   float64   `json:"total"`
+	Reused  bool      `json:"reused,omitempty"`
+}
+
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// TimingStats summarises the recent fetch timings of one URL, one host or
+// every URL. Phase percentiles only cover fetches that went through the
+// phase.
+type TimingStats struct {
+	URL     string      `json:"url,omitempty"`
+	Host    string      `json:"host,omitempty"`
+	Samples int         `json:"samples"`
+	DNS     Percentiles `json:"dns"`
+	Connect Percentiles `json:"connect"`
+	TLS     Percentiles `json:"tls"`
+	TTFB    Percentiles `json:"ttfb"`
+	Body    Percentiles `json:"body"`
+	Total   Percentiles `json:"total"`
+}
+
+const (
+	FetchStatusOK          = "ok"
+	FetchStatusNotModified = "not_modified"
+	FetchStatusFailed      = "failed"
+	FetchStatusHTTPError   = "http_error" // answered with neither 2xx nor 304
+	FetchStatusNever       = "never"
+)
+
+type Stats struct {
+	URLs         int   `json:"urls"`
+	Submissions  int   `json:"submissions"`
+	SuccessCount int   `json:"success_count"`
+	FailureCount int   `json:"failure_count"`
+	NotModified  int   `json:"not_modified_count"`
+	BytesSaved   int64 `json:"bytes_saved"`
+
+	Timings TimingStats `json:"timings"`
+}
+
+// BucketCounter is a ring of fixed-width time buckets. Last is the index,
+// in bucket widths since the Unix epoch, of the newest bucket written.
+type BucketCounter struct {
+	Last    int64 `json:"last"`
+	Buckets []int `json:"buckets"`
+}
+
+type SubmissionHistory struct {
+	Minutes BucketCounter `json:"minutes"`
+	Hours   BucketCounter `json:"hours"`
+	Days    BucketCounter `json:"days"`
+}
+
+type TrendingURL struct {
+	URL         string  `json:"url"`
+	Submissions int     `json:"submissions"`
+	Velocity    float64 `json:"velocity"` // submissions per hour over the window
+	Count       int     `json:"count"`
+}
+
+type DomainStats struct {
+	Domain            string    `json:"domain"`
+	Submissions       int       `json:"submissions"`
+	DistinctURLs      int       `json:"distinct_urls"`
+	SuccessCount      int       `json:"success_count"`
+	FailureCount      int       `json:"failure_count"`
+	FetchSuccessRatio float64   `json:"fetch_success_ratio"`
+	FirstSeen         time.Time `json:"first_seen"`
+	LastSeen          time.Time `json:"last_seen"`
+}
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+type Job struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Priority  int       `json:"priority"`
+	Status    JobStatus `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	// Traceparent links the fetch to the trace of the request that queued it.
+	Traceparent string     `json:"traceparent,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// BulkDeletePayload selects the URLs for POST /admin/urls/delete: every
+// URL on Domain or its subdomains, or every URL matching Pattern in Mode.
+type BulkDeletePayload struct {
+	Domain  string `json:"domain,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Mode    string `json:"mode,omitempty"`
+	DryRun  bool   `json:"dry_run,omitempty"`
+}
+
+// AdminResult reports what an admin action did, or with DryRun would do.
+type AdminResult struct {
+	Action   string   `json:"action"`
+	Affected int      `json:"affected"`
+	URLs     []string `json:"urls"`
+	DryRun   bool     `json:"dry_run,omitempty"`
+}
+
+// Classifications of a verdict, from least to most severe.
+const (
+	VerdictUnknown    = "unknown"
+	VerdictClean      = "clean"
+	VerdictSuspicious = "suspicious"
+	VerdictMalicious  = "malicious"
+)
+
+// What a verdict applies to: one exact URL, every URL on one host, or
+// every URL under one registrable domain.
+const (
+	ScopeURL    = "url"
+	ScopeHost   = "host"
+	ScopeDomain = "domain"
+)
+
+// Verdict is a classification of a URL, host or registrable domain, set by
+// an analyst, a rule or a known-bad hash.
+type Verdict struct {
+	Scope          string    `json:"scope"`
+	Key            string    `json:"key"`
+	Classification string    `json:"classification"`
+	Reasons        []string  `json:"reasons,omitempty"`
+	SetBy          string    `json:"set_by"`
+	SetAt          time.Time `json:"set_at"`
+}
+
+// Listed reports whether the verdict puts its key on the blocklist.
+func (v Verdict) Listed() bool {
+	return v.Classification == VerdictSuspicious || v.Classification == VerdictMalicious
+}
+
+// VerdictPayload sets a verdict through PUT /admin/verdicts.
+type VerdictPayload struct {
+	Scope          string   `json:"scope"`
+	Key            string   `json:"key"`
+	Classification string   `json:"classification"`
+	Reasons        []string `json:"reasons,omitempty"`
+}
+
+// LookupResult answers GET /lookup: the most specific verdict covering the
+// URL, checked by exact URL, then host, then registrable domain.
+type LookupResult struct {
+	URL            string   `json:"url"`
+	Host           string   `json:"host,omitempty"`
+	Domain         string   `json:"domain,omitempty"`
+	Listed         bool     `json:"listed"`
+	Classification string   `json:"classification"`
+	Match          *Verdict `json:"match,omitempty"`
+}
+
+// FeedInfo describes a blocklist feed and its last generation.
+type FeedInfo struct {
+	Name        string   `json:"name"`
+	ContentType string   `json:"content_type"`
+	Scopes      []string `json:"scopes"`
+	Serial      uint64   `json:"serial"`
+	ETag        string   `json:"etag"`
+	Entries     int      `json:"entries"`
+}
+
+// Kinds of known-bad hash: an exact SHA-256 of a body, or a fuzzy hash that
+// also matches bodies close to it.
+const (
+	HashSHA256 = "sha256"
+	HashFuzzy  = "fuzzy"
+)
+
+// KnownHash is the hash of content known to be bad. A fetched body that
+// matches it gets Classification as the verdict on its URL.
+type KnownHash struct {
+	Type           string    `json:"type"`
+	Value          string    `json:"value"`
+	Name           string    `json:"name,omitempty"`
+	Classification string    `json:"classification"`
+	AddedBy        string    `json:"added_by,omitempty"`
+	AddedAt        time.Time `json:"added_at"`
+}
+
+// HashesPayload adds known-bad hashes through POST /hashes. Type may be
+// left out and is then told from the value.
+type HashesPayload struct {
+	Hashes []KnownHash `json:"hashes"`
+}
+
+// HashesResult answers POST /hashes: how many hashes were new and how many
+// are known now. Stored URLs that match are audited as they are found.
+type HashesResult struct {
+	Added int `json:"added"`
+	Total int `json:"total"`
+}
+
+// HashMatch records which known-bad hash a URL's body matched, and how
+// closely: 100 for an exact match, else the fuzzy score.
+type HashMatch struct {
+	Type           string    `json:"type"`
+	Hash           string    `json:"hash"`
+	Name           string    `json:"name,omitempty"`
+	Classification string    `json:"classification"`
+	Score          int       `json:"score"`
+	MatchedAt      time.Time `json:"matched_at"`
+}
+
+// Snapshot is one version of a URL's body kept in the archive: a body
+// with the same hash on consecutive fetches is one version, seen from
+// FirstSeen to LastSeen. Size is the body's length and StoredSize that
+// of its compressed copy, which versions with the same hash share.
+type Snapshot struct {
+	Hash        string    `json:"hash"`
+	Size        int64     `json:"size"`
+	StoredSize  int64     `json:"stored_size"`
+	ContentType string    `json:"content_type,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// SnapshotList answers GET /url/snapshots, newest version first.
+type SnapshotList struct {
+	URL       string     `json:"url"`
+	Snapshots []Snapshot `json:"snapshots"`
 }
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+// Remove deletes url and returns a copy of its record, reporting whether
+// it was stored.
+func (s *Store) Remove(url string) (types.URLData, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, exists := s.entries.Load(url)
+	if !exists {
+		return types.URLData{}, false
+	}
+	s.entries.Delete(url)
+	s.unindex(url)
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	return *value.(*types.URLData), true
+}
+
+// Reset clears the counters and the source and tag aggregates of url,
+// keeping its creation time, validators and flags. It returns the record
+// as it was and reports whether url was stored.
+func (s *Store) Reset(url string) (types.URLData, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, exists := s.entries.Load(url)
+	if !exists {
+		return types.URLData{}, false
+	}
+	data := value.(*types.URLData)
+	Mutex.Lock()
+	before := *data
+	data.Count = 0
+	data.SuccessCount = 0
+	data.FailureCount = 0
+	data.NotModifiedCount = 0
+	data.BytesSaved = 0
+	data.FetchTime = 0
+	data.History = nil
+	data.Timings = nil
+	data.Sources = nil
+	data.Tags = nil
+	Mutex.Unlock()
+	s.index(url, data)
+	return before, true
+}
+
+// SetPinned marks url as always fetched by the background fetcher, or
+// clears the mark. It returns a copy of the record as it was before and
+// reports whether url was stored.
+func (s *Store) SetPinned(url string, pinned bool) (types.URLData, bool) {
+	return s.update(url, func(data *types.URLData) { data.Pinned = pinned })
+}
+
+// SetExcluded marks url as never fetched, or clears the mark, like
+// SetPinned.
+func (s *Store) SetExcluded(url string, excluded bool) (types.URLData, bool) {
+	return s.update(url, func(data *types.URLData) { data.Excluded = excluded })
+}
+
+func (s *Store) update(url string, fn func(*types.URLData)) (types.URLData, bool) {
+	value, exists := s.entries.Load(url)
+	if !exists {
+		return types.URLData{}, false
+	}
+	data := value.(*types.URLData)
+	Mutex.Lock()
+	defer Mutex.Unlock()
+	before := *data
+	fn(data)
+	return before, true
+}
+
+// Excluded reports whether url is stored and excluded from fetching.
+func (s *Store) Excluded(url string) bool {
+	value, exists := s.entries.Load(url)
+	if !exists {
+		return false
+	}
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	return value.(*types.URLData).Excluded
+}
+
+// Pinned returns the pinned URLs that are not excluded, in URL order.
+func (s *Store) Pinned() []string {
+	var urls []string
+	Mutex.RLock()
+	s.Range(func(_, value interface{}) bool {
+		data := value.(*types.URLData)
+		if data.Pinned && !data.Excluded {
+			urls = append(urls, data.URL)
+		}
+		return true
+	})
+	Mutex.RUnlock()
+	sort.Strings(urls)
+	return urls
+}
+
+// TopFetchable is TopByCount without the excluded URLs.
+func (s *Store) TopFetchable(n int) []*types.URLData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	urls := make([]*types.URLData, 0, min(n, s.byCount.length))
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	s.byCount.descend(nil, func(_ sortKey, data *types.URLData) bool {
+		if !data.Excluded {
+			urls = append(urls, data)
+		}
+		return len(urls) < n
+	})
+	return urls
+}
+
+// BulkDelete selects URLs on a domain (the host itself or any subdomain)
+// or matching a search pattern, exactly one of the two, and deletes them
+// unless dryRun is set. It returns the selected URLs in URL order.
+func (s *Store) BulkDelete(domain string, pattern SearchQuery, dryRun bool) ([]string, error) {
+	if (domain == "") == (pattern.Pattern == "") {
+		return nil, fmt.Errorf("exactly one of domain or pattern is required")
+	}
+	match, literal, err := patternMatcher(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var urls []string
+	if domain != "" {
+		for host, set := range s.hosts {
+			if MatchesDomain(host, domain) {
+				for u := range set {
+					urls = append(urls, u)
+				}
+			}
+		}
+	} else if candidates := s.candidates(pattern, literal); candidates != nil {
+		for u := range candidates {
+			if match(u) {
+				urls = append(urls, u)
+			}
+		}
+	} else {
+		for u := range s.keys {
+			if match(u) {
+				urls = append(urls, u)
+			}
+		}
+	}
+	sort.Strings(urls)
+
+	if !dryRun {
+		for _, u := range urls {
+			s.entries.Delete(u)
+			s.unindex(u)
+		}
+	}
+	return urls, nil
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkDelete(t *testing.T) {
+	store := NewStore()
+	for _, u := range []string{
+		"http://example.com/a",
+		"http://www.example.com/b",
+		"http://notexample.com/c",
+		"http://other.org/login.php",
+		"http://other.org/index.html",
+	} {
+		store.Submit(u)
+	}
+
+	_, err := store.BulkDelete("", SearchQuery{}, false)
+	assert.Error(t, err)
+	_, err = store.BulkDelete("example.com", SearchQuery{Pattern: "x"}, false)
+	assert.Error(t, err)
+
+	urls, err := store.BulkDelete("example.com", SearchQuery{}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://example.com/a", "http://www.example.com/b"}, urls)
+	_, exists := store.Load("http://example.com/a")
+	assert.True(t, exists, "a dry run deletes nothing")
+
+	urls, err = store.BulkDelete("example.com", SearchQuery{}, false)
+	assert.NoError(t, err)
+	assert.Len(t, urls, 2)
+	_, exists = store.Load("http://www.example.com/b")
+	assert.False(t, exists)
+	assert.Nil(t, store.hosts["www.example.com"])
+
+	urls, err = store.BulkDelete("", SearchQuery{Pattern: `\.php$`, Mode: MatchRegex}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://other.org/login.php"}, urls)
+
+	urls, err = store.BulkDelete("", SearchQuery{Pattern: "http://other.org/index.html", Mode: MatchExact}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://other.org/index.html"}, urls)
+
+	remaining, _, err := store.Search(SearchQuery{Pattern: "http", Mode: MatchSubstring, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, "http://notexample.com/c", remaining[0].URL)
+}
+
+func TestResetPinAndExclude(t *testing.T) {
+	store := NewStore()
+	for i := 0; i < 3; i++ {
+		store.Submit("http://busy.com")
+	}
+	store.Submit("http://quiet.com")
+	store.Submit("http://pinned.com")
+
+	before, ok := store.Reset("http://busy.com")
+	assert.True(t, ok)
+	assert.Equal(t, 3, before.Count)
+	_, ok = store.Reset("http://missing.com")
+	assert.False(t, ok)
+	value, _ := store.Load("http://busy.com")
+	data := value.(*types.URLData)
+	assert.Equal(t, 0, data.Count)
+	assert.Nil(t, data.History)
+	assert.False(t, data.CreatedAt.IsZero())
+
+	before, ok = store.SetPinned("http://pinned.com", true)
+	assert.True(t, ok)
+	assert.False(t, before.Pinned)
+	_, ok = store.SetPinned("http://missing.com", true)
+	assert.False(t, ok)
+	_, ok = store.SetExcluded("http://quiet.com", true)
+	assert.True(t, ok)
+	assert.True(t, store.Excluded("http://quiet.com"))
+	assert.False(t, store.Excluded("http://missing.com"))
+
+	assert.Equal(t, []string{"http://pinned.com"}, store.Pinned())
+	var top []string
+	for _, data := range store.TopFetchable(5) {
+		top = append(top, data.URL)
+	}
+	assert.NotContains(t, top, "http://quiet.com")
+	assert.Len(t, top, 2)
+
+	removed, ok := store.Remove("http://busy.com")
+	assert.True(t, ok)
+	assert.Equal(t, "http://busy.com", removed.URL)
+	_, ok = store.Remove("http://busy.com")
+	assert.False(t, ok)
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/publicsuffix"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+const (
+	GroupByHost        = "host"
+	GroupByRegistrable = "registrable"
+
+	DomainSortSubmissions  = "submissions"
+	DomainSortDistinctURLs = "distinct_urls"
+	DomainSortSuccessRatio = "success_ratio"
+	DomainSortFirstSeen    = "first_seen"
+	DomainSortLastSeen     = "last_seen"
+	DomainSortDomain       = "domain"
+)
+
+// DomainQuery selects one page of per-domain aggregates.
+type DomainQuery struct {
+	GroupBy    string
+	Sort       string
+	Descending bool
+	Limit      int
+	Cursor     string
+}
+
+func ValidDomainSort(field string) bool {
+	switch field {
+	case DomainSortSubmissions, DomainSortDistinctURLs, DomainSortSuccessRatio,
+		DomainSortFirstSeen, DomainSortLastSeen, DomainSortDomain:
+		return true
+	}
+	return false
+}
+
+// DomainOf returns the grouping key of host under groupBy, or "" when host
+// has none (for instance a bare public suffix).
+func DomainOf(host, groupBy string) string {
+	if groupBy != GroupByRegistrable {
+		return host
+	}
+	domain, err := publicsuffix.RegistrableDomain(host)
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+func domainKey(stats *types.DomainStats, field string) sortKey {
+	key := sortKey{URL: stats.Domain}
+	switch field {
+	case DomainSortSubmissions:
+		key.I = int64(stats.Submissions)
+	case DomainSortDistinctURLs:
+		key.I = int64(stats.DistinctURLs)
+	case DomainSortSuccessRatio:
+		key.F = stats.FetchSuccessRatio
+	case DomainSortFirstSeen:
+		key.I = stats.FirstSeen.UnixNano()
+	case DomainSortLastSeen:
+		key.I = stats.LastSeen.UnixNano()
+	}
+	return key
+}
+
+// Domains returns one page of per-domain aggregates and the cursor for the
+// next page.
+func Domains(q DomainQuery) ([]types.DomainStats, string, error) {
+	return URLStore.Domains(q)
+}
+
+// Domains aggregates stored URLs by host or registrable domain, walking the
+// host index so each URL is visited once.
+func (s *Store) Domains(q DomainQuery) ([]types.DomainStats, string, error) {
+	var after *sortKey
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if c.Sort != q.GroupBy+":"+q.Sort || c.Descending != q.Descending {
+			return nil, "", fmt.Errorf("cursor does not match sort order")
+		}
+		after = &c.After
+	}
+
+	aggregates := make(map[string]*types.DomainStats)
+
+	s.mutex.Lock()
+	Mutex.RLock()
+	for host, urls := range s.hosts {
+		domain := DomainOf(host, q.GroupBy)
+		if domain == "" {
+			continue
+		}
+		stats, exists := aggregates[domain]
+		if !exists {
+			stats = &types.DomainStats{Domain: domain}
+			aggregates[domain] = stats
+		}
+		for u := range urls {
+			value, exists := s.entries.Load(u)
+			if !exists {
+				continue
+			}
+			data := value.(*types.URLData)
+			lastSeen := data.LastSubmitted
+			if lastSeen.IsZero() {
+				lastSeen = data.CreatedAt
+			}
+
+			stats.Submissions += data.Count
+			stats.DistinctURLs++
+			stats.SuccessCount += data.SuccessCount
+			stats.FailureCount += data.FailureCount
+			if stats.FirstSeen.IsZero() || data.CreatedAt.Before(stats.FirstSeen) {
+				stats.FirstSeen = data.CreatedAt
+			}
+			if lastSeen.After(stats.LastSeen) {
+				stats.LastSeen = lastSeen
+			}
+		}
+	}
+	Mutex.RUnlock()
+	s.mutex.Unlock()
+
+	type entry struct {
+		key   sortKey
+		stats *types.DomainStats
+	}
+	entries := make([]entry, 0, len(aggregates))
+	for _, stats := range aggregates {
+		if fetches := stats.SuccessCount + stats.FailureCount; fetches > 0 {
+			stats.FetchSuccessRatio = float64(stats.SuccessCount) / float64(fetches)
+		}
+		key := domainKey(stats, q.Sort)
+		if after != nil {
+			if q.Descending && !key.less(*after) || !q.Descending && !after.less(key) {
+				continue
+			}
+		}
+		entries = append(entries, entry{key: key, stats: stats})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if q.Descending {
+			return entries[j].key.less(entries[i].key)
+		}
+		return entries[i].key.less(entries[j].key)
+	})
+
+	next := ""
+	if len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		next = encodeCursor(cursor{Sort: q.GroupBy + ":" + q.Sort, Descending: q.Descending, After: entries[len(entries)-1].key})
+	}
+
+	domains := make([]types.DomainStats, len(entries))
+	for i, e := range entries {
+		domains[i] = *e.stats
+	}
+	return domains, next, nil
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainsAggregatesByRegistrableDomain(t *testing.T) {
+	store := NewStore()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.Store("http://a.example.co.uk/1", &types.URLData{URL: "http://a.example.co.uk/1", Count: 3, SuccessCount: 3, FailureCount: 1, CreatedAt: base})
+	store.Store("http://b.example.co.uk/2", &types.URLData{URL: "http://b.example.co.uk/2", Count: 2, SuccessCount: 1, CreatedAt: base.Add(time.Hour), LastSubmitted: base.Add(5 * time.Hour)})
+	store.Store("http://other.com/", &types.URLData{URL: "http://other.com/", Count: 10, CreatedAt: base.Add(2 * time.Hour)})
+
+	domains, next, err := store.Domains(DomainQuery{GroupBy: GroupByRegistrable, Sort: DomainSortSubmissions, Descending: true, Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Len(t, domains, 2)
+	assert.Equal(t, "other.com", domains[0].Domain)
+
+	example := domains[1]
+	assert.Equal(t, "example.co.uk", example.Domain)
+	assert.Equal(t, 5, example.Submissions)
+	assert.Equal(t, 2, example.DistinctURLs)
+	assert.InDelta(t, 0.8, example.FetchSuccessRatio, 0.001)
+	assert.Equal(t, base, example.FirstSeen)
+	assert.Equal(t, base.Add(5*time.Hour), example.LastSeen)
+
+	hosts, _, err := store.Domains(DomainQuery{GroupBy: GroupByHost, Sort: DomainSortDomain, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, hosts, 3)
+	assert.Equal(t, "a.example.co.uk", hosts[0].Domain)
+}
+
+func TestDomainsPaginates(t *testing.T) {
+	store := NewStore()
+	for _, u := range []string{"http://a.com/", "http://b.com/", "http://c.com/"} {
+		store.Store(u, &types.URLData{URL: u, Count: 1})
+	}
+
+	query := DomainQuery{GroupBy: GroupByHost, Sort: DomainSortDomain, Limit: 2}
+	first, next, err := store.Domains(query)
+	assert.NoError(t, err)
+	assert.Len(t, first, 2)
+	assert.NotEmpty(t, next)
+
+	query.Cursor = next
+	second, next, err := store.Domains(query)
+	assert.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Equal(t, []types.DomainStats{{Domain: "c.com", Submissions: 1, DistinctURLs: 1}}, second)
+}
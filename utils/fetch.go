@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/fuzzyhash"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/logger"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/tracing"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+var (
+	downloads = newSemaphore(constants.MAX_DOWNLOADS)
+
+	httpClient = &http.Client{
+		Transport: &http.Transport{
+			MaxIdleConns:       10,
+			IdleConnTimeout:    30 * time.Second,
+			DisableCompression: true,
+		},
+	}
+)
+
+func init() {
+	metrics.NewGaugeFunc("fetch_semaphore_in_use", "Downloads currently holding a semaphore slot.",
+		func() float64 { inUse, _ := downloads.usage(); return float64(inUse) })
+	metrics.NewGaugeFunc("fetch_semaphore_capacity", "Maximum concurrent downloads.",
+		func() float64 { _, limit := downloads.usage(); return float64(limit) })
+	metrics.NewGaugeFunc("url_store_size", "Distinct URLs in the store.",
+		func() float64 { return float64(URLStore.Len()) })
+}
+
+// fetchErrorReason classifies a failed fetch for the outcome metric.
+func fetchErrorReason(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var recordErr tls.RecordHeaderError
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection_reset"
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &recordErr):
+		return "tls"
+	}
+	return "other"
+}
+
+// statusClass groups response codes as 2xx, 3xx, 4xx or 5xx.
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+func FetchURL(url string) error {
+	return FetchURLContext(context.Background(), url)
+}
+
+// FetchURLContext downloads url once, bounded by the download semaphore, and
+// records the outcome on its store entry. Log lines carry the request ID
+// from ctx, and the fetch is traced as a child of the span in ctx with one
+// child span per connection phase.
+func FetchURLContext(ctx context.Context, url string) error {
+	ctx, span := tracing.Start(ctx, "fetch", tracing.KindClient)
+	defer span.End()
+	span.SetAttribute("url.full", url)
+	span.SetAttribute("http.request.method", http.MethodGet)
+
+	downloads.acquire()
+	defer downloads.release()
+	span.AddEvent("semaphore.acquired", nil)
+
+	log := logger.FromContext(ctx).With("url", url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		recordFetchFailure(url)
+		metrics.FetchOutcomes.Inc(types.FetchStatusFailed, "invalid_url")
+		span.RecordError(err)
+		log.Error("failed to build fetch request", "error", err)
+
+		return err
+	}
+
+	if data, exists := URLStore.Load(url); exists {
+		Mutex.RLock()
+		if etag := data.(*types.URLData).ETag; etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := data.(*types.URLData).LastModified; lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+		Mutex.RUnlock()
+	}
+
+	traceCtx, timer := withPhaseTimer(ctx)
+	req = req.WithContext(traceCtx)
+
+	start := time.Now()
+	fail := func(err error, reason string) error {
+		timer.recordSpans(ctx)
+		span.SetAttribute("fetch.outcome", types.FetchStatusFailed)
+		span.SetAttribute("error.type", reason)
+		span.RecordError(err)
+		metrics.FetchDuration.Observe(time.Since(start).Seconds(), types.FetchStatusFailed)
+		metrics.FetchOutcomes.Inc(types.FetchStatusFailed, reason)
+		recordFetchFailure(url)
+		log.Error("failed to fetch URL", "reason", reason, "error", err)
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fail(err, fetchErrorReason(err))
+	}
+	defer resp.Body.Close()
+
+	// Read the body, up to a cap, so the timings cover the whole download
+	// and the connection can be reused.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, constants.MAX_FETCH_BODY))
+	if err != nil {
+		return fail(err, fetchErrorReason(err))
+	}
+	bodyBytes := int64(len(body))
+	timer.markBodyDone()
+	timer.recordSpans(ctx)
+	timings := timer.timings()
+	elapsed := timings.Total
+
+	outcome := types.FetchStatusOK
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		outcome = types.FetchStatusNotModified
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		outcome = types.FetchStatusHTTPError
+	}
+	metrics.FetchDuration.Observe(elapsed, outcome)
+	metrics.FetchOutcomes.Inc(outcome, statusClass(resp.StatusCode))
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	span.SetAttribute("http.response.body.size", bodyBytes)
+	span.SetAttribute("fetch.outcome", outcome)
+
+	// Hash only full bodies; a cut-off one would not match its own hash.
+	var contentHash, fuzzyHash string
+	if outcome == types.FetchStatusOK && bodyBytes < constants.MAX_FETCH_BODY {
+		sum := sha256.Sum256(body)
+		contentHash, fuzzyHash = hex.EncodeToString(sum[:]), fuzzyhash.Hash(body)
+	}
+
+	if data, exists := URLStore.Load(url); exists {
+		urlData := data.(*types.URLData)
+
+		Mutex.Lock()
+		urlData.FetchTime = elapsed
+		urlData.Timings = append(urlData.Timings, timings)
+		if excess := len(urlData.Timings) - constants.TIMING_SAMPLES; excess > 0 {
+			urlData.Timings = append(urlData.Timings[:0:0], urlData.Timings[excess:]...)
+		}
+		urlData.LastFetched = time.Now().Format(time.RFC3339)
+		urlData.LastFetchStatus = outcome
+
+		switch outcome {
+		case types.FetchStatusHTTPError:
+			// An error page is not the resource: the validators, length
+			// and hashes of the last page served stay for the next fetch.
+			urlData.FailureCount++
+		case types.FetchStatusNotModified:
+			// The body we would have downloaded is the one we already saw.
+			urlData.SuccessCount++
+			urlData.NotModifiedCount++
+			urlData.BytesSaved += urlData.ContentLength
+		default:
+			urlData.SuccessCount++
+			urlData.ETag = resp.Header.Get("ETag")
+			urlData.LastModified = resp.Header.Get("Last-Modified")
+			urlData.ContentHash, urlData.FuzzyHash = contentHash, fuzzyHash
+			switch {
+			case bodyBytes < constants.MAX_FETCH_BODY:
+				urlData.ContentLength = bodyBytes
+			case resp.ContentLength >= 0:
+				urlData.ContentLength = resp.ContentLength
+			}
+		}
+		successCount, failureCount := urlData.SuccessCount, urlData.FailureCount
+		Mutex.Unlock()
+
+		log.Info("fetched URL",
+			"status", resp.StatusCode,
+			"fetch_time", elapsed,
+			"ttfb", timings.TTFB,
+			"body_bytes", bodyBytes,
+			"success_count", successCount,
+			"failure_count", failureCount,
+		)
+		if contentHash != "" {
+			notifyBody(ctx, url, body, resp.Header.Get("Content-Type"))
+		}
+		NotifyUpdate(ctx, url)
+	}
+
+	if outcome == types.FetchStatusHTTPError {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+	return nil
+}
+
+func recordFetchFailure(url string) {
+	if data, exists := URLStore.Load(url); exists {
+		Mutex.Lock()
+		data.(*types.URLData).FailureCount++
+		data.(*types.URLData).LastFetchStatus = types.FetchStatusFailed
+		Mutex.Unlock()
+	}
+}
+
+func CollectStats() types.Stats {
+	var stats types.Stats
+	var samples []types.FetchTimings
+
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	URLStore.Range(func(_, value interface{}) bool {
+		data := value.(*types.URLData)
+		stats.URLs++
+		stats.Submissions += data.Count
+		stats.SuccessCount += data.SuccessCount
+		stats.FailureCount += data.FailureCount
+		stats.NotModified += data.NotModifiedCount
+		stats.BytesSaved += data.BytesSaved
+		samples = append(samples, data.Timings...)
+		return true
+	})
+	stats.Timings = summarize(samples)
+
+	return stats
+}
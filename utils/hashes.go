@@ -0,0 +1,37 @@
+package utils
+
+import "github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+
+// ContentHashes returns the SHA-256 and fuzzy hash of the last full body
+// fetched from url, either empty when there is none, and reports whether
+// url is stored.
+func (s *Store) ContentHashes(url string) (string, string, bool) {
+	value, exists := s.entries.Load(url)
+	if !exists {
+		return "", "", false
+	}
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	data := value.(*types.URLData)
+	return data.ContentHash, data.FuzzyHash, true
+}
+
+// SetHashMatch records the known-bad hash the body of url matched, or
+// clears it when match is nil. It reports whether url now matches a
+// different hash than before; a match on the same hash keeps the time it
+// was first seen.
+func (s *Store) SetHashMatch(url string, match *types.HashMatch) bool {
+	value, exists := s.entries.Load(url)
+	if !exists {
+		return false
+	}
+	data := value.(*types.URLData)
+	Mutex.Lock()
+	defer Mutex.Unlock()
+	previous := data.HashMatch
+	if previous != nil && match != nil && previous.Hash == match.Hash {
+		return false
+	}
+	data.HashMatch = match
+	return match != nil
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	hooksMutex  sync.RWMutex
+	updateHooks []func(ctx context.Context, url string)
+	bodyHooks   []func(ctx context.Context, url string, body []byte, contentType string)
+)
+
+// OnUpdate registers fn to be called after a submission or fetch updates
+// the record of a URL. Hooks run on the caller's goroutine, so slow ones
+// should hand the work off.
+func OnUpdate(fn func(ctx context.Context, url string)) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	updateHooks = append(updateHooks, fn)
+}
+
+// NotifyUpdate calls the OnUpdate hooks for url. It must be called without
+// the store locks held.
+func NotifyUpdate(ctx context.Context, url string) {
+	hooksMutex.RLock()
+	hooks := updateHooks
+	hooksMutex.RUnlock()
+	for _, fn := range hooks {
+		fn(ctx, url)
+	}
+}
+
+// OnBody registers fn to be called with every full body a fetch downloads
+// with a 2xx status, before the OnUpdate hooks. Bodies cut off at
+// MAX_FETCH_BODY are not passed. fn runs on the fetching goroutine and must not modify body.
+func OnBody(fn func(ctx context.Context, url string, body []byte, contentType string)) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	bodyHooks = append(bodyHooks, fn)
+}
+
+func notifyBody(ctx context.Context, url string, body []byte, contentType string) {
+	hooksMutex.RLock()
+	hooks := bodyHooks
+	hooksMutex.RUnlock()
+	for _, fn := range hooks {
+		fn(ctx, url, body, contentType)
+	}
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/tracing"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+// phaseTimer records when each phase of one HTTP request started and ended,
+// from the first DNS lookup to the last byte of the body. Phases a reused
+// connection skips stay zero.
+type phaseTimer struct {
+	mutex        sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	bodyDone     time.Time
+	reused       bool
+}
+
+// withPhaseTimer returns a copy of ctx that times the request made with it.
+func withPhaseTimer(ctx context.Context) (context.Context, *phaseTimer) {
+	t := &phaseTimer{start: time.Now()}
+	mark := func(field *time.Time) {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		if field.IsZero() {
+			*field = time.Now()
+		}
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { mark(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { mark(&t.dnsDone) },
+		ConnectStart:      func(string, string) { mark(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { mark(&t.connectDone) },
+		TLSHandshakeStart: func() { mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { mark(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mutex.Lock()
+			t.reused = info.Reused
+			t.mutex.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() { mark(&t.firstByte) },
+	}
+	return httptrace.WithClientTrace(ctx, trace), t
+}
+
+// markBodyDone records that the response body has been read.
+func (t *phaseTimer) markBodyDone() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.bodyDone = time.Now()
+}
+
+// timings converts the recorded phases to durations.
+func (t *phaseTimer) timings() types.FetchTimings {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	between := func(start, end time.Time) float64 {
+		if start.IsZero() || end.IsZero() {
+			return 0
+		}
+		return end.Sub(start).Seconds()
+	}
+	end := t.bodyDone
+	if end.IsZero() {
+		end = t.firstByte
+	}
+	return types.FetchTimings{
+		At:      t.start,
+		DNS:     between(t.dnsStart, t.dnsDone),
+		Connect: between(t.connectStart, t.connectDone),
+		TLS:     between(t.tlsStart, t.tlsDone),
+		TTFB:    between(t.wroteRequest, t.firstByte),
+		Body:    between(t.firstByte, t.bodyDone),
+		Total:   between(t.start, end),
+		Reused:  t.reused,
+	}
+}
+
+// recordSpans adds one child span per completed phase to the span in ctx.
+func (t *phaseTimer) recordSpans(ctx context.Context) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	phases := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"dns", t.dnsStart, t.dnsDone},
+		{"connect", t.connectStart, t.connectDone},
+		{"tls", t.tlsStart, t.tlsDone},
+		{"ttfb", t.wroteRequest, t.firstByte},
+		{"body", t.firstByte, t.bodyDone},
+	}
+	for _, phase := range phases {
+		if phase.start.IsZero() || phase.end.IsZero() {
+			continue
+		}
+		tracing.Record(ctx, "fetch."+phase.name, phase.start, phase.end, nil)
+	}
+	tracing.SpanFromContext(ctx).SetAttribute("net.connection.reused", t.reused)
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+const (
+	SortCount        = "count"
+	SortCreatedAt    = "created_at"
+	SortLastFetched  = "last_fetched"
+	SortFailureCount = "failure_count"
+	SortFetchTime    = "fetch_time"
+)
+
+// ListQuery selects one page of stored URLs.
+type ListQuery struct {
+	Limit      int
+	Cursor     string
+	Sort       string
+	Descending bool
+
+	Domain        string
+	Scheme        string
+	MinCount      int
+	Status        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	MetadataFilter
+}
+
+// sortKey is the position of a URL in a listing. Time and integer fields use
+// I, fetch time uses F and the URL breaks ties so every position is unique.
+type sortKey struct {
+	I   int64   `json:"i,omitempty"`
+	F   float64 `json:"f,omitempty"`
+	URL string  `json:"u"`
+}
+
+// cursor is the opaque token handed out as the next page marker. It carries
+// the sort it was issued for so it cannot be replayed against another order.
+type cursor struct {
+	Sort       string  `json:"s"`
+	Descending bool    `json:"d,omitempty"`
+	After      sortKey `json:"a"`
+}
+
+func (a sortKey) less(b sortKey) bool {
+	if a.I != b.I {
+		return a.I < b.I
+	}
+	if a.F != b.F {
+		return a.F < b.F
+	}
+	return a.URL < b.URL
+}
+
+func ValidSort(field string) bool {
+	switch field {
+	case SortCount, SortCreatedAt, SortLastFetched, SortFailureCount, SortFetchTime:
+		return true
+	}
+	return false
+}
+
+func keyFor(data *types.URLData, field string) sortKey {
+	key := sortKey{URL: data.URL}
+	switch field {
+	case SortCount:
+		key.I = int64(data.Count)
+	case SortCreatedAt:
+		key.I = data.CreatedAt.UnixNano()
+	case SortLastFetched:
+		if t, err := time.Parse(time.RFC3339, data.LastFetched); err == nil {
+			key.I = t.UnixNano()
+		}
+	case SortFailureCount:
+		key.I = int64(data.FailureCount)
+	case SortFetchTime:
+		key.F = data.FetchTime
+	}
+	return key
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// Matches reports whether data passes the query's filters. It must be
+// called with Mutex read-held.
+func (q ListQuery) Matches(data *types.URLData) bool {
+	if data.Count < q.MinCount {
+		return false
+	}
+	if !q.matchesMetadata(data) {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && data.CreatedAt.Before(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !data.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	if q.Status != "" {
+		status := data.LastFetchStatus
+		if status == "" {
+			status = types.FetchStatusNever
+		}
+		if status != q.Status {
+			return false
+		}
+	}
+	if q.Domain != "" || q.Scheme != "" {
+		parsed, err := url.Parse(data.URL)
+		if err != nil {
+			return false
+		}
+		if q.Scheme != "" && !strings.EqualFold(parsed.Scheme, q.Scheme) {
+			return false
+		}
+		if q.Domain != "" && !MatchesDomain(parsed.Hostname(), q.Domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesDomain reports whether host is domain or one of its subdomains.
+func MatchesDomain(host, domain string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// ListURLs returns one page of stored URLs and the cursor for the next page,
+// which is empty on the last page.
+func ListURLs(q ListQuery) ([]*types.URLData, string, error) {
+	return URLStore.List(q)
+}
+
+// List pages through the store. Sorts on count and creation time walk the
+// matching index from the cursor; other sorts scan and sort the store.
+func (s *Store) List(q ListQuery) ([]*types.URLData, string, error) {
+	var after *sortKey
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if c.Sort != q.Sort || c.Descending != q.Descending {
+			return nil, "", fmt.Errorf("cursor does not match sort order")
+		}
+		after = &c.After
+	}
+
+	var entries []indexEntry
+	if index := s.indexFor(q.Sort); index != nil {
+		entries = s.walkIndex(index, q, after)
+	} else {
+		entries = s.scan(q, after)
+	}
+
+	next := ""
+	if len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		next = encodeCursor(cursor{Sort: q.Sort, Descending: q.Descending, After: entries[len(entries)-1].key})
+	}
+
+	urls := make([]*types.URLData, len(entries))
+	for i, e := range entries {
+		urls[i] = e.data
+	}
+	return urls, next, nil
+}
+
+type indexEntry struct {
+	key  sortKey
+	data *types.URLData
+}
+
+// walkIndex collects up to Limit+1 matching entries so the caller can tell
+// whether another page follows.
+func (s *Store) walkIndex(index *skiplist, q ListQuery, after *sortKey) []indexEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+
+	var entries []indexEntry
+	collect := func(key sortKey, data *types.URLData) bool {
+		if q.Matches(data) {
+			entries = append(entries, indexEntry{key: key, data: data})
+		}
+		return len(entries) <= q.Limit
+	}
+	if q.Descending {
+		index.descend(after, collect)
+	} else {
+		index.ascend(after, collect)
+	}
+	return entries
+}
+
+func (s *Store) scan(q ListQuery, after *sortKey) []indexEntry {
+	var entries []indexEntry
+
+	Mutex.RLock()
+	s.Range(func(_, value interface{}) bool {
+		data := value.(*types.URLData)
+		if !q.Matches(data) {
+			return true
+		}
+		key := keyFor(data, q.Sort)
+		if after != nil {
+			if q.Descending && !key.less(*after) || !q.Descending && !after.less(key) {
+				return true
+			}
+		}
+		entries = append(entries, indexEntry{key: key, data: data})
+		return true
+	})
+	Mutex.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if q.Descending {
+			return entries[j].key.less(entries[i].key)
+		}
+		return entries[i].key.less(entries[j].key)
+	})
+	return entries
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func storeListFixtures() {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		u := fmt.Sprintf("https://a%d.list-test.example/page", i)
+		URLStore.Store(u, &types.URLData{URL: u, Count: i % 3, CreatedAt: base.Add(time.Duration(i) * time.Hour)})
+	}
+	URLStore.Store("http://b.list-test.example/", &types.URLData{
+		URL: "http://b.list-test.example/", Count: 10, CreatedAt: base, LastFetchStatus: types.FetchStatusFailed,
+	})
+}
+
+func TestListURLsPaginatesWithoutGapsOrDuplicates(t *testing.T) {
+	storeListFixtures()
+
+	query := ListQuery{Limit: 3, Sort: SortCount, Descending: true, Domain: "list-test.example"}
+	var seen []string
+	for {
+		page, next, err := ListURLs(query)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page), 3)
+		for _, data := range page {
+			seen = append(seen, data.URL)
+		}
+		if next == "" {
+			break
+		}
+		query.Cursor = next
+	}
+
+	assert.Len(t, seen, 8)
+	assert.Equal(t, "http://b.list-test.example/", seen[0])
+	unique := map[string]bool{}
+	for _, u := range seen {
+		unique[u] = true
+	}
+	assert.Len(t, unique, 8)
+}
+
+func TestListURLsFilters(t *testing.T) {
+	storeListFixtures()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		want  int
+	}{
+		{"scheme", ListQuery{Domain: "list-test.example", Scheme: "http"}, 1},
+		{"subdomain", ListQuery{Domain: "a3.list-test.example"}, 1},
+		{"min count", ListQuery{Domain: "list-test.example", MinCount: 2}, 3},
+		{"failed", ListQuery{Domain: "list-test.example", Status: types.FetchStatusFailed}, 1},
+		{"never fetched", ListQuery{Domain: "list-test.example", Status: types.FetchStatusNever}, 7},
+		{"created range", ListQuery{Domain: "list-test.example", CreatedAfter: base.Add(2 * time.Hour), CreatedBefore: base.Add(4 * time.Hour)}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Limit = 50
+			tt.query.Sort = SortCreatedAt
+			page, next, err := ListURLs(tt.query)
+			assert.NoError(t, err)
+			assert.Empty(t, next)
+			assert.Len(t, page, tt.want)
+		})
+	}
+}
+
+func TestListURLsRejectsCursorFromAnotherSort(t *testing.T) {
+	storeListFixtures()
+
+	_, next, err := ListURLs(ListQuery{Limit: 1, Sort: SortCount, Domain: "list-test.example"})
+	assert.NoError(t, err)
+
+	_, _, err = ListURLs(ListQuery{Limit: 1, Sort: SortCreatedAt, Cursor: next})
+	assert.Error(t, err)
+
+	_, _, err = ListURLs(ListQuery{Limit: 1, Sort: SortCount, Cursor: "not-a-cursor"})
+	assert.Error(t, err)
+}
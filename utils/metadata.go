@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+var (
+	sourcePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+	tagPattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9._:-]{0,63}$`)
+)
+
+const maxReferenceIDLength = 256
+
+// NormalizeSubmission checks the metadata of a submission, lower-casing and
+// de-duplicating its tags.
+func NormalizeSubmission(payload *types.RequestUrlPayload) error {
+	if payload.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if payload.Source != "" && !sourcePattern.MatchString(payload.Source) {
+		return fmt.Errorf("source must be 1-64 letters, digits, '.', '_' or '-'")
+	}
+	if payload.Source == "" && (payload.Confidence != nil || payload.ReferenceID != "") {
+		return fmt.Errorf("confidence and reference_id need a source")
+	}
+	if payload.Confidence != nil && (*payload.Confidence < 0 || *payload.Confidence > 100) {
+		return fmt.Errorf("confidence must be between 0 and 100")
+	}
+	if len(payload.ReferenceID) > maxReferenceIDLength {
+		return fmt.Errorf("reference_id longer than %d characters", maxReferenceIDLength)
+	}
+	if len(payload.Tags) > constants.MAX_SUBMISSION_TAGS {
+		return fmt.Errorf("at most %d tags per submission", constants.MAX_SUBMISSION_TAGS)
+	}
+
+	tags := make([]string, 0, len(payload.Tags))
+	for _, tag := range payload.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return fmt.Errorf("tag %q must be 1-64 lower-case letters, digits, '.', '_', ':' or '-'", tag)
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	payload.Tags = tags
+	return nil
+}
+
+// recordMetadata folds the metadata of one submission into data. It must
+// be called with Mutex held. Sources and tags beyond the per-URL caps are
+// dropped so one URL cannot grow without bound.
+func recordMetadata(data *types.URLData, payload types.RequestUrlPayload, now time.Time) {
+	if payload.Source != "" {
+		stats, exists := data.Sources[payload.Source]
+		if !exists && len(data.Sources) < constants.MAX_SOURCES_PER_URL {
+			if data.Sources == nil {
+				data.Sources = map[string]*types.SourceStats{}
+			}
+			stats = &types.SourceStats{FirstSeen: now}
+			data.Sources[payload.Source] = stats
+		}
+		if stats != nil {
+			stats.Submissions++
+			stats.LastSeen = now
+			if payload.Confidence != nil {
+				confidence := *payload.Confidence
+				stats.Confidence = &confidence
+			}
+			if payload.ReferenceID != "" {
+				stats.ReferenceID = payload.ReferenceID
+			}
+		}
+	}
+
+	for _, tag := range payload.Tags {
+		if _, exists := data.Tags[tag]; !exists && len(data.Tags) >= constants.MAX_TAGS_PER_URL {
+			continue
+		}
+		if data.Tags == nil {
+			data.Tags = map[string]int{}
+		}
+		data.Tags[tag]++
+	}
+}
+
+// AddTags attaches tags to url, counting each once, and returns the ones it
+// did not have before. Tags beyond MAX_TAGS_PER_URL are dropped.
+func (s *Store) AddTags(url string, tags []string) []string {
+	value, exists := s.entries.Load(url)
+	if !exists {
+		return nil
+	}
+	data := value.(*types.URLData)
+	Mutex.Lock()
+	defer Mutex.Unlock()
+	var added []string
+	for _, tag := range tags {
+		if _, exists := data.Tags[tag]; exists || len(data.Tags) >= constants.MAX_TAGS_PER_URL {
+			continue
+		}
+		if data.Tags == nil {
+			data.Tags = map[string]int{}
+		}
+		data.Tags[tag] = 1
+		added = append(added, tag)
+	}
+	return added
+}
+
+// Confidence is the highest latest confidence any source reported for
+// data, or -1 when none did. It must be called with Mutex read-held.
+func Confidence(data *types.URLData) int {
+	highest := -1
+	for _, stats := range data.Sources {
+		if stats.Confidence != nil && *stats.Confidence > highest {
+			highest = *stats.Confidence
+		}
+	}
+	return highest
+}
+
+// MetadataFilter selects URLs by what their submitters said about them.
+// Zero fields match everything.
+type MetadataFilter struct {
+	Source        string
+	Tag           string
+	MinConfidence int
+}
+
+func (f MetadataFilter) empty() bool {
+	return f == MetadataFilter{}
+}
+
+// matchesMetadata must be called with Mutex read-held.
+func (f MetadataFilter) matchesMetadata(data *types.URLData) bool {
+	if f.Source != "" {
+		if _, exists := data.Sources[f.Source]; !exists {
+			return false
+		}
+	}
+	if f.Tag != "" && data.Tags[strings.ToLower(f.Tag)] == 0 {
+		return false
+	}
+	return f.MinConfidence <= 0 || Confidence(data) >= f.MinConfidence
+}
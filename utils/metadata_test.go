@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func confidence(n int) *int { return &n }
+
+func TestNormalizeSubmission(t *testing.T) {
+	payload := types.RequestUrlPayload{URL: "http://a.com", Source: "feed-1", Tags: []string{" Phishing", "phishing", "bank"}}
+	assert.NoError(t, NormalizeSubmission(&payload))
+	assert.Equal(t, []string{"phishing", "bank"}, payload.Tags)
+
+	for _, bad := range []types.RequestUrlPayload{
+		{},
+		{URL: "http://a.com", Source: "has space"},
+		{URL: "http://a.com", Confidence: confidence(50)},
+		{URL: "http://a.com", ReferenceID: "ticket-1"},
+		{URL: "http://a.com", Source: "feed", Confidence: confidence(101)},
+		{URL: "http://a.com", Tags: []string{"no spaces allowed"}},
+		{URL: "http://a.com", Tags: make([]string, constants.MAX_SUBMISSION_TAGS+1)},
+	} {
+		assert.Error(t, NormalizeSubmission(&bad), "%+v", bad)
+	}
+}
+
+func TestSubmitAggregatesMetadata(t *testing.T) {
+	store := NewStore()
+	store.SubmitWith(types.RequestUrlPayload{URL: "http://a.com", Source: "feed-1", Tags: []string{"phishing"}, Confidence: confidence(40)})
+	store.SubmitWith(types.RequestUrlPayload{URL: "http://a.com", Source: "feed-1", Tags: []string{"phishing", "bank"}, ReferenceID: "r-2"})
+	data := store.SubmitWith(types.RequestUrlPayload{URL: "http://a.com", Source: "feed-2", Confidence: confidence(90)})
+	store.Submit("http://a.com")
+
+	assert.Equal(t, 4, data.Count)
+	assert.Len(t, data.Sources, 2)
+	feed1 := data.Sources["feed-1"]
+	assert.Equal(t, 2, feed1.Submissions)
+	assert.Equal(t, 40, *feed1.Confidence, "a submission without confidence keeps the last one")
+	assert.Equal(t, "r-2", feed1.ReferenceID)
+	assert.True(t, feed1.LastSeen.After(feed1.FirstSeen))
+	assert.Equal(t, map[string]int{"phishing": 2, "bank": 1}, data.Tags)
+	assert.Equal(t, 90, Confidence(data))
+
+	for i := 0; i < constants.MAX_SOURCES_PER_URL+5; i++ {
+		store.SubmitWith(types.RequestUrlPayload{URL: "http://b.com", Source: fmt.Sprint("feed-", i)})
+	}
+	value, _ := store.Load("http://b.com")
+	assert.Len(t, value.(*types.URLData).Sources, constants.MAX_SOURCES_PER_URL)
+}
+
+func TestMetadataFilters(t *testing.T) {
+	store := NewStore()
+	store.SubmitWith(types.RequestUrlPayload{URL: "http://a.com/1", Source: "feed-1", Tags: []string{"phishing"}, Confidence: confidence(80)})
+	store.SubmitWith(types.RequestUrlPayload{URL: "http://a.com/2", Source: "feed-2", Tags: []string{"malware"}, Confidence: confidence(30)})
+	store.Submit("http://a.com/3")
+
+	list := func(filter MetadataFilter) []string {
+		urls, _, err := store.List(ListQuery{Limit: 10, Sort: SortCount, MetadataFilter: filter})
+		assert.NoError(t, err)
+		var found []string
+		for _, data := range urls {
+			found = append(found, data.URL)
+		}
+		return found
+	}
+	assert.Len(t, list(MetadataFilter{}), 3)
+	assert.Equal(t, []string{"http://a.com/2"}, list(MetadataFilter{Source: "feed-2"}))
+	assert.Equal(t, []string{"http://a.com/1"}, list(MetadataFilter{Tag: "Phishing"}))
+	assert.Equal(t, []string{"http://a.com/1"}, list(MetadataFilter{MinConfidence: 50}))
+	assert.Empty(t, list(MetadataFilter{Source: "feed-1", Tag: "malware"}))
+
+	results, _, err := store.Search(SearchQuery{Pattern: "a.com", Mode: MatchSubstring, Limit: 10,
+		MetadataFilter: MetadataFilter{Tag: "malware"}})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "http://a.com/2", results[0].URL)
+
+	results, _, err = store.Search(SearchQuery{Pattern: "http://a.com/", Mode: MatchPrefix, Limit: 10,
+		MetadataFilter: MetadataFilter{Source: "feed-1"}})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+const (
+	MatchExact     = "exact"
+	MatchPrefix    = "prefix"
+	MatchSubstring = "substring"
+	MatchGlob      = "glob"
+	MatchRegex     = "regex"
+
+	maxPatternLength = 512
+	maxRegexInsts    = 5000
+)
+
+// SearchQuery matches stored URLs by a pattern on the whole URL and by
+// structured conditions on its parts. Empty fields match everything.
+type SearchQuery struct {
+	Pattern string
+	Mode    string
+
+	// Host is matched case-insensitively; a leading "*." also matches
+	// subdomains. Path is a glob on the URL path.
+	Host   string
+	Path   string
+	Params map[string]string
+
+	MetadataFilter
+
+	Limit  int
+	Cursor string
+}
+
+// ValidMatchMode reports whether mode is a supported search mode.
+func ValidMatchMode(mode string) bool {
+	switch mode {
+	case MatchExact, MatchPrefix, MatchSubstring, MatchGlob, MatchRegex:
+		return true
+	}
+	return false
+}
+
+func trigramsOf(s string) []string {
+	if len(s) < 3 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(s))
+	trigrams := make([]string, 0, len(s)-2)
+	for i := 0; i+3 <= len(s); i++ {
+		trigram := s[i : i+3]
+		if _, dup := seen[trigram]; !dup {
+			seen[trigram] = struct{}{}
+			trigrams = append(trigrams, trigram)
+		}
+	}
+	return trigrams
+}
+
+func hostOf(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// compileRegex compiles an RE2 pattern, refusing ones long or complex enough
+// to make matching across the store expensive.
+func compileRegex(pattern string) (*regexp.Regexp, *syntax.Regexp, error) {
+	if len(pattern) > maxPatternLength {
+		return nil, nil, fmt.Errorf("pattern longer than %d characters", maxPatternLength)
+	}
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	if len(prog.Inst) > maxRegexInsts {
+		return nil, nil, fmt.Errorf("regex too complex")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	return re, parsed, nil
+}
+
+// globToRegex translates a glob where * matches any run of characters and ?
+// matches one character into an anchored regular expression.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// requiredLiteral returns the longest case-sensitive literal every match of
+// re must contain, used to narrow candidates through the trigram index.
+func requiredLiteral(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase == 0 {
+			return string(re.Rune)
+		}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpConcat:
+		longest := ""
+		for _, sub := range re.Sub {
+			if literal := requiredLiteral(sub); len(literal) > len(longest) {
+				longest = literal
+			}
+		}
+		return longest
+	}
+	return ""
+}
+
+// Search returns one page of URLs matching q in URL order, and the cursor for
+// the next page.
+func Search(q SearchQuery) ([]*types.URLData, string, error) {
+	return URLStore.Search(q)
+}
+
+func (s *Store) Search(q SearchQuery) ([]*types.URLData, string, error) {
+	var after string
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil || c.Sort != "search" {
+			return nil, "", fmt.Errorf("invalid cursor")
+		}
+		after = c.After.URL
+	}
+
+	match, literal, err := patternMatcher(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mutex.Lock()
+	var results []*types.URLData
+	collect := func(u string, data *types.URLData) bool {
+		if u > after && match(u) && q.matchesParts(u) && q.matchesData(data) {
+			results = append(results, data)
+		}
+		return true
+	}
+
+	switch candidates := s.candidates(q, literal); {
+	case q.Mode == MatchPrefix && candidates == nil:
+		start := sortKey{URL: max(after, q.Pattern)}
+		s.byURL.ascend(&start, func(key sortKey, data *types.URLData) bool {
+			if !strings.HasPrefix(key.URL, q.Pattern) {
+				return false
+			}
+			collect(key.URL, data)
+			return len(results) <= q.Limit
+		})
+		if value, exists := s.entries.Load(q.Pattern); exists && q.Pattern > after && q.matchesParts(q.Pattern) &&
+			q.matchesData(value.(*types.URLData)) {
+			results = append([]*types.URLData{value.(*types.URLData)}, results...)
+		}
+	case candidates != nil:
+		for u := range candidates {
+			if value, exists := s.entries.Load(u); exists {
+				collect(u, value.(*types.URLData))
+			}
+		}
+	default:
+		start := sortKey{URL: after}
+		s.byURL.ascend(&start, func(key sortKey, data *types.URLData) bool {
+			collect(key.URL, data)
+			return len(results) <= q.Limit
+		})
+	}
+	s.mutex.Unlock()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].URL < results[j].URL })
+
+	next := ""
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+		next = encodeCursor(cursor{Sort: "search", After: sortKey{URL: results[len(results)-1].URL}})
+	}
+	return results, next, nil
+}
+
+// matchesData applies the metadata filters, which need the record rather
+// than the URL.
+func (q SearchQuery) matchesData(data *types.URLData) bool {
+	if q.MetadataFilter.empty() {
+		return true
+	}
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	return q.matchesMetadata(data)
+}
+
+// patternMatcher returns the predicate for the pattern part of q and a
+// literal that every matching URL contains, if one is known.
+func patternMatcher(q SearchQuery) (func(string) bool, string, error) {
+	if q.Pattern == "" {
+		return func(string) bool { return true }, "", nil
+	}
+
+	switch q.Mode {
+	case MatchExact:
+		return func(u string) bool { return u == q.Pattern }, q.Pattern, nil
+	case MatchPrefix:
+		return func(u string) bool { return strings.HasPrefix(u, q.Pattern) }, "", nil
+	case MatchSubstring, "":
+		return func(u string) bool { return strings.Contains(u, q.Pattern) }, q.Pattern, nil
+	case MatchGlob:
+		if len(q.Pattern) > maxPatternLength {
+			return nil, "", fmt.Errorf("pattern longer than %d characters", maxPatternLength)
+		}
+		re, parsed, err := compileRegex(globToRegex(q.Pattern))
+		if err != nil {
+			return nil, "", err
+		}
+		return re.MatchString, requiredLiteral(parsed), nil
+	case MatchRegex:
+		re, parsed, err := compileRegex(q.Pattern)
+		if err != nil {
+			return nil, "", err
+		}
+		return re.MatchString, requiredLiteral(parsed), nil
+	}
+	return nil, "", fmt.Errorf("unknown match mode %q", q.Mode)
+}
+
+// candidates narrows the URLs worth checking using the host and trigram
+// indexes. A nil result means no index applies and the caller must walk the
+// store. It must be called with the mutex held.
+func (s *Store) candidates(q SearchQuery, literal string) map[string]struct{} {
+	var sets []map[string]struct{}
+
+	if q.Host != "" && !strings.HasPrefix(q.Host, "*.") {
+		sets = append(sets, s.hosts[strings.ToLower(q.Host)])
+	}
+	if q.Mode == MatchExact && q.Pattern != "" {
+		set := map[string]struct{}{}
+		if _, exists := s.entries.Load(q.Pattern); exists {
+			set[q.Pattern] = struct{}{}
+		}
+		sets = append(sets, set)
+	} else if len(literal) >= 3 {
+		for _, trigram := range trigramsOf(literal) {
+			sets = append(sets, s.trigrams[trigram])
+		}
+	}
+
+	if len(sets) == 0 {
+		return nil
+	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	result := make(map[string]struct{}, len(sets[0]))
+	for u := range sets[0] {
+		result[u] = struct{}{}
+	}
+	for _, set := range sets[1:] {
+		for u := range result {
+			if _, exists := set[u]; !exists {
+				delete(result, u)
+			}
+		}
+	}
+	return result
+}
+
+// matchesParts checks the structured host, path and query parameter
+// conditions against u.
+func (q SearchQuery) matchesParts(u string) bool {
+	if q.Host == "" && q.Path == "" && len(q.Params) == 0 {
+		return true
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+
+	if q.Host != "" {
+		if domain, wildcard := strings.CutPrefix(q.Host, "*."); wildcard {
+			if !MatchesDomain(parsed.Hostname(), domain) {
+				return false
+			}
+		} else if !strings.EqualFold(parsed.Hostname(), q.Host) {
+			return false
+		}
+	}
+	if q.Path != "" {
+		if ok, _ := path.Match(q.Path, parsed.EscapedPath()); !ok {
+			return false
+		}
+	}
+	if len(q.Params) > 0 {
+		values := parsed.Query()
+		for name, want := range q.Params {
+			if !values.Has(name) {
+				return false
+			}
+			if want != "" && values.Get(name) != want {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSearchStore() *Store {
+	store := NewStore()
+	for _, u := range []string{
+		"http://evil.example.com/login?user=1",
+		"http://evil.example.com/login/reset",
+		"https://www.example.com/index.html",
+		"https://shop.example.org/cart?item=42&ref=mail",
+		"https://Example.net/Download.EXE",
+	} {
+		store.Store(u, &types.URLData{URL: u})
+	}
+	return store
+}
+
+func searchURLs(t *testing.T, store *Store, q SearchQuery) []string {
+	if q.Limit == 0 {
+		q.Limit = 50
+	}
+	results, _, err := store.Search(q)
+	assert.NoError(t, err)
+	urls := []string{}
+	for _, data := range results {
+		urls = append(urls, data.URL)
+	}
+	return urls
+}
+
+func TestSearchModes(t *testing.T) {
+	store := newSearchStore()
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []string
+	}{
+		{"exact", SearchQuery{Mode: MatchExact, Pattern: "https://www.example.com/index.html"}, []string{"https://www.example.com/index.html"}},
+		{"prefix", SearchQuery{Mode: MatchPrefix, Pattern: "http://evil.example.com/login"}, []string{"http://evil.example.com/login/reset", "http://evil.example.com/login?user=1"}},
+		{"substring", SearchQuery{Mode: MatchSubstring, Pattern: "example.org"}, []string{"https://shop.example.org/cart?item=42&ref=mail"}},
+		{"short substring", SearchQuery{Mode: MatchSubstring, Pattern: "42"}, []string{"https://shop.example.org/cart?item=42&ref=mail"}},
+		{"glob", SearchQuery{Mode: MatchGlob, Pattern: "https://*.example.???/*"}, []string{"https://shop.example.org/cart?item=42&ref=mail", "https://www.example.com/index.html"}},
+		{"regex", SearchQuery{Mode: MatchRegex, Pattern: `(?i)\.exe$`}, []string{"https://Example.net/Download.EXE"}},
+		{"regex with literal", SearchQuery{Mode: MatchRegex, Pattern: `login/(reset|forgot)`}, []string{"http://evil.example.com/login/reset"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchURLs(t, store, tt.query))
+		})
+	}
+}
+
+func TestSearchStructured(t *testing.T) {
+	store := newSearchStore()
+
+	assert.Equal(t, []string{"http://evil.example.com/login/reset", "http://evil.example.com/login?user=1"},
+		searchURLs(t, store, SearchQuery{Host: "EVIL.example.com"}))
+	assert.Equal(t, []string{"http://evil.example.com/login/reset", "http://evil.example.com/login?user=1", "https://www.example.com/index.html"},
+		searchURLs(t, store, SearchQuery{Host: "*.example.com"}))
+	assert.Equal(t, []string{"http://evil.example.com/login/reset"},
+		searchURLs(t, store, SearchQuery{Path: "/login/*"}))
+	assert.Equal(t, []string{"https://shop.example.org/cart?item=42&ref=mail"},
+		searchURLs(t, store, SearchQuery{Params: map[string]string{"item": "42", "ref": ""}}))
+	assert.Empty(t, searchURLs(t, store, SearchQuery{Params: map[string]string{"item": "7"}}))
+}
+
+func TestSearchPaginates(t *testing.T) {
+	store := newSearchStore()
+
+	query := SearchQuery{Mode: MatchSubstring, Pattern: "example", Limit: 2}
+	var all []string
+	for {
+		page, next, err := store.Search(query)
+		assert.NoError(t, err)
+		for _, data := range page {
+			all = append(all, data.URL)
+		}
+		if next == "" {
+			break
+		}
+		query.Cursor = next
+	}
+	assert.Len(t, all, 4)
+}
+
+func TestSearchRejectsComplexRegex(t *testing.T) {
+	store := newSearchStore()
+
+	_, _, err := store.Search(SearchQuery{Mode: MatchRegex, Pattern: `(a{1,1000}){1,1000}`, Limit: 10})
+	assert.Error(t, err)
+	_, _, err = store.Search(SearchQuery{Mode: MatchRegex, Pattern: `(unclosed`, Limit: 10})
+	assert.Error(t, err)
+}
+
+func TestSearchIndexFollowsDeletes(t *testing.T) {
+	store := newSearchStore()
+	store.Delete("https://shop.example.org/cart?item=42&ref=mail")
+
+	assert.Empty(t, searchURLs(t, store, SearchQuery{Mode: MatchSubstring, Pattern: "example.org"}))
+	assert.Empty(t, searchURLs(t, store, SearchQuery{Host: "shop.example.org"}))
+}
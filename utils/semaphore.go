@@ -0,0 +1,53 @@
+package utils
+
+import "sync"
+
+// semaphore bounds concurrent downloads. Unlike a buffered channel its
+// capacity can change while slots are held; lowering it lets current
+// holders finish and admits no one until usage drops below the new limit.
+type semaphore struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+	limit int
+	inUse int
+}
+
+func newSemaphore(limit int) *semaphore {
+	s := &semaphore{limit: limit}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
+}
+
+func (s *semaphore) acquire() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for s.inUse >= s.limit {
+		s.cond.Wait()
+	}
+	s.inUse++
+}
+
+func (s *semaphore) release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.inUse--
+	s.cond.Signal()
+}
+
+func (s *semaphore) setLimit(limit int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.limit = limit
+	s.cond.Broadcast()
+}
+
+func (s *semaphore) usage() (inUse, limit int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.inUse, s.limit
+}
+
+// SetMaxDownloads changes how many downloads may run at once.
+func SetMaxDownloads(n int) {
+	downloads.setLimit(max(n, 1))
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaphoreSetLimit(t *testing.T) {
+	s := newSemaphore(1)
+	s.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		s.acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired beyond the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	s.setLimit(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("raising the limit did not admit the waiter")
+	}
+
+	s.setLimit(1)
+	inUse, limit := s.usage()
+	assert.Equal(t, 2, inUse)
+	assert.Equal(t, 1, limit)
+	s.release()
+	s.release()
+	inUse, _ = s.usage()
+	assert.Equal(t, 0, inUse)
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"math/rand"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+const maxLevel = 32
+
+// skiplist is an ordered index of URLs by sortKey. Level 0 is doubly linked
+// so it can be walked in both directions.
+type skiplist struct {
+	head   *slNode
+	tail   *slNode
+	level  int
+	length int
+}
+
+type slNode struct {
+	key  sortKey
+	data *types.URLData
+	next []*slNode
+	prev *slNode
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		head:  &slNode{next: make([]*slNode, maxLevel)},
+		level: 1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < maxLevel && rand.Intn(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// predecessors returns, for each level, the last node whose key is less
+// than key.
+func (s *skiplist) predecessors(key sortKey) [maxLevel]*slNode {
+	var update [maxLevel]*slNode
+	node := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].key.less(key) {
+			node = node.next[i]
+		}
+		update[i] = node
+	}
+	return update
+}
+
+func (s *skiplist) insert(key sortKey, data *types.URLData) {
+	update := s.predecessors(key)
+	if next := update[0].next[0]; next != nil && next.key == key {
+		next.data = data
+		return
+	}
+
+	level := randomLevel()
+	if level > s.level {
+		for i := s.level; i < level; i++ {
+			update[i] = s.head
+		}
+		s.level = level
+	}
+
+	node := &slNode{key: key, data: data, next: make([]*slNode, level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+	}
+	if update[0] != s.head {
+		node.prev = update[0]
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node
+	} else {
+		s.tail = node
+	}
+	s.length++
+}
+
+func (s *skiplist) delete(key sortKey) bool {
+	update := s.predecessors(key)
+	node := update[0].next[0]
+	if node == nil || node.key != key {
+		return false
+	}
+
+	for i := 0; i < len(node.next); i++ {
+		update[i].next[i] = node.next[i]
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node.prev
+	} else {
+		s.tail = node.prev
+	}
+	for s.level > 1 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+	s.length--
+	return true
+}
+
+// ascend calls fn for every entry after the given key (or from the start
+// when after is nil) in ascending order until fn returns false.
+func (s *skiplist) ascend(after *sortKey, fn func(sortKey, *types.URLData) bool) {
+	node := s.head.next[0]
+	if after != nil {
+		node = s.predecessors(*after)[0].next[0]
+		if node != nil && node.key == *after {
+			node = node.next[0]
+		}
+	}
+	for ; node != nil; node = node.next[0] {
+		if !fn(node.key, node.data) {
+			return
+		}
+	}
+}
+
+// descend calls fn for every entry before the given key (or from the end
+// when after is nil) in descending order until fn returns false.
+func (s *skiplist) descend(after *sortKey, fn func(sortKey, *types.URLData) bool) {
+	node := s.tail
+	if after != nil {
+		node = s.predecessors(*after)[0]
+		if node == s.head {
+			node = nil
+		}
+	}
+	for ; node != nil; node = node.prev {
+		if !fn(node.key, node.data) {
+			return
+		}
+	}
+}
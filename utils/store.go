@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+// Store holds every submitted URL keyed by the raw URL. Alongside the map it
+// maintains ordered indexes by submission count and by creation time so
+// top-N and latest-N listings do not have to scan and sort the whole store,
+// and text indexes (URL order, trigrams, host) that back search.
+//
+// Count and CreatedAt must only change through Store, Submit or Reindex,
+// otherwise the indexes go stale.
+type Store struct {
+	entries sync.Map
+
+	mutex     sync.Mutex
+	keys      map[string]indexKeys
+	byCount   *skiplist
+	byCreated *skiplist
+	byURL     *skiplist
+	trigrams  map[string]map[string]struct{}
+	hosts     map[string]map[string]struct{}
+}
+
+type indexKeys struct {
+	count   sortKey
+	created sortKey
+}
+
+func NewStore() *Store {
+	return &Store{
+		keys:      make(map[string]indexKeys),
+		byCount:   newSkiplist(),
+		byCreated: newSkiplist(),
+		byURL:     newSkiplist(),
+		trigrams:  make(map[string]map[string]struct{}),
+		hosts:     make(map[string]map[string]struct{}),
+	}
+}
+
+func (s *Store) Load(key interface{}) (interface{}, bool) {
+	return s.entries.Load(key)
+}
+
+func (s *Store) Range(f func(key, value interface{}) bool) {
+	s.entries.Range(f)
+}
+
+func (s *Store) Store(key, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries.Store(key, value)
+	s.unindex(key.(string))
+	s.index(key.(string), value.(*types.URLData))
+}
+
+func (s *Store) Delete(key interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries.Delete(key)
+	s.unindex(key.(string))
+}
+
+func (s *Store) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.keys)
+}
+
+// Submit records one submission of url, creating the entry on first sight,
+// and returns the entry.
+func (s *Store) Submit(url string) *types.URLData {
+	return s.SubmitWith(types.RequestUrlPayload{URL: url})
+}
+
+// SubmitWith is Submit for a payload carrying metadata, which is
+// aggregated into the URL's sources and tags.
+func (s *Store) SubmitWith(payload types.RequestUrlPayload) *types.URLData {
+	url := payload.URL
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if value, exists := s.entries.Load(url); exists {
+		data := value.(*types.URLData)
+		now := time.Now()
+		Mutex.Lock()
+		data.Count++
+		data.LastSubmitted = now
+		RecordSubmission(&data.History, now)
+		recordMetadata(data, payload, now)
+		Mutex.Unlock()
+		s.index(url, data)
+		return data
+	}
+
+	now := time.Now()
+	data := &types.URLData{URL: url, Count: 1, CreatedAt: now, LastSubmitted: now}
+	RecordSubmission(&data.History, now)
+	recordMetadata(data, payload, now)
+	s.entries.Store(url, data)
+	s.index(url, data)
+	return data
+}
+
+// Get returns a copy of the record of url, as Copy makes them, and
+// reports whether url is stored.
+func (s *Store) Get(url string) (types.URLData, bool) {
+	value, exists := s.entries.Load(url)
+	if !exists {
+		return types.URLData{}, false
+	}
+	return Copy(value.(*types.URLData)), true
+}
+
+// Copy returns a copy of data that is safe to read and encode without
+// Mutex: the maps, slices and history it shares with the stored record,
+// which submissions and fetches change in place, are copied too.
+func Copy(data *types.URLData) types.URLData {
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	return copyData(data)
+}
+
+// Copies is Copy for a page of records.
+func Copies(records []*types.URLData) []types.URLData {
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	copies := make([]types.URLData, 0, len(records))
+	for _, data := range records {
+		copies = append(copies, copyData(data))
+	}
+	return copies
+}
+
+// copyData must be called with Mutex held.
+func copyData(data *types.URLData) types.URLData {
+	copied := *data
+	if data.History != nil {
+		history := *data.History
+		for _, counter := range []*types.BucketCounter{&history.Minutes, &history.Hours, &history.Days} {
+			counter.Buckets = append([]int(nil), counter.Buckets...)
+		}
+		copied.History = &history
+	}
+	copied.Timings = append([]types.FetchTimings(nil), data.Timings...)
+	if data.HashMatch != nil {
+		match := *data.HashMatch
+		copied.HashMatch = &match
+	}
+	if data.Sources != nil {
+		copied.Sources = make(map[string]*types.SourceStats, len(data.Sources))
+		for name, stats := range data.Sources {
+			statsCopy := *stats
+			copied.Sources[name] = &statsCopy
+		}
+	}
+	if data.Tags != nil {
+		copied.Tags = make(map[string]int, len(data.Tags))
+		for tag, count := range data.Tags {
+			copied.Tags[tag] = count
+		}
+	}
+	return copied
+}
+
+// Reindex refreshes the index position of url after its Count or CreatedAt
+// was changed in place.
+func (s *Store) Reindex(url string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if value, exists := s.entries.Load(url); exists {
+		s.index(url, value.(*types.URLData))
+	}
+}
+
+// TopByCount returns the n most submitted URLs, most submitted first.
+func (s *Store) TopByCount(n int) []*types.URLData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	urls := make([]*types.URLData, 0, min(n, s.byCount.length))
+	s.byCount.descend(nil, func(_ sortKey, data *types.URLData) bool {
+		urls = append(urls, data)
+		return len(urls) < n
+	})
+	return urls
+}
+
+// index must be called with the mutex held.
+func (s *Store) index(url string, data *types.URLData) {
+	if keys, exists := s.keys[url]; exists {
+		s.byCount.delete(keys.count)
+		s.byCreated.delete(keys.created)
+	} else {
+		s.indexText(url, data)
+	}
+
+	Mutex.RLock()
+	keys := indexKeys{
+		count:   sortKey{I: int64(data.Count), URL: url},
+		created: sortKey{I: data.CreatedAt.UnixNano(), URL: url},
+	}
+	Mutex.RUnlock()
+
+	s.keys[url] = keys
+	s.byCount.insert(keys.count, data)
+	s.byCreated.insert(keys.created, data)
+}
+
+// unindex must be called with the mutex held.
+func (s *Store) unindex(url string) {
+	if keys, exists := s.keys[url]; exists {
+		s.byCount.delete(keys.count)
+		s.byCreated.delete(keys.created)
+		s.unindexText(url)
+		delete(s.keys, url)
+	}
+}
+
+func (s *Store) indexText(url string, data *types.URLData) {
+	s.byURL.insert(sortKey{URL: url}, data)
+	for _, trigram := range trigramsOf(url) {
+		addToSet(s.trigrams, trigram, url)
+	}
+	if host := hostOf(url); host != "" {
+		addToSet(s.hosts, host, url)
+	}
+}
+
+func (s *Store) unindexText(url string) {
+	s.byURL.delete(sortKey{URL: url})
+	for _, trigram := range trigramsOf(url) {
+		removeFromSet(s.trigrams, trigram, url)
+	}
+	if host := hostOf(url); host != "" {
+		removeFromSet(s.hosts, host, url)
+	}
+}
+
+func addToSet(index map[string]map[string]struct{}, key, url string) {
+	set, exists := index[key]
+	if !exists {
+		set = make(map[string]struct{})
+		index[key] = set
+	}
+	set[url] = struct{}{}
+}
+
+func removeFromSet(index map[string]map[string]struct{}, key, url string) {
+	if set, exists := index[key]; exists {
+		delete(set, url)
+		if len(set) == 0 {
+			delete(index, key)
+		}
+	}
+}
+
+// indexFor returns the ordered index serving sort field, if there is one.
+func (s *Store) indexFor(field string) *skiplist {
+	switch field {
+	case SortCount:
+		return s.byCount
+	case SortCreatedAt:
+		return s.byCreated
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkiplistOrdering(t *testing.T) {
+	list := newSkiplist()
+	for _, i := range rand.Perm(200) {
+		list.insert(sortKey{I: int64(i), URL: fmt.Sprint(i)}, nil)
+	}
+	for i := 0; i < 200; i += 2 {
+		assert.True(t, list.delete(sortKey{I: int64(i), URL: fmt.Sprint(i)}))
+	}
+	assert.False(t, list.delete(sortKey{I: 0, URL: "0"}))
+	assert.Equal(t, 100, list.length)
+
+	var ascending []int64
+	list.ascend(&sortKey{I: 50, URL: "50"}, func(key sortKey, _ *types.URLData) bool {
+		ascending = append(ascending, key.I)
+		return len(ascending) < 3
+	})
+	assert.Equal(t, []int64{51, 53, 55}, ascending)
+
+	var descending []int64
+	list.descend(&sortKey{I: 50, URL: "50"}, func(key sortKey, _ *types.URLData) bool {
+		descending = append(descending, key.I)
+		return len(descending) < 3
+	})
+	assert.Equal(t, []int64{49, 47, 45}, descending)
+
+	var last []int64
+	list.descend(nil, func(key sortKey, _ *types.URLData) bool {
+		last = append(last, key.I)
+		return false
+	})
+	assert.Equal(t, []int64{199}, last)
+}
+
+func TestStoreSubmitKeepsCountIndexCurrent(t *testing.T) {
+	store := NewStore()
+	store.Submit("http://a.com")
+	store.Submit("http://b.com")
+	store.Submit("http://b.com")
+	store.Submit("http://c.com")
+	store.Submit("http://c.com")
+	store.Submit("http://c.com")
+
+	top := store.TopByCount(2)
+	assert.Equal(t, "http://c.com", top[0].URL)
+	assert.Equal(t, "http://b.com", top[1].URL)
+
+	store.Delete("http://c.com")
+	top = store.TopByCount(5)
+	assert.Len(t, top, 2)
+	assert.Equal(t, "http://b.com", top[0].URL)
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestStoreListUsesIndexAndScanConsistently(t *testing.T) {
+	store := newBenchmarkStore(500)
+
+	for _, field := range []string{SortCount, SortCreatedAt, SortFailureCount} {
+		for _, descending := range []bool{true, false} {
+			query := ListQuery{Limit: 7, Sort: field, Descending: descending}
+			var got []string
+			for {
+				page, next, err := store.List(query)
+				assert.NoError(t, err)
+				for _, data := range page {
+					got = append(got, data.URL)
+				}
+				if next == "" {
+					break
+				}
+				query.Cursor = next
+			}
+			assert.Len(t, got, 500, "sort %s descending=%v", field, descending)
+		}
+	}
+}
+
+func newBenchmarkStore(n int) *Store {
+	store := NewStore()
+	base := time.Now()
+	for i := 0; i < n; i++ {
+		u := fmt.Sprintf("http://host%d.example/%d", i%1000, i)
+		store.Store(u, &types.URLData{
+			URL:          u,
+			Count:        rand.Intn(10000),
+			FailureCount: rand.Intn(10),
+			CreatedAt:    base.Add(-time.Duration(rand.Intn(1e6)) * time.Second),
+		})
+	}
+	return store
+}
+
+// sortedTopN is how listings were served before the indexes: copy every
+// entry, sort, truncate.
+func sortedTopN(store *Store, n int) []*types.URLData {
+	var urls []*types.URLData
+	store.Range(func(_, value interface{}) bool {
+		urls = append(urls, value.(*types.URLData))
+		return true
+	})
+	sort.Slice(urls, func(i, j int) bool { return urls[i].Count > urls[j].Count })
+	if len(urls) > n {
+		urls = urls[:n]
+	}
+	return urls
+}
+
+func sortedLatestN(store *Store, n int) []*types.URLData {
+	var urls []*types.URLData
+	store.Range(func(_, value interface{}) bool {
+		urls = append(urls, value.(*types.URLData))
+		return true
+	})
+	sort.Slice(urls, func(i, j int) bool {
+		return urls[i].CreatedAt.Format(time.RFC3339) > urls[j].CreatedAt.Format(time.RFC3339)
+	})
+	if len(urls) > n {
+		urls = urls[:n]
+	}
+	return urls
+}
+
+func BenchmarkTopByCount(b *testing.B) {
+	for _, size := range []int{10_000, 100_000} {
+		store := newBenchmarkStore(size)
+
+		b.Run(fmt.Sprintf("index/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store.TopByCount(10)
+			}
+		})
+		b.Run(fmt.Sprintf("sort/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sortedTopN(store, 10)
+			}
+		})
+	}
+}
+
+func BenchmarkLatest(b *testing.B) {
+	for _, size := range []int{10_000, 100_000} {
+		store := newBenchmarkStore(size)
+		query := ListQuery{Limit: 50, Sort: SortCreatedAt, Descending: true}
+
+		b.Run(fmt.Sprintf("index/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store.List(query)
+			}
+		})
+		b.Run(fmt.Sprintf("sort/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sortedLatestN(store, 50)
+			}
+		})
+	}
+}
+
+func TestGetCopiesRecord(t *testing.T) {
+	store := NewStore()
+	const url = "http://copy.example/"
+	store.SubmitWith(types.RequestUrlPayload{URL: url, Source: "feed-a", Tags: []string{"phish"}})
+
+	copied, exists := store.Get(url)
+	assert.True(t, exists)
+
+	// Later submissions change the stored record, not the copy.
+	store.SubmitWith(types.RequestUrlPayload{URL: url, Source: "feed-b", Tags: []string{"phish"}})
+	assert.Equal(t, 1, copied.Count)
+	assert.Len(t, copied.Sources, 1)
+	assert.Equal(t, 1, copied.Tags["phish"])
+	submissions := 0
+	for _, n := range copied.History.Minutes.Buckets {
+		submissions += n
+	}
+	assert.Equal(t, 1, submissions)
+
+	_, exists = store.Get("http://never-submitted.example/")
+	assert.False(t, exists)
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+const (
+	TimingsByURL  = "url"
+	TimingsByHost = GroupByHost
+)
+
+// percentiles returns the nearest-rank p50, p90 and p99 of values, ignoring
+// zeros, which mark phases a fetch skipped.
+func percentiles(values []float64) types.Percentiles {
+	var sorted []float64
+	for _, v := range values {
+		if v > 0 {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 {
+		return types.Percentiles{}
+	}
+	sort.Float64s(sorted)
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return sorted[max(i, 0)]
+	}
+	return types.Percentiles{P50: rank(0.5), P90: rank(0.9), P99: rank(0.99)}
+}
+
+// summarize computes per-phase percentiles over samples.
+func summarize(samples []types.FetchTimings) types.TimingStats {
+	phases := make([][]float64, 6)
+	for _, t := range samples {
+		for i, v := range []float64{t.DNS, t.Connect, t.TLS, t.TTFB, t.Body, t.Total} {
+			phases[i] = append(phases[i], v)
+		}
+	}
+	return types.TimingStats{
+		Samples: len(samples),
+		DNS:     percentiles(phases[0]),
+		Connect: percentiles(phases[1]),
+		TLS:     percentiles(phases[2]),
+		TTFB:    percentiles(phases[3]),
+		Body:    percentiles(phases[4]),
+		Total:   percentiles(phases[5]),
+	}
+}
+
+// URLTimings summarises the recent fetches of one stored URL.
+func (s *Store) URLTimings(url string) (types.TimingStats, bool) {
+	value, exists := s.Load(url)
+	if !exists {
+		return types.TimingStats{}, false
+	}
+	Mutex.RLock()
+	stats := summarize(value.(*types.URLData).Timings)
+	Mutex.RUnlock()
+	stats.URL = url
+	return stats, true
+}
+
+// HostTimings summarises the recent fetches of every URL on host.
+func (s *Store) HostTimings(host string) (types.TimingStats, bool) {
+	s.mutex.Lock()
+	urls := make([]string, 0, len(s.hosts[host]))
+	for u := range s.hosts[host] {
+		urls = append(urls, u)
+	}
+	s.mutex.Unlock()
+	if len(urls) == 0 {
+		return types.TimingStats{}, false
+	}
+
+	var samples []types.FetchTimings
+	Mutex.RLock()
+	for _, u := range urls {
+		if value, exists := s.Load(u); exists {
+			samples = append(samples, value.(*types.URLData).Timings...)
+		}
+	}
+	Mutex.RUnlock()
+
+	stats := summarize(samples)
+	stats.Host = host
+	return stats, true
+}
+
+// Timings summarises recent fetches grouped by URL or host, slowest p90
+// total first, keeping up to limit groups that have samples.
+func (s *Store) Timings(by string, limit int) []types.TimingStats {
+	groups := make(map[string][]types.FetchTimings)
+	Mutex.RLock()
+	s.Range(func(_, value interface{}) bool {
+		data := value.(*types.URLData)
+		if len(data.Timings) == 0 {
+			return true
+		}
+		key := data.URL
+		if by == TimingsByHost {
+			key = hostOf(data.URL)
+		}
+		groups[key] = append(groups[key], data.Timings...)
+		return true
+	})
+	Mutex.RUnlock()
+
+	all := make([]types.TimingStats, 0, len(groups))
+	for key, samples := range groups {
+		stats := summarize(samples)
+		if by == TimingsByHost {
+			stats.Host = key
+		} else {
+			stats.URL = key
+		}
+		all = append(all, stats)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Total.P90 != all[j].Total.P90 {
+			return all[i].Total.P90 > all[j].Total.P90
+		}
+		return all[i].URL+all[i].Host < all[j].URL+all[j].Host
+	})
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	return all
+}
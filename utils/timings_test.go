@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentilesIgnoreSkippedPhases(t *testing.T) {
+	values := []float64{0, 0}
+	for i := 1; i <= 100; i++ {
+		values = append(values, float64(i))
+	}
+	assert.Equal(t, types.Percentiles{P50: 50, P90: 90, P99: 99}, percentiles(values))
+	assert.Equal(t, types.Percentiles{}, percentiles([]float64{0, 0}))
+	assert.Equal(t, types.Percentiles{P50: 7, P90: 7, P99: 7}, percentiles([]float64{7}))
+}
+
+func TestStoreTimingsGroupByHost(t *testing.T) {
+	store := NewStore()
+	store.Store("http://a.com/1", &types.URLData{URL: "http://a.com/1", Timings: []types.FetchTimings{{Total: 1}, {Total: 3}}})
+	store.Store("http://a.com/2", &types.URLData{URL: "http://a.com/2", Timings: []types.FetchTimings{{Total: 2}}})
+	store.Store("http://b.com/", &types.URLData{URL: "http://b.com/", Timings: []types.FetchTimings{{Total: 0.5, DNS: 0.1}}})
+	store.Store("http://c.com/", &types.URLData{URL: "http://c.com/"})
+
+	hosts := store.Timings(TimingsByHost, 10)
+	assert.Len(t, hosts, 2)
+	assert.Equal(t, "a.com", hosts[0].Host)
+	assert.Equal(t, 3, hosts[0].Samples)
+	assert.Equal(t, types.Percentiles{P50: 2, P90: 3, P99: 3}, hosts[0].Total)
+	assert.Equal(t, "b.com", hosts[1].Host)
+	assert.Equal(t, 0.1, hosts[1].DNS.P50)
+
+	urls := store.Timings(TimingsByURL, 1)
+	assert.Len(t, urls, 1)
+	assert.Equal(t, "http://a.com/1", urls[0].URL)
+
+	host, exists := store.HostTimings("a.com")
+	assert.True(t, exists)
+	assert.Equal(t, 3, host.Samples)
+	_, exists = store.HostTimings("missing.com")
+	assert.False(t, exists)
+
+	url, exists := store.URLTimings("http://b.com/")
+	assert.True(t, exists)
+	assert.Equal(t, 1, url.Samples)
+}
+
+func TestFetchURLRecordsTimings(t *testing.T) {
+	body := strings.Repeat("x", 64<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	URLStore.Store(server.URL, &types.URLData{URL: server.URL})
+	assert.NoError(t, FetchURL(server.URL))
+	assert.NoError(t, FetchURL(server.URL))
+
+	value, _ := URLStore.Load(server.URL)
+	data := value.(*types.URLData)
+	assert.Len(t, data.Timings, 2)
+	assert.Equal(t, int64(len(body)), data.ContentLength)
+
+	first, second := data.Timings[0], data.Timings[1]
+	assert.Greater(t, first.Connect, 0.0)
+	assert.Greater(t, first.TTFB, 0.0)
+	assert.Greater(t, first.Total, 0.0)
+	assert.GreaterOrEqual(t, first.Total, first.TTFB+first.Body)
+	// The body was drained, so the second fetch reuses the connection.
+	assert.True(t, second.Reused)
+	assert.Zero(t, second.Connect)
+	assert.Equal(t, second.Total, data.FetchTime)
+
+	stats, exists := URLStore.URLTimings(server.URL)
+	assert.True(t, exists)
+	assert.Equal(t, 2, stats.Samples)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+)
+
+// Each resolution keeps enough buckets to answer windows up to its span:
+// an hour by minute, two days by hour and thirty days by day.
+var resolutions = []struct {
+	width   time.Duration
+	buckets int
+	counter func(*types.SubmissionHistory) *types.BucketCounter
+}{
+	{time.Minute, 60, func(h *types.SubmissionHistory) *types.BucketCounter { return &h.Minutes }},
+	{time.Hour, 48, func(h *types.SubmissionHistory) *types.BucketCounter { return &h.Hours }},
+	{24 * time.Hour, 30, func(h *types.SubmissionHistory) *types.BucketCounter { return &h.Days }},
+}
+
+// MaxTrendingWindow is the longest window the history can answer.
+var MaxTrendingWindow = 30 * 24 * time.Hour
+
+// RecordSubmission adds one submission at t to history, allocating it on
+// first use. Callers must hold Mutex.
+func RecordSubmission(history **types.SubmissionHistory, t time.Time) {
+	if *history == nil {
+		*history = &types.SubmissionHistory{}
+	}
+	for _, res := range resolutions {
+		add(res.counter(*history), res.buckets, t.UnixNano()/int64(res.width))
+	}
+}
+
+func add(counter *types.BucketCounter, size int, index int64) {
+	if len(counter.Buckets) != size {
+		counter.Buckets = make([]int, size)
+		counter.Last = index
+	}
+	if index > counter.Last {
+		// Clear the buckets skipped since the last write; they now belong
+		// to the current lap of the ring.
+		for i := counter.Last + 1; i <= index && i <= counter.Last+int64(size); i++ {
+			counter.Buckets[i%int64(size)] = 0
+		}
+		counter.Last = index
+	}
+	if index > counter.Last-int64(size) {
+		counter.Buckets[index%int64(size)]++
+	}
+}
+
+// SubmissionsWithin counts submissions in the window ending at now, using
+// the finest resolution that spans it. Callers must hold Mutex for reading.
+func SubmissionsWithin(history *types.SubmissionHistory, window time.Duration, now time.Time) int {
+	if history == nil {
+		return 0
+	}
+	for _, res := range resolutions {
+		if window > res.width*time.Duration(res.buckets) {
+			continue
+		}
+		counter := res.counter(history)
+		if len(counter.Buckets) != res.buckets {
+			return 0
+		}
+		size := int64(res.buckets)
+		current := now.UnixNano() / int64(res.width)
+		span := int64((window + res.width - 1) / res.width)
+
+		total := 0
+		for i := current - span + 1; i <= current; i++ {
+			if i <= counter.Last && i > counter.Last-size {
+				total += counter.Buckets[i%size]
+			}
+		}
+		return total
+	}
+	return 0
+}
+
+// Trending returns up to limit URLs ranked by submissions per hour over the
+// window ending now.
+func Trending(window time.Duration, limit int) ([]types.TrendingURL, error) {
+	return URLStore.Trending(window, limit, time.Now())
+}
+
+func (s *Store) Trending(window time.Duration, limit int, now time.Time) ([]types.TrendingURL, error) {
+	if window < time.Minute || window > MaxTrendingWindow {
+		return nil, fmt.Errorf("window must be between 1m and %s", MaxTrendingWindow)
+	}
+
+	var trending []types.TrendingURL
+	Mutex.RLock()
+	s.Range(func(_, value interface{}) bool {
+		data := value.(*types.URLData)
+		if !data.LastSubmitted.IsZero() && now.Sub(data.LastSubmitted) > window {
+			return true
+		}
+		if n := SubmissionsWithin(data.History, window, now); n > 0 {
+			trending = append(trending, types.TrendingURL{
+				URL:         data.URL,
+				Submissions: n,
+				Velocity:    float64(n) / window.Hours(),
+				Count:       data.Count,
+			})
+		}
+		return true
+	})
+	Mutex.RUnlock()
+
+	sort.Slice(trending, func(i, j int) bool {
+		if trending[i].Submissions != trending[j].Submissions {
+			return trending[i].Submissions > trending[j].Submissions
+		}
+		return trending[i].URL < trending[j].URL
+	})
+	if len(trending) > limit {
+		trending = trending[:limit]
+	}
+	return trending, nil
+}
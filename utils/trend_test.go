@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmissionsWithin(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var history *types.SubmissionHistory
+
+	RecordSubmission(&history, now.Add(-3*24*time.Hour))
+	RecordSubmission(&history, now.Add(-5*time.Hour))
+	RecordSubmission(&history, now.Add(-30*time.Minute))
+	RecordSubmission(&history, now.Add(-time.Minute))
+	RecordSubmission(&history, now)
+
+	assert.Equal(t, 2, SubmissionsWithin(history, 2*time.Minute, now))
+	assert.Equal(t, 3, SubmissionsWithin(history, time.Hour, now))
+	assert.Equal(t, 4, SubmissionsWithin(history, 6*time.Hour, now))
+	assert.Equal(t, 5, SubmissionsWithin(history, 7*24*time.Hour, now))
+
+	// An hour later the minute buckets have rolled over.
+	later := now.Add(90 * time.Minute)
+	assert.Equal(t, 0, SubmissionsWithin(history, time.Hour, later))
+	assert.Equal(t, 3, SubmissionsWithin(history, 3*time.Hour, later))
+}
+
+func TestSubmissionsWithinSurvivesLongGaps(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var history *types.SubmissionHistory
+
+	RecordSubmission(&history, now)
+	RecordSubmission(&history, now.Add(400*24*time.Hour))
+
+	assert.Equal(t, 1, SubmissionsWithin(history, time.Hour, now.Add(400*24*time.Hour)))
+}
+
+func TestTrendingRanksRecentVelocity(t *testing.T) {
+	now := time.Now()
+	store := NewStore()
+
+	old := &types.URLData{URL: "http://old.com", Count: 1000, CreatedAt: now.AddDate(-1, 0, 0), LastSubmitted: now.Add(-48 * time.Hour)}
+	for i := 0; i < 1000; i++ {
+		RecordSubmission(&old.History, now.Add(-48*time.Hour))
+	}
+	store.Store(old.URL, old)
+
+	for i := 0; i < 5; i++ {
+		store.Submit("http://hot.com")
+	}
+	store.Submit("http://warm.com")
+
+	trending, err := store.Trending(time.Hour, 10, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, trending, 2)
+	assert.Equal(t, "http://hot.com", trending[0].URL)
+	assert.Equal(t, 5, trending[0].Submissions)
+	assert.InDelta(t, 5.0, trending[0].Velocity, 0.001)
+
+	_, err = store.Trending(365*24*time.Hour, 10, now)
+	assert.Error(t, err)
+	_, err = store.Trending(time.Second, 10, now)
+	assert.Error(t, err)
+}
@@ -1,39 +1,80 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/Dev-AustinPeter/spamhaus-take-home-task/constants"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/health"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/metrics"
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/tracing"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
 )
 
 var (
 	Mutex    sync.RWMutex
-	URLStore sync.Map
-
-	semaphore = make(chan struct{}, constants.MAX_DOWNLOADS)
+	URLStore = NewStore()
+)
 
-	httpClient = &http.Client{
-		Transport: &http.Transport{
-			MaxIdleConns:       10,
-			IdleConnTimeout:    30 * time.Second,
-			DisableCompression: true,
-		},
-	}
+var (
+	ErrBodyTooLarge         = errors.New("request body too large")
+	ErrUnsupportedMediaType = errors.New("request body must be application/json")
 )
 
+// ParseJson decodes a request body holding exactly one JSON object into
+// payload, rejecting unknown fields. A Content-Type other than JSON fails
+// with ErrUnsupportedMediaType and a body cut off by http.MaxBytesReader
+// with ErrBodyTooLarge; a missing Content-Type is accepted.
 func ParseJson(r *http.Request, payload any) error {
 	if r.Body == nil {
 		return fmt.Errorf("missing request body")
 	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			return ErrUnsupportedMediaType
+		}
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(payload); err != nil {
+		return jsonError(err)
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		if err != nil && !errors.As(err, new(*json.SyntaxError)) {
+			return jsonError(err)
+		}
+		return fmt.Errorf("request body must contain a single JSON object")
+	}
+	return nil
+}
 
-	return json.NewDecoder(r.Body).Decode(payload)
+func jsonError(err error) error {
+	if errors.As(err, new(*http.MaxBytesError)) {
+		return ErrBodyTooLarge
+	}
+	return err
+}
+
+// ParseErrorStatus is the response status for an error from ParseJson.
+func ParseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
 }
 
 func WriteJson(w http.ResponseWriter, status int, v any) error {
@@ -51,46 +92,80 @@ func WriteError(w http.ResponseWriter, status int, err error) {
 	})
 }
 
+// SetNextCursor advertises the next page through X-Next-Cursor and a Link
+// header, leaving both out on the last page.
+func SetNextCursor(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+	nextURL := *r.URL
+	params := nextURL.Query()
+	params.Set("cursor", next)
+	nextURL.RawQuery = params.Encode()
+	w.Header().Set("X-Next-Cursor", next)
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextURL.RequestURI()))
+}
+
 func LoadData(filePath string) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		log.Println("[INFO] No existing data file found, starting fresh.")
+		slog.Info("no existing data file found, starting fresh", "file", filePath)
+		health.MarkStoreLoaded()
 		return
 	}
 	defer file.Close()
 	decoder := json.NewDecoder(file)
 	var tempStore map[string]*types.URLData
 	if err := decoder.Decode(&tempStore); err != nil {
-		log.Println("[ERROR] Error loading data file:", err)
+		slog.Error("loading data file", "file", filePath, "error", err)
 		return
 	}
 	for k, v := range tempStore {
 		URLStore.Store(k, v)
 	}
+	health.MarkStoreLoaded()
 }
 
 func SaveData(filePath string) {
+	start := time.Now()
+	defer func() { metrics.SnapshotSaveDuration.Observe(time.Since(start).Seconds()) }()
+	_, span := tracing.Start(context.Background(), "snapshot.save", tracing.KindInternal)
+	defer span.End()
+	span.SetAttribute("file.path", filePath)
+
 	tempStore := make(map[string]*types.URLData)
+	Mutex.RLock()
 	URLStore.Range(func(key, value interface{}) bool {
 		tempStore[key.(string)] = value.(*types.URLData)
 		return true
 	})
 	data, err := json.MarshalIndent(tempStore, "", "  ")
+	Mutex.RUnlock()
+	span.SetAttribute("snapshot.urls", len(tempStore))
+	span.SetAttribute("snapshot.bytes", len(data))
 	if err != nil {
-		log.Println("[ERROR] Error marshaling data:", err)
+		span.RecordError(err)
+		metrics.SnapshotSaveFailures.Inc()
+		health.RecordSnapshot(err)
+		slog.Error("marshaling data", "error", err)
 		return
 	}
 	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		log.Println("[ERROR] Error writing data to file:", err)
+		span.RecordError(err)
+		metrics.SnapshotSaveFailures.Inc()
+		health.RecordSnapshot(err)
+		slog.Error("writing data file", "file", filePath, "error", err)
+		return
 	}
+	health.RecordSnapshot(nil)
 }
 
-func StartBatchSave(filepath string) {
-	ticker := time.NewTicker(time.Duration(constants.BATCH_SAVE_INTERVAL) * time.Second)
+func StartBatchSave(filepath string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	for range ticker.C {
 		SaveData(filepath)
-		log.Println("[INFO] Data batch saved.")
+		slog.Info("data batch saved", "file", filepath)
 	}
 }
 
@@ -106,30 +181,3 @@ func FilterByURL(urls []*types.URLData, query string) []*types.URLData {
 	}
 	return filtered
 }
-
-func FetchURL(url string) {
-	semaphore <- struct{}{}
-	defer func() { <-semaphore }()
-
-	start := time.Now()
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		if data, exists := URLStore.Load(url); exists {
-			data.(*types.URLData).FailureCount++
-		}
-		log.Printf("[ERROR] Failed to fetch URL: %s, Error: %v\n", url, err)
-
-		return
-	}
-
-	defer resp.Body.Close()
-	elapsed := time.Since(start).Seconds()
-
-	if data, exists := URLStore.Load(url); exists {
-		data.(*types.URLData).FetchTime = elapsed
-		data.(*types.URLData).SuccessCount++
-		data.(*types.URLData).LastFetched = time.Now().Format(time.RFC3339)
-
-		log.Printf("[INFO] Successfully fetched URL: %s, Fetch Time: %.2f seconds, Success Count: %d, Failure Count: %d\n", url, elapsed, data.(*types.URLData).SuccessCount, data.(*types.URLData).FailureCount)
-	}
-}
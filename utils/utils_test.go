@@ -2,15 +2,18 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/Dev-AustinPeter/spamhaus-take-home-task/fuzzyhash"
 	"github.com/Dev-AustinPeter/spamhaus-take-home-task/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -51,13 +54,55 @@ func TestParseJson(t *testing.T) {
 				t.Errorf("ParseJson() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			if !tt.wantErr && payload != tt.want {
+			if !tt.wantErr && !reflect.DeepEqual(payload, tt.want) {
 				t.Errorf("ParseJson() got = %+v, want %+v", payload, tt.want)
 			}
 		})
 	}
 }
 
+func TestParseJson_Strict(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		limit       int64
+		wantStatus  int
+	}{
+		{name: "JSON with charset", body: `{"url": "http://example.com"}`, contentType: "application/json; charset=utf-8"},
+		{name: "Structured JSON suffix", body: `{"url": "http://example.com"}`, contentType: "application/merge-patch+json"},
+		{name: "Unknown field", body: `{"url": "http://example.com", "extra": 1}`, wantStatus: http.StatusBadRequest},
+		{name: "Two objects", body: `{"url": "a"}{"url": "b"}`, wantStatus: http.StatusBadRequest},
+		{name: "Trailing garbage", body: `{"url": "a"} x`, wantStatus: http.StatusBadRequest},
+		{name: "Not JSON", body: `url=a`, contentType: "application/x-www-form-urlencoded", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "Malformed content type", body: `{"url": "a"}`, contentType: "application/", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "Too large", body: `{"url": "` + strings.Repeat("a", 100) + `"}`, limit: 64, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "Too large after first object", body: `{"url": "a"}` + strings.Repeat(" ", 100), limit: 64, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.limit > 0 {
+				req.Body = http.MaxBytesReader(httptest.NewRecorder(), req.Body, tt.limit)
+			}
+			var payload types.RequestUrlPayload
+
+			err := ParseJson(req, &payload)
+
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Equal(t, tt.wantStatus, ParseErrorStatus(err))
+		})
+	}
+}
+
 func TestWriteJson(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -251,3 +296,75 @@ func TestFetchURL(t *testing.T) {
 	assert.Equal(t, 1, urlData.SuccessCount)
 
 }
+
+func TestFetchURL_ConditionalGet(t *testing.T) {
+	body := "hello world"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	URLStore.Store(server.URL, &types.URLData{URL: server.URL})
+
+	FetchURL(server.URL)
+	FetchURL(server.URL)
+
+	storedData, _ := URLStore.Load(server.URL)
+	urlData := storedData.(*types.URLData)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 2, urlData.SuccessCount)
+	assert.Equal(t, `"v1"`, urlData.ETag)
+	assert.Equal(t, "Mon, 01 Jan 2024 00:00:00 GMT", urlData.LastModified)
+	assert.Equal(t, 1, urlData.NotModifiedCount)
+	assert.Equal(t, int64(len(body)), urlData.BytesSaved)
+	// The 304 keeps the hashes of the body seen on the first fetch.
+	assert.Equal(t, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", urlData.ContentHash)
+	assert.Equal(t, fuzzyhash.Hash([]byte(body)), urlData.FuzzyHash)
+
+	stats := CollectStats()
+	assert.GreaterOrEqual(t, stats.BytesSaved, int64(len(body)))
+}
+
+func TestFetchURLCountsErrorPagesAsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such page", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	hooksMutex.Lock()
+	saved := bodyHooks
+	bodies := 0
+	bodyHooks = []func(context.Context, string, []byte, string){func(context.Context, string, []byte, string) { bodies++ }}
+	hooksMutex.Unlock()
+	defer func() {
+		hooksMutex.Lock()
+		bodyHooks = saved
+		hooksMutex.Unlock()
+	}()
+
+	URLStore.Store(server.URL, &types.URLData{URL: server.URL, ETag: `"v1"`, ContentLength: 5, ContentHash: "kept",
+		FuzzyHash: "kept"})
+	defer URLStore.Remove(server.URL)
+	assert.Error(t, FetchURL(server.URL))
+
+	storedData, _ := URLStore.Load(server.URL)
+	urlData := storedData.(*types.URLData)
+	// An error page counts as a failure, is neither hashed nor passed to the
+	// OnBody hooks, and leaves what was known of the last page served.
+	assert.Equal(t, types.FetchStatusHTTPError, urlData.LastFetchStatus)
+	assert.Equal(t, 1, urlData.FailureCount)
+	assert.Zero(t, urlData.SuccessCount)
+	assert.Equal(t, `"v1"`, urlData.ETag)
+	assert.Equal(t, int64(5), urlData.ContentLength)
+	assert.Equal(t, "kept", urlData.ContentHash)
+	assert.Equal(t, "kept", urlData.FuzzyHash)
+	assert.Zero(t, bodies)
+}